@@ -0,0 +1,244 @@
+package mbserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	FuncCodeReadCoils              = 0x01
+	FuncCodeReadDiscreteInputs     = 0x02
+	FuncCodeReadHoldingRegisters   = 0x03
+	FuncCodeReadInputRegisters     = 0x04
+	FuncCodeWriteSingleCoil        = 0x05
+	FuncCodeWriteSingleRegister    = 0x06
+	FuncCodeWriteMultipleCoils     = 0x0F
+	FuncCodeWriteMultipleRegisters = 0x10
+	FuncCodeMaskWriteRegister      = 0x16
+	FuncCodeReadWriteMultiple      = 0x17
+
+	maxReadBits       = 2000
+	maxReadRegisters  = 125
+	maxWriteBits      = 1968
+	maxWriteRegisters = 123
+	maxReadWriteWrite = 121 // registers written by one read/write multiple registers request
+)
+
+// Exception is a Modbus exception code that is returned to the master instead of a normal response.
+type Exception byte
+
+const (
+	IllegalFunction              Exception = 0x01
+	IllegalDataAddress           Exception = 0x02
+	IllegalDataValue             Exception = 0x03
+	ServerDeviceFailure          Exception = 0x04
+	Acknowledge                  Exception = 0x05
+	ServerDeviceBusy             Exception = 0x06
+	GatewayPathUnavailable       Exception = 0x0A
+	GatewayTargetFailedToRespond Exception = 0x0B
+)
+
+func (e Exception) Error() string {
+	return fmt.Sprintf("modbus exception %d", byte(e))
+}
+
+// Handler serves the data of the server. The unit id selects the device that the request is addressed to.
+// Returning an Exception sends it to the master, any other error is returned as ServerDeviceFailure.
+type Handler interface {
+	ReadCoils(unitId uint8, addr, quantity uint16) ([]bool, error)
+	ReadDiscreteInputs(unitId uint8, addr, quantity uint16) ([]bool, error)
+	ReadHoldingRegisters(unitId uint8, addr, quantity uint16) ([]uint16, error)
+	ReadInputRegisters(unitId uint8, addr, quantity uint16) ([]uint16, error)
+	WriteCoils(unitId uint8, addr uint16, values []bool) error
+	WriteHoldingRegisters(unitId uint8, addr uint16, values []uint16) error
+}
+
+// HandlePDU runs the request PDU (function code and data) against the handler, and returns the response PDU.
+func HandlePDU(handler Handler, unitId uint8, pdu []byte) []byte {
+	if len(pdu) == 0 {
+		return exceptionResponse(0, IllegalFunction)
+	}
+	functionCode := pdu[0]
+	data, err := handleRequest(handler, unitId, functionCode, pdu[1:])
+	if err != nil {
+		var exception Exception
+		if !errors.As(err, &exception) {
+			exception = ServerDeviceFailure
+		}
+		return exceptionResponse(functionCode, exception)
+	}
+	return append([]byte{functionCode}, data...)
+}
+
+func handleRequest(handler Handler, unitId uint8, functionCode byte, data []byte) ([]byte, error) {
+	switch functionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		if len(data) != 4 {
+			return nil, IllegalDataValue
+		}
+		addr, quantity := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4])
+		if quantity < 1 || quantity > maxReadBits {
+			return nil, IllegalDataValue
+		}
+		var values []bool
+		var err error
+		if functionCode == FuncCodeReadCoils {
+			values, err = handler.ReadCoils(unitId, addr, quantity)
+		} else {
+			values, err = handler.ReadDiscreteInputs(unitId, addr, quantity)
+		}
+		if err != nil {
+			return nil, err
+		}
+		packed := encodeBools(values)
+		return append([]byte{byte(len(packed))}, packed...), nil
+
+	case FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters:
+		if len(data) != 4 {
+			return nil, IllegalDataValue
+		}
+		addr, quantity := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4])
+		if quantity < 1 || quantity > maxReadRegisters {
+			return nil, IllegalDataValue
+		}
+		var values []uint16
+		var err error
+		if functionCode == FuncCodeReadHoldingRegisters {
+			values, err = handler.ReadHoldingRegisters(unitId, addr, quantity)
+		} else {
+			values, err = handler.ReadInputRegisters(unitId, addr, quantity)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 1+len(values)*2)
+		out[0] = byte(len(values) * 2)
+		for i, value := range values {
+			binary.BigEndian.PutUint16(out[1+i*2:], value)
+		}
+		return out, nil
+
+	case FuncCodeWriteSingleCoil:
+		if len(data) != 4 {
+			return nil, IllegalDataValue
+		}
+		addr, value := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4])
+		if value != 0xFF00 && value != 0x0000 {
+			return nil, IllegalDataValue
+		}
+		if err := handler.WriteCoils(unitId, addr, []bool{value == 0xFF00}); err != nil {
+			return nil, err
+		}
+		return data, nil
+
+	case FuncCodeWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, IllegalDataValue
+		}
+		addr, value := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4])
+		if err := handler.WriteHoldingRegisters(unitId, addr, []uint16{value}); err != nil {
+			return nil, err
+		}
+		return data, nil
+
+	case FuncCodeWriteMultipleCoils:
+		if len(data) < 5 {
+			return nil, IllegalDataValue
+		}
+		addr, quantity, byteCount := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]), int(data[4])
+		if quantity < 1 || quantity > maxWriteBits || byteCount != (int(quantity)+7)/8 || len(data) != 5+byteCount {
+			return nil, IllegalDataValue
+		}
+		if err := handler.WriteCoils(unitId, addr, decodeBools(quantity, data[5:])); err != nil {
+			return nil, err
+		}
+		return data[0:4], nil
+
+	case FuncCodeWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, IllegalDataValue
+		}
+		addr, quantity, byteCount := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]), int(data[4])
+		if quantity < 1 || quantity > maxWriteRegisters || byteCount != int(quantity)*2 || len(data) != 5+byteCount {
+			return nil, IllegalDataValue
+		}
+		values := make([]uint16, quantity)
+		for i := range values {
+			values[i] = binary.BigEndian.Uint16(data[5+i*2:])
+		}
+		if err := handler.WriteHoldingRegisters(unitId, addr, values); err != nil {
+			return nil, err
+		}
+		return data[0:4], nil
+
+	case FuncCodeMaskWriteRegister:
+		if len(data) != 6 {
+			return nil, IllegalDataValue
+		}
+		addr, andMask, orMask := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]), binary.BigEndian.Uint16(data[4:6])
+		values, err := handler.ReadHoldingRegisters(unitId, addr, 1)
+		if err != nil {
+			return nil, err
+		}
+		value := (values[0] & andMask) | (orMask &^ andMask)
+		if err = handler.WriteHoldingRegisters(unitId, addr, []uint16{value}); err != nil {
+			return nil, err
+		}
+		return data, nil
+
+	case FuncCodeReadWriteMultiple:
+		if len(data) < 9 {
+			return nil, IllegalDataValue
+		}
+		readAddr, readQuantity := binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4])
+		writeAddr, writeQuantity, byteCount := binary.BigEndian.Uint16(data[4:6]), binary.BigEndian.Uint16(data[6:8]), int(data[8])
+		if readQuantity < 1 || readQuantity > maxReadRegisters || writeQuantity < 1 || writeQuantity > maxReadWriteWrite ||
+			byteCount != int(writeQuantity)*2 || len(data) != 9+byteCount {
+			return nil, IllegalDataValue
+		}
+		writeValues := make([]uint16, writeQuantity)
+		for i := range writeValues {
+			writeValues[i] = binary.BigEndian.Uint16(data[9+i*2:])
+		}
+		// the write is done before the read
+		if err := handler.WriteHoldingRegisters(unitId, writeAddr, writeValues); err != nil {
+			return nil, err
+		}
+		values, err := handler.ReadHoldingRegisters(unitId, readAddr, readQuantity)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 1+len(values)*2)
+		out[0] = byte(len(values) * 2)
+		for i, value := range values {
+			binary.BigEndian.PutUint16(out[1+i*2:], value)
+		}
+		return out, nil
+
+	default:
+		return nil, IllegalFunction
+	}
+}
+
+func exceptionResponse(functionCode byte, exception Exception) []byte {
+	return []byte{functionCode | 0x80, byte(exception)}
+}
+
+func encodeBools(in []bool) (out []byte) {
+	out = make([]byte, (len(in)+7)/8)
+	for i, value := range in {
+		if value {
+			out[i/8] |= 0x01 << (uint(i) % 8)
+		}
+	}
+	return
+}
+
+func decodeBools(quantity uint16, in []byte) (out []bool) {
+	out = make([]bool, quantity)
+	for i := range out {
+		out[i] = (in[i/8]>>(uint(i)%8))&0x01 == 0x01
+	}
+	return
+}
@@ -0,0 +1,147 @@
+package mbserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mbapHeaderLength = 7
+	maxPDULength     = 253
+
+	// DefaultIdleTimeout is how long a master connection can be idle before it is closed
+	DefaultIdleTimeout = 2 * time.Minute
+)
+
+// Server is a Modbus TCP server (slave) that answers requests from masters with a Handler.
+type Server struct {
+	Address     string
+	Handler     Handler
+	IdleTimeout time.Duration
+
+	mutex    sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+// NewServer returns a server listening on address (host:port) once started.
+func NewServer(address string, handler Handler) *Server {
+	return &Server{
+		Address:     address,
+		Handler:     handler,
+		IdleTimeout: DefaultIdleTimeout,
+		conns:       make(map[net.Conn]bool),
+	}
+}
+
+// Start opens the listener and accepts master connections in the background.
+func (s *Server) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.listener != nil {
+		return errors.New("modbus server: already started")
+	}
+	listener, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.accept(listener)
+	return nil
+}
+
+// Stop closes the listener and all open master connections.
+func (s *Server) Stop() error {
+	s.mutex.Lock()
+	listener := s.listener
+	s.listener = nil
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mutex.Unlock()
+	if listener == nil {
+		return nil
+	}
+	err := listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Errorf("modbus server: accept on %s failed: %v", s.Address, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		s.mutex.Lock()
+		if s.listener == nil {
+			s.mutex.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// serve answers the requests of one master connection until it is closed or idle for too long.
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+
+	header := make([]byte, mbapHeaderLength)
+	for {
+		if s.IdleTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		transactionId := binary.BigEndian.Uint16(header[0:2])
+		protocolId := binary.BigEndian.Uint16(header[2:4])
+		length := binary.BigEndian.Uint16(header[4:6])
+		unitId := header[6]
+		if protocolId != 0 || length < 2 || length > maxPDULength+1 {
+			log.Errorf("modbus server: invalid MBAP header from %s, closing connection", conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		response := HandlePDU(s.Handler, unitId, pdu)
+
+		adu := make([]byte, mbapHeaderLength+len(response))
+		binary.BigEndian.PutUint16(adu[0:2], transactionId)
+		binary.BigEndian.PutUint16(adu[2:4], 0)
+		binary.BigEndian.PutUint16(adu[4:6], uint16(len(response)+1))
+		adu[6] = unitId
+		copy(adu[mbapHeaderLength:], response)
+		if _, err := conn.Write(adu); err != nil {
+			return
+		}
+	}
+}
@@ -27,9 +27,12 @@ func (m *Module) addNetwork(body *model.Network) (network *model.Network, err er
 		return nil, errors.New("failed to create modbus network")
 	}
 
-	if boolean.IsTrue(network.Enable) {
+	if isServerNetwork(network) {
+		m.initiateServer(m.pollingContext, network)
+	} else if boolean.IsTrue(network.Enable) {
 		m.initiatePolling(m.pollingContext, network)
-	} else {
+	}
+	if !boolean.IsTrue(network.Enable) {
 		err = m.networkUpdateErr(network, "network disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.NetworkError)
 		err = m.grpcMarshaller.UpdateNetworkDescendantsErrors(network.UUID, "network disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.NetworkError, true)
 	}
@@ -55,6 +58,10 @@ func (m *Module) addDevice(body *model.Device) (device *model.Device, err error)
 		err = m.grpcMarshaller.UpdateDeviceDescendantsErrors(device.UUID, "device disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.DeviceError)
 	}
 
+	if m.getServerByNetworkUUID(device.NetworkUUID) != nil {
+		return device, nil
+	}
+
 	netPollMan, err := m.getNetworkPollManagerByUUID(device.NetworkUUID)
 	if netPollMan == nil || err != nil {
 		m.modbusDebugMsg("addPoint(): cannot find NetworkPollManager for network: ", device.NetworkUUID)
@@ -83,8 +90,8 @@ func (m *Module) addPoint(body *model.Point) (point *model.Point, err error) {
 	}
 	body.ReadPollRequired = boolean.NewTrue()
 
-	if *body.AddressID < 1 || *body.AddressID > 65535 {
-		return nil, errors.New("register must be between 1 and 65535")
+	if err = validatePoint(body); err != nil {
+		return nil, err
 	}
 
 	isTypeBool := checkForBooleanType(body.ObjectType, body.DataType)
@@ -107,6 +114,13 @@ func (m *Module) addPoint(body *model.Point) (point *model.Point, err error) {
 		return nil, err
 	}
 
+	if m.getServerByNetworkUUID(dev.NetworkUUID) != nil {
+		if boolean.IsFalse(point.Enable) {
+			err = m.internalPointUpdateErr(point, "point disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.PointError)
+		}
+		return point, nil
+	}
+
 	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
 	if err != nil {
 		return nil, err
@@ -126,6 +140,17 @@ func (m *Module) addPoint(body *model.Point) (point *model.Point, err error) {
 	return point, nil
 }
 
+// validatePoint checks the point properties that are required to poll it.
+func validatePoint(body *model.Point) error {
+	if body.AddressID == nil || *body.AddressID < 1 || *body.AddressID > 65535 {
+		return errors.New("register must be between 1 and 65535")
+	}
+	if isStringPoint(body) {
+		return validateStringPoint(body)
+	}
+	return nil
+}
+
 func (m *Module) updateNetwork(uuid string, body *model.Network) (network *model.Network, err error) {
 	m.modbusDebugMsg("updateNetwork(): ", uuid)
 	if body == nil {
@@ -155,6 +180,23 @@ func (m *Module) updateNetwork(uuid string, body *model.Network) (network *model
 		return nil, err
 	}
 
+	// the client is rebuilt on the next poll, so that transport and serial port changes are applied
+	if netPollMan, _ := m.getNetworkPollManagerByUUID(network.UUID); netPollMan != nil {
+		netPollMan.BusLock.Lock()
+		m.closeSerialClient(network.UUID)
+		netPollMan.BusLock.Unlock()
+	}
+
+	if server := m.getServerByNetworkUUID(network.UUID); server != nil {
+		server.update(m.pollingContext, network)
+		if boolean.IsFalse(network.Enable) {
+			m.grpcMarshaller.UpdateNetworkDescendantsErrors(network.UUID, "network disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.DeviceError, true)
+		} else {
+			m.grpcMarshaller.ClearNetworkDescendantsErrors(network.UUID, true)
+		}
+		return network, nil
+	}
+
 	restartPolling := false
 	if body.MaxPollRate != network.MaxPollRate {
 		restartPolling = true
@@ -170,11 +212,13 @@ func (m *Module) updateNetwork(uuid string, body *model.Network) (network *model
 		netPollMan.NetworkName = network.Name
 	}
 
-	if boolean.IsFalse(network.Enable) && netPollMan.Enable == true {
+	pollingStarted := netPollMan.Enable || netPollMan.NetworkPaused() // a paused network still has its points queued
+	if boolean.IsFalse(network.Enable) && pollingStarted {
 		// DO POLLING DISABLE ACTIONS
+		netPollMan.ResumeNetwork() // a pause from the api ends when the network is disabled
 		netPollMan.StopPolling()
 		m.grpcMarshaller.UpdateNetworkDescendantsErrors(network.UUID, "network disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.DeviceError, true)
-	} else if restartPolling || (boolean.IsTrue(network.Enable) && netPollMan.Enable == false) {
+	} else if restartPolling || (boolean.IsTrue(network.Enable) && !pollingStarted) {
 		if restartPolling {
 			netPollMan.StopPolling()
 		}
@@ -222,11 +266,16 @@ func (m *Module) updateDevice(uuid string, body *model.Device) (device *model.De
 		}
 	}
 
+	if m.getServerByNetworkUUID(device.NetworkUUID) != nil {
+		return device, nil
+	}
+
 	netPollMan, err := m.getNetworkPollManagerByUUID(device.NetworkUUID)
 	if netPollMan == nil || err != nil {
 		m.modbusDebugMsg("updateDevice(): cannot find NetworkPollManager for network: ", device.NetworkUUID)
 		return
 	}
+	netPollMan.ResetDeviceHealth(device.UUID)
 	if boolean.IsFalse(device.Enable) {
 		// DO POLLING DISABLE ACTIONS FOR DEVICE
 		m.grpcMarshaller.UpdateDeviceDescendantsErrors(device.UUID, "device disabled", dto.MessageLevel.Warning, dto.CommonFaultCode.DeviceError)
@@ -273,8 +322,8 @@ func (m *Module) updatePoint(uuid string, body *model.Point) (point *model.Point
 		body = resetWriteableProperties(body)
 	}
 
-	if *body.AddressID < 1 || *body.AddressID > 65535 {
-		return nil, errors.New("register must be between 1 and 65535")
+	if err = validatePoint(body); err != nil {
+		return nil, err
 	}
 
 	isTypeBool := checkForBooleanType(body.ObjectType, body.DataType)
@@ -307,6 +356,10 @@ func (m *Module) updatePoint(uuid string, body *model.Point) (point *model.Point
 		return nil, err
 	}
 
+	if m.getServerByNetworkUUID(dev.NetworkUUID) != nil {
+		return point, nil
+	}
+
 	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
 	if netPollMan == nil || err != nil {
 		m.modbusErrorMsg("updatePoint(): cannot find NetworkPollManager for network: ", dev.NetworkUUID)
@@ -335,6 +388,16 @@ func (m *Module) writePoint(pntUUID string, body *dto.PointWriter) (point *model
 		return
 	}
 
+	if m.getServerByPointUUID(pntUUID) != nil {
+		// points of a server network have no device to write to, so the write value is the present value
+		pnt, err := m.grpcMarshaller.PointWrite(pntUUID, body)
+		if err != nil {
+			m.modbusDebugMsg("writePoint(): bad response from WritePoint(), ", err)
+			return nil, err
+		}
+		return &pnt.Point, nil
+	}
+
 	body.IgnorePresentValueUpdate = true
 	pnt, err := m.grpcMarshaller.PointWrite(pntUUID, body)
 	if err != nil {
@@ -396,6 +459,11 @@ func (m *Module) deleteNetwork(uuid string) (ok bool, err error) {
 		return
 	}
 	found := false
+	if server := m.getServerByNetworkUUID(uuid); server != nil {
+		server.stop()
+		delete(m.servers, uuid)
+		found = true
+	}
 	for index, netPollMan := range m.NetworkPollManagers {
 		if netPollMan.FFNetworkUUID == uuid {
 			netPollMan.StopPolling()
@@ -422,13 +490,19 @@ func (m *Module) deleteDevice(body *model.Device) (ok bool, err error) {
 		return
 	}
 
-	netPollMan, err := m.getNetworkPollManagerByUUID(body.NetworkUUID)
-	if netPollMan == nil || err != nil {
-		m.modbusDebugMsg("deleteDevice(): cannot find NetworkPollManager for network: ", body.NetworkUUID)
-		_ = m.deviceUpdateErr(body, "cannot find NetworkPollManager for network", dto.MessageLevel.Fail, dto.CommonFaultCode.SystemError)
-		return
+	if m.getServerByNetworkUUID(body.NetworkUUID) == nil {
+		netPollMan, err := m.getNetworkPollManagerByUUID(body.NetworkUUID)
+		if netPollMan == nil || err != nil {
+			m.modbusDebugMsg("deleteDevice(): cannot find NetworkPollManager for network: ", body.NetworkUUID)
+			_ = m.deviceUpdateErr(body, "cannot find NetworkPollManager for network", dto.MessageLevel.Fail, dto.CommonFaultCode.SystemError)
+			return false, err
+		}
+		netPollMan.PollQueue.RemovePollingPointByDeviceUUID(body.UUID)
+		netPollMan.ResetDeviceHealth(body.UUID)
+		netPollMan.ForgetDeviceMetrics(body.UUID)
+		netPollMan.ResetDevicePollStats(body.UUID)
+		netPollMan.ResumeDevice(body.UUID)
 	}
-	netPollMan.PollQueue.RemovePollingPointByDeviceUUID(body.UUID)
 	err = m.grpcMarshaller.DeleteDevice(body.UUID)
 	if err != nil {
 		return false, err
@@ -449,14 +523,16 @@ func (m *Module) deletePoint(body *model.Point) (ok bool, err error) {
 		return false, err
 	}
 
-	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
-	if netPollMan == nil || err != nil {
-		m.modbusDebugMsg("addPoint(): cannot find NetworkPollManager for network: ", dev.NetworkUUID)
-		_ = m.internalPointUpdateErr(body, "cannot find NetworkPollManager for network", dto.MessageLevel.Fail, dto.CommonFaultCode.SystemError)
-		return
+	if m.getServerByNetworkUUID(dev.NetworkUUID) == nil {
+		netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+		if netPollMan == nil || err != nil {
+			m.modbusDebugMsg("addPoint(): cannot find NetworkPollManager for network: ", dev.NetworkUUID)
+			_ = m.internalPointUpdateErr(body, "cannot find NetworkPollManager for network", dto.MessageLevel.Fail, dto.CommonFaultCode.SystemError)
+			return false, err
+		}
+		netPollMan.PollQueue.RemovePollingPointByPointUUID(body.UUID)
+		netPollMan.ResetPointPollStats(body.UUID)
 	}
-
-	netPollMan.PollQueue.RemovePollingPointByPointUUID(body.UUID)
 	err = m.grpcMarshaller.DeletePoint(body.UUID)
 	if err != nil {
 		return false, err
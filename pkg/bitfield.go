@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+const (
+	metaTagBitWidth  = "bit_width"  // point meta tag: number of bits of a bitwise point, from its BitwiseIndex. Default 1
+	metaTagMaskWrite = "mask_write" // device meta tag: "true" if the device supports mask write register (function code 22)
+
+	metaTagReadWriteMultiple = "read_write_multiple" // device meta tag: "true" if the device supports read/write multiple registers (function code 23)
+)
+
+// bitField is the bits of a register value that a bitwise point reads and writes, eg: an operating mode in bits 4 to 7.
+type bitField struct {
+	offset uint
+	width  uint
+}
+
+// pointBitField returns the bit field of a bitwise point.
+func pointBitField(pnt *model.Point) (field bitField, ok bool) {
+	if !boolean.IsTrue(pnt.IsBitwise) || pnt.BitwiseIndex == nil || *pnt.BitwiseIndex < 0 {
+		return field, false
+	}
+	field.offset = uint(*pnt.BitwiseIndex)
+	field.width = 1
+	if value, ok := pointMetaTag(pnt, metaTagBitWidth); ok {
+		if width, err := strconv.Atoi(value); err == nil && width > 1 {
+			field.width = uint(width)
+		}
+	}
+	return field, true
+}
+
+// deviceSupports checks if the capability meta tag of the device is set, eg: mask_write.
+func deviceSupports(dev *model.Device, metaTag string) bool {
+	for _, tag := range dev.MetaTags {
+		if tag != nil && tag.Key == metaTag {
+			supported, _ := strconv.ParseBool(strings.TrimSpace(tag.Value))
+			return supported
+		}
+	}
+	return false
+}
+
+// canMaskWrite checks if the bit field of the point can be written with mask write register, which needs no read first.
+func canMaskWrite(dev *model.Device, pnt *model.Point, field bitField) bool {
+	objectType := convertOldObjectType(nstring.NewString(pnt.ObjectType).ToSnakeCase())
+	return deviceSupports(dev, metaTagMaskWrite) && objectType == string(datatype.ObjTypeHoldingRegister) &&
+		pointRegisterLength(pnt) == 1 && field.offset+field.width <= 16
+}
+
+// canWriteReadBack checks if the read-modify-write of the bit field of the point can write the register and read it back
+// in one read/write multiple registers request, so the point gets the field the device holds after the write.
+func canWriteReadBack(dev *model.Device, pnt *model.Point, field bitField) bool {
+	objectType := convertOldObjectType(nstring.NewString(pnt.ObjectType).ToSnakeCase())
+	dataType := nstring.NewString(pnt.DataType).ToSnakeCase()
+	return deviceSupports(dev, metaTagReadWriteMultiple) && objectType == string(datatype.ObjTypeHoldingRegister) &&
+		(dataType == string(datatype.TypeUint16) || dataType == string(datatype.TypeInt16)) && field.offset+field.width <= 16
+}
+
+func (f bitField) mask() uint64 {
+	return (uint64(1)<<f.width - 1) << f.offset
+}
+
+func (f bitField) check(value float64) error {
+	if math.Mod(value, 1) != 0 {
+		return errors.New("cannot get bits from floats")
+	}
+	if value < 0 {
+		return errors.New("cannot get bits from negative numbers")
+	}
+	if f.offset+f.width > 64 {
+		return fmt.Errorf("bits %d to %d are outside of the 64 bit value", f.offset, f.offset+f.width-1)
+	}
+	return nil
+}
+
+func (f bitField) checkFieldValue(fieldValue float64) error {
+	if math.Mod(fieldValue, 1) != 0 || fieldValue < 0 || uint64(fieldValue) > f.mask()>>f.offset {
+		return fmt.Errorf("value %v doesn't fit in the %d bit field", fieldValue, f.width)
+	}
+	return nil
+}
+
+// read returns the value of the field in the register value.
+func (f bitField) read(value float64) (float64, error) {
+	if err := f.check(value); err != nil {
+		return 0, err
+	}
+	return float64((uint64(value) & f.mask()) >> f.offset), nil
+}
+
+// write returns the register value with the field set to fieldValue, for a read-modify-write.
+func (f bitField) write(value, fieldValue float64) (float64, error) {
+	if err := f.check(value); err != nil {
+		return 0, err
+	}
+	if err := f.checkFieldValue(fieldValue); err != nil {
+		return 0, err
+	}
+	return float64(uint64(value)&^f.mask() | uint64(fieldValue)<<f.offset), nil
+}
+
+// maskWrite returns the masks of a mask write register request that sets the field to fieldValue.
+func (f bitField) maskWrite(fieldValue float64) (andMask uint16, orMask uint16, err error) {
+	if f.offset+f.width > 16 {
+		return 0, 0, fmt.Errorf("bits %d to %d are outside of the register, they can't be mask written", f.offset, f.offset+f.width-1)
+	}
+	if err = f.checkFieldValue(fieldValue); err != nil {
+		return 0, 0, err
+	}
+	return ^uint16(f.mask()), uint16(uint64(fieldValue) << f.offset), nil
+}
@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/module-core-modbus/mbserver"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+// maskWriteRegister is how a device applies a mask write register (function code 22) request to the register.
+func maskWriteRegister(register, andMask, orMask uint16) uint16 {
+	return register&andMask | orMask&^andMask
+}
+
+// bitFields are fields at bit 0, in the middle of the register and ending at bit 15.
+var bitFields = []struct {
+	name    string
+	field   bitField
+	andMask uint16 // of a mask write of the field
+	max     float64
+}{
+	{"bit 0", bitField{0, 1}, 0xfffe, 1},
+	{"bits 0 to 3", bitField{0, 4}, 0xfff0, 15},
+	{"bits 4 to 7", bitField{4, 4}, 0xff0f, 15},
+	{"bits 6 to 8", bitField{6, 3}, 0xfe3f, 7},
+	{"bits 12 to 15", bitField{12, 4}, 0x0fff, 15},
+	{"bit 15", bitField{15, 1}, 0x7fff, 1},
+	{"bits 0 to 15", bitField{0, 16}, 0x0000, 65535},
+}
+
+func TestBitFieldRead(t *testing.T) {
+	const register = 0xa5c3 // 1010 0101 1100 0011
+	want := map[string]float64{
+		"bit 0":         1,
+		"bits 0 to 3":   0x3,
+		"bits 4 to 7":   0xc,
+		"bits 6 to 8":   0x7,
+		"bits 12 to 15": 0xa,
+		"bit 15":        1,
+		"bits 0 to 15":  register,
+	}
+	for _, tt := range bitFields {
+		if got, err := tt.field.read(register); err != nil || got != want[tt.name] {
+			t.Errorf("%s: read(%#x) = %v, %v, want %v", tt.name, register, got, err, want[tt.name])
+		}
+		if got, err := tt.field.read(0); err != nil || got != 0 {
+			t.Errorf("%s: read(0) = %v, %v, want 0", tt.name, got, err)
+		}
+	}
+
+	for _, value := range []float64{1.5, -1} {
+		if _, err := (bitField{0, 1}).read(value); err == nil {
+			t.Errorf("read(%v) error = nil", value)
+		}
+	}
+	if _, err := (bitField{60, 8}).read(1); err == nil {
+		t.Error("read() of bits 60 to 67 error = nil")
+	}
+}
+
+func TestBitFieldMaskWrite(t *testing.T) {
+	for _, tt := range bitFields {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, fieldValue := range []float64{0, 1, tt.max} {
+				andMask, orMask, err := tt.field.maskWrite(fieldValue)
+				if err != nil {
+					t.Fatalf("maskWrite(%v) error = %v", fieldValue, err)
+				}
+				if andMask != tt.andMask || orMask != uint16(fieldValue)<<tt.field.offset {
+					t.Errorf("maskWrite(%v) = %#04x, %#04x, want %#04x, %#04x", fieldValue, andMask, orMask, tt.andMask, uint16(fieldValue)<<tt.field.offset)
+				}
+				if orMask&andMask != 0 {
+					t.Errorf("maskWrite(%v) or mask %#04x sets bits outside of the field", fieldValue, orMask)
+				}
+
+				// the device keeps the other bits of the register, and the field reads back as the written value
+				for _, register := range []uint16{0x0000, 0xffff, 0xa5c3} {
+					written := maskWriteRegister(register, andMask, orMask)
+					if got, _ := tt.field.read(float64(written)); got != fieldValue {
+						t.Errorf("field of %#04x after maskWrite(%v) = %v", register, fieldValue, got)
+					}
+					if written&andMask != register&andMask {
+						t.Errorf("maskWrite(%v) of %#04x changed other bits: %#04x", fieldValue, register, written)
+					}
+				}
+			}
+
+			if _, _, err := tt.field.maskWrite(tt.max + 1); err == nil {
+				t.Errorf("maskWrite(%v) error = nil", tt.max+1)
+			}
+		})
+	}
+
+	for _, value := range []float64{-1, 0.5} {
+		if _, _, err := (bitField{4, 4}).maskWrite(value); err == nil {
+			t.Errorf("maskWrite(%v) error = nil", value)
+		}
+	}
+	// fields that don't end in the register can't be mask written
+	for _, field := range []bitField{{14, 4}, {16, 1}} {
+		if _, _, err := field.maskWrite(0); err == nil {
+			t.Errorf("maskWrite() of bits %d to %d error = nil", field.offset, field.offset+field.width-1)
+		}
+	}
+}
+
+func TestBitFieldWrite(t *testing.T) {
+	for _, tt := range bitFields {
+		for _, register := range []float64{0x0000, 0xffff, 0xa5c3} {
+			for _, fieldValue := range []float64{0, 1, tt.max} {
+				// the read-modify-write of a register gives the same value as a mask write of the field
+				andMask, orMask, _ := tt.field.maskWrite(fieldValue)
+				want := float64(maskWriteRegister(uint16(register), andMask, orMask))
+				if got, err := tt.field.write(register, fieldValue); err != nil || got != want {
+					t.Errorf("%s: write(%#04x, %v) = %#04x, %v, want %#04x", tt.name, uint16(register), fieldValue, uint16(got), err, uint16(want))
+				}
+			}
+		}
+		if _, err := tt.field.write(0, tt.max+1); err == nil {
+			t.Errorf("%s: write(0, %v) error = nil", tt.name, tt.max+1)
+		}
+	}
+
+	// fields of 32 and 64 bit registers are written with a read-modify-write
+	if got, err := (bitField{28, 8}).write(0xffffffff, 0); err != nil || got != 0x0fffffff {
+		t.Errorf("write() of bits 28 to 35 = %#x, %v, want 0x0fffffff", uint64(got), err)
+	}
+}
+
+func TestPointBitField(t *testing.T) {
+	bitwise := func(index int, width string) *model.Point {
+		pnt := &model.Point{IsBitwise: boolean.NewTrue(), BitwiseIndex: integer.New(index)}
+		if width != "" {
+			pnt.MetaTags = []*model.PointMetaTag{{Key: metaTagBitWidth, Value: width}}
+		}
+		return pnt
+	}
+	tests := []struct {
+		name  string
+		pnt   *model.Point
+		ok    bool
+		field bitField
+	}{
+		{"not bitwise", &model.Point{BitwiseIndex: integer.New(3)}, false, bitField{}},
+		{"no index", &model.Point{IsBitwise: boolean.NewTrue()}, false, bitField{}},
+		{"negative index", bitwise(-1, ""), false, bitField{}},
+		{"single bit", bitwise(3, ""), true, bitField{3, 1}},
+		{"bit width", bitwise(4, " 4 "), true, bitField{4, 4}},
+		{"ending at bit 15", bitwise(12, "4"), true, bitField{12, 4}},
+		{"invalid bit width", bitwise(4, "x"), true, bitField{4, 1}},
+		{"zero bit width", bitwise(4, "0"), true, bitField{4, 1}},
+	}
+	for _, tt := range tests {
+		if field, ok := pointBitField(tt.pnt); ok != tt.ok || field != tt.field {
+			t.Errorf("%s: pointBitField() = %+v, %t, want %+v, %t", tt.name, field, ok, tt.field, tt.ok)
+		}
+	}
+}
+
+// addBitFieldPoint adds a write_once_then_read point of the bits of holding register 1.
+func (tn *testNetwork) addBitFieldPoint(offset, width int) *model.Point {
+	point, err := tn.module.addPoint(&model.Point{
+		Name:         "bits " + strconv.Itoa(offset),
+		DeviceUUID:   tn.device.UUID,
+		Enable:       boolean.NewTrue(),
+		ObjectType:   string(datatype.ObjTypeHoldingRegister),
+		DataType:     string(datatype.TypeUint16),
+		AddressID:    integer.New(1),
+		IsBitwise:    boolean.NewTrue(),
+		BitwiseIndex: integer.New(offset),
+		WriteMode:    datatype.WriteOnceThenRead,
+		PollPriority: datatype.PriorityNormal,
+		PollRate:     datatype.RateFast,
+		MetaTags:     []*model.PointMetaTag{{Key: metaTagBitWidth, Value: strconv.Itoa(width)}},
+	})
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	return point
+}
+
+func TestPollingBitFieldMaskWrite(t *testing.T) {
+	forTransports(t, func(t *testing.T, tn *testNetwork) {
+		tn.device.MetaTags = []*model.DeviceMetaTag{{Key: metaTagMaskWrite, Value: "true"}}
+		if _, err := tn.marshaller.UpdateDevice(tn.device.UUID, tn.device); err != nil {
+			t.Fatal(err)
+		}
+		// the fields are only written with mask write register requests
+		tn.simDevice.SetException(mbserver.FuncCodeWriteSingleRegister, mbserver.IllegalFunction)
+		tn.simDevice.SetException(mbserver.FuncCodeWriteMultipleRegisters, mbserver.IllegalFunction)
+		tn.simDevice.SetHoldingRegisters(0, 0xa5c3)
+
+		low := tn.addBitFieldPoint(0, 4)
+		middle := tn.addBitFieldPoint(6, 3)
+		high := tn.addBitFieldPoint(12, 4)
+		tn.waitForValue(low.UUID, 0x3)
+		tn.waitForValue(middle.UUID, 0x7)
+		tn.waitForValue(high.UUID, 0xa)
+
+		tn.write(middle.UUID, 2)
+		tn.waitForRegister(0, 0xa483)
+		tn.waitForValue(middle.UUID, 2)
+
+		tn.write(high.UUID, 0xf)
+		tn.waitForRegister(0, 0xf483)
+		tn.waitForValue(high.UUID, 0xf)
+
+		tn.write(low.UUID, 0)
+		tn.waitForRegister(0, 0xf480)
+		tn.waitForValue(low.UUID, 0)
+		tn.waitForValue(middle.UUID, 2)
+		tn.waitForValue(high.UUID, 0xf)
+	})
+}
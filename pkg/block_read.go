@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+const (
+	maxRegisterBlockLength = 125  // max registers in one FC03/FC04 request
+	maxBitBlockLength      = 2000 // max bits in one FC01/FC02 request
+)
+
+// blockPoint is a point that is read as part of a block read, with its position in the device register map.
+type blockPoint struct {
+	pp      *pollqueue.PollingPoint
+	pnt     *model.Point
+	address int
+	length  int
+}
+
+// pointBlock is a single read request that covers all of its points.
+type pointBlock struct {
+	regType smod.RegType
+	start   int
+	length  int
+	points  []*blockPoint
+}
+
+func newBlockPoint(pp *pollqueue.PollingPoint, pnt *model.Point, zeroMode bool) *blockPoint {
+	return &blockPoint{
+		pp:      pp,
+		pnt:     pnt,
+		address: int(pointAddress(pnt, zeroMode)),
+		length:  int(pointRegisterLength(pnt)),
+	}
+}
+
+// pointRegType returns the register type that the point is read from.
+func pointRegType(pnt *model.Point) smod.RegType {
+	return objectRegType(pnt.ObjectType)
+}
+
+// objectRegType returns the register type of a point object type.
+func objectRegType(objectType string) smod.RegType {
+	switch convertOldObjectType(nstring.NewString(objectType).ToSnakeCase()) {
+	case string(datatype.ObjTypeCoil):
+		return smod.Coil
+	case string(datatype.ObjTypeDiscreteInput):
+		return smod.DiscreteInput
+	case string(datatype.ObjTypeInputRegister):
+		return smod.InputRegister
+	default:
+		return smod.HoldingRegister
+	}
+}
+
+// pointRegisterLength returns the number of registers (or bits for coils and discrete inputs) that the point value uses.
+func pointRegisterLength(pnt *model.Point) uint16 {
+	regType := pointRegType(pnt)
+	if regType == smod.Coil || regType == smod.DiscreteInput {
+		return 1
+	}
+	dataType := nstring.NewString(pnt.DataType).ToSnakeCase()
+	switch dataType {
+	case string(datatype.TypeUint32), string(datatype.TypeInt32), string(datatype.TypeFloat32), string(datatype.TypeMod10U32):
+		return 2
+	case string(datatype.TypeUint64), string(datatype.TypeInt64), string(datatype.TypeFloat64):
+		return 4
+	case smod.TypeString:
+		return stringRegisterLength(pnt)
+	default:
+		if count := smod.RegisterCount(dataType); count > 0 {
+			return count
+		}
+		return 1
+	}
+}
+
+// isBlockReadable checks that the point only requires a read, so it can be read together with other points. String
+// points are read on their own, as their value isn't a number.
+func isBlockReadable(pnt *model.Point) bool {
+	return boolean.IsTrue(pnt.Enable) && boolean.IsTrue(pnt.ReadPollRequired) && !boolean.IsTrue(pnt.WritePollRequired) && !isStringPoint(pnt)
+}
+
+// planPointBlock groups the primary point with the candidates that have the same register type and are within maxGap
+// of the block, without exceeding maxLength. Returns nil if no other point can be read with the primary point.
+func planPointBlock(primary *blockPoint, candidates []*blockPoint, maxGap, maxLength int) *pointBlock {
+	regType := pointRegType(primary.pnt)
+	if regType == smod.Coil || regType == smod.DiscreteInput {
+		if maxLength > maxBitBlockLength {
+			maxLength = maxBitBlockLength
+		}
+	} else if maxLength > maxRegisterBlockLength {
+		maxLength = maxRegisterBlockLength
+	}
+
+	sorted := []*blockPoint{primary}
+	for _, bp := range candidates {
+		if bp.pnt.UUID != primary.pnt.UUID && pointRegType(bp.pnt) == regType {
+			sorted = append(sorted, bp)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].address < sorted[j].address
+	})
+	primaryIndex := 0
+	for i, bp := range sorted {
+		if bp == primary {
+			primaryIndex = i
+			break
+		}
+	}
+
+	block := &pointBlock{regType: regType, start: primary.address, length: primary.length, points: []*blockPoint{primary}}
+	left := primaryIndex - 1
+	right := primaryIndex + 1
+	for {
+		added := false
+		end := block.start + block.length
+		if right < len(sorted) {
+			bp := sorted[right]
+			newEnd := end
+			if bp.address+bp.length > newEnd {
+				newEnd = bp.address + bp.length
+			}
+			if bp.address-end <= maxGap && newEnd-block.start <= maxLength {
+				block.points = append(block.points, bp)
+				block.length = newEnd - block.start
+				right++
+				added = true
+			}
+		}
+		end = block.start + block.length
+		if left >= 0 {
+			bp := sorted[left]
+			newStart := block.start
+			if bp.address < newStart {
+				newStart = bp.address
+			}
+			newEnd := end // a long point can end after the block
+			if bp.address+bp.length > newEnd {
+				newEnd = bp.address + bp.length
+			}
+			if block.start-(bp.address+bp.length) <= maxGap && newEnd-newStart <= maxLength {
+				block.points = append(block.points, bp)
+				block.start = newStart
+				block.length = newEnd - newStart
+				left--
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	if len(block.points) < 2 {
+		return nil
+	}
+	return block
+}
+
+// pollPointBlock reads the polling point in one request with the other queued points of the device that are close to it.
+// Returns false if there are no points to group with it, and it should be polled on its own.
+func (m *Module) pollPointBlock(netPollMan *pollqueue.NetworkPollManager, mbClient *smod.ModbusClient, dev *model.Device, pp *pollqueue.PollingPoint, pnt *model.Point, pollStartTime time.Time) bool {
+	if !isBlockReadable(pnt) {
+		return false
+	}
+
+	candidates := make([]*blockPoint, 0)
+	for _, queuedPP := range netPollMan.PollQueue.GetQueuedPollingPointsByDeviceUUID(dev.UUID) {
+		queuedPnt, err := m.grpcMarshaller.GetPoint(queuedPP.FFPointUUID, &nmodule.Opts{Args: &nargs.Args{WithMetaTags: true}})
+		if err != nil || queuedPnt == nil || !isBlockReadable(queuedPnt) {
+			continue
+		}
+		candidates = append(candidates, newBlockPoint(queuedPP, queuedPnt, mbClient.DeviceZeroMode))
+	}
+
+	block := planPointBlock(newBlockPoint(pp, pnt, mbClient.DeviceZeroMode), candidates, m.config.BlockReadMaxGap, m.config.BlockReadMaxLength)
+	if block == nil {
+		return false
+	}
+
+	// take the grouped points out of the queue, skipping any that have been removed in the meantime
+	drawn := []*blockPoint{block.points[0]}
+	for _, bp := range block.points[1:] {
+		if netPollMan.PollQueue.DrawBlockPollingPoint(bp.pp.FFPointUUID) != nil {
+			drawn = append(drawn, bp)
+		}
+	}
+	block.points = drawn
+
+	m.modbusPollingMsg(fmt.Sprintf("BLOCK-READ: device: %s, start: %d, length: %d, points: %d", dev.Name, block.start, block.length, len(block.points)))
+	raw, err := mbClient.ReadBlock(uint16(block.start), uint16(block.length), block.regType)
+	reportDevicePoll(netPollMan, dev.UUID, err)
+	if err != nil {
+		// the block may span registers that the device doesn't support, so fall back to reading each point on its own
+		m.modbusPollingMsg(fmt.Sprintf("BLOCK-READ: failed, reading points individually. device: %s, err: %v", dev.Name, err))
+		for _, bp := range block.points {
+			if netPollMan.IsDeviceOffline(dev.UUID) { // don't spend the bus time on the timeouts of the remaining points
+				netPollMan.PollingPointCompleteNotification(bp.pp, bp.pnt, false, false, 0, false, true, pollqueue.DELAYED_RETRY, true)
+				continue
+			}
+			readStartTime := time.Now()
+			_, value, readErr := m.networkRead(mbClient, bp.pnt)
+			reportDevicePoll(netPollMan, dev.UUID, readErr)
+			m.blockPointFinished(netPollMan, bp, value, readErr, time.Since(readStartTime).Seconds())
+		}
+		return true
+	}
+
+	pollTimeSecs := time.Since(pollStartTime).Seconds() / float64(len(block.points))
+	for _, bp := range block.points {
+		var value float64
+		var decodeErr error
+		offset := uint16(bp.address - block.start)
+		if block.regType == smod.Coil || block.regType == smod.DiscreteInput {
+			value, decodeErr = mbClient.DecodeBlockBit(raw, offset)
+		} else {
+			setEncoding(mbClient, bp.pnt.ObjectEncoding)
+			dataType := nstring.NewString(bp.pnt.DataType).ToSnakeCase()
+			value, decodeErr = mbClient.DecodeBlockRegisters(raw, offset, uint16(bp.length), dataType)
+		}
+		m.blockPointFinished(netPollMan, bp, value, decodeErr, pollTimeSecs)
+	}
+	return true
+}
+
+// blockPointFinished updates the point with its value from the block read, and re-adds it to the poll queue.
+// The first point of the block must be one of the CurrentPollPoints of the poll queue.
+func (m *Module) blockPointFinished(netPollMan *pollqueue.NetworkPollManager, bp *blockPoint, value float64, err error, pollTimeSecs float64) {
+	pnt := bp.pnt
+	if err != nil {
+		retryType := m.pointRequestFailed(pnt, err, dto.CommonFaultCode.PointError)
+		netPollMan.PollingPointCompleteNotification(bp.pp, pnt, false, false, pollTimeSecs, false, true, retryType, false)
+		return
+	}
+
+	if field, ok := pointBitField(pnt); ok {
+		fieldValue, bitErr := field.read(value)
+		if bitErr != nil {
+			m.modbusDebugMsg("Bitwise Error: ", bitErr)
+			_ = m.internalPointUpdateErr(pnt, bitErr.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointError)
+			netPollMan.PollingPointCompleteNotification(bp.pp, pnt, false, false, pollTimeSecs, false, true, pollqueue.DELAYED_RETRY, false)
+			return
+		}
+		value = fieldValue
+	}
+
+	m.modbusPollingMsg(fmt.Sprintf("BLOCK-READ-RESPONSE: responseValue %f, point UUID: %s", value, pnt.UUID))
+	if !float.ComparePtrValues(pnt.OriginalValue, &value) {
+		updatedPnt, updateErr := m.internalPointUpdate(pnt, value)
+		if updateErr == nil && updatedPnt != nil {
+			pnt = updatedPnt
+		}
+	}
+	netPollMan.PollingPointCompleteNotification(bp.pp, pnt, false, true, pollTimeSecs, false, true, pollqueue.NORMAL_RETRY, false)
+}
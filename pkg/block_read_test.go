@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+// testBlockPoint is a point of the object type and data type at the zero based address, named after them.
+func testBlockPoint(objectType datatype.ObjectType, dataType datatype.DataType, address int) *blockPoint {
+	pnt := &model.Point{
+		UUID:       fmt.Sprintf("%s-%s-%d", objectType, dataType, address),
+		ObjectType: string(objectType),
+		DataType:   string(dataType),
+		AddressID:  integer.New(address),
+	}
+	return newBlockPoint(nil, pnt, true)
+}
+
+// testBlockPoints are uint16 points of the object type at each address from start to end, excluding end.
+func testBlockPoints(objectType datatype.ObjectType, start, end int) []*blockPoint {
+	points := make([]*blockPoint, 0, end-start)
+	for address := start; address < end; address++ {
+		points = append(points, testBlockPoint(objectType, datatype.TypeUint16, address))
+	}
+	return points
+}
+
+// checkBlock checks the range of the block, and that it has the primary point first and each point once.
+func checkBlock(t *testing.T, block *pointBlock, primary *blockPoint, start, length, points int) {
+	t.Helper()
+	if points == 0 {
+		if block != nil {
+			t.Fatalf("block = start %d, length %d, %d points, want nil", block.start, block.length, len(block.points))
+		}
+		return
+	}
+	if block == nil {
+		t.Fatalf("block = nil, want start %d, length %d, %d points", start, length, points)
+	}
+	if block.start != start || block.length != length || len(block.points) != points {
+		t.Errorf("block = start %d, length %d, %d points, want start %d, length %d, %d points", block.start, block.length, len(block.points), start, length, points)
+	}
+	if block.points[0] != primary {
+		t.Errorf("first point of the block is %s, want the primary point", block.points[0].pnt.UUID)
+	}
+	if block.regType != pointRegType(primary.pnt) {
+		t.Errorf("block register type = %v, want %v", block.regType, pointRegType(primary.pnt))
+	}
+	uuids := make([]string, 0, len(block.points))
+	for _, bp := range block.points {
+		if bp.address < block.start || bp.address+bp.length > block.start+block.length {
+			t.Errorf("point %s is outside of the block", bp.pnt.UUID)
+		}
+		uuids = append(uuids, bp.pnt.UUID)
+	}
+	sort.Strings(uuids)
+	for i := 1; i < len(uuids); i++ {
+		if uuids[i] == uuids[i-1] {
+			t.Errorf("point %s is in the block twice", uuids[i])
+		}
+	}
+}
+
+func TestPlanPointBlock(t *testing.T) {
+	hr := datatype.ObjTypeHoldingRegister
+	tests := []struct {
+		name       string
+		primary    *blockPoint
+		candidates []*blockPoint
+		maxGap     int
+		maxLength  int
+		start      int
+		length     int
+		points     int // 0 if there is no block
+	}{
+		{"no candidates", testBlockPoint(hr, datatype.TypeUint16, 10), nil, 10, 125, 0, 0, 0},
+		{"adjacent", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 11, 13), 0, 125, 10, 3, 3},
+		{"both sides", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 8, 13), 0, 125, 8, 5, 5},
+		{"gap of max gap", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 13, 14), 2, 125, 10, 4, 2},
+		{"gap over max gap", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 13, 14), 1, 125, 0, 0, 0},
+		{"gap before", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 5, 6), 4, 125, 5, 6, 2},
+		{"gaps up to max gap", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeUint16, 12),
+			testBlockPoint(hr, datatype.TypeUint16, 14),
+			testBlockPoint(hr, datatype.TypeUint16, 17), // 2 unused registers before it
+		}, 1, 125, 10, 5, 3},
+		{"mixed data types", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeFloat32, 11),
+			testBlockPoint(hr, datatype.TypeFloat64, 13),
+			testBlockPoint(hr, datatype.TypeInt16, 17),
+			testBlockPoint(hr, datatype.TypeInt32, 8),
+			testBlockPoint(hr, datatype.TypeUint64, 4),
+		}, 0, 125, 4, 14, 6},
+		{"other register types", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(datatype.ObjTypeInputRegister, datatype.TypeUint16, 11),
+			testBlockPoint(datatype.ObjTypeCoil, datatype.TypeUint16, 11),
+			testBlockPoint(datatype.ObjTypeDiscreteInput, datatype.TypeUint16, 9),
+		}, 10, 125, 0, 0, 0},
+		{"overlapping points", testBlockPoint(hr, datatype.TypeFloat32, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeUint16, 10),
+			testBlockPoint(hr, datatype.TypeUint16, 11),
+			testBlockPoint(hr, datatype.TypeUint32, 11),
+		}, 0, 125, 10, 3, 4},
+		{"point in another point", testBlockPoint(hr, datatype.TypeUint16, 12), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeFloat64, 10),
+		}, 0, 125, 10, 4, 2},
+		{"max length", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 0, 20), 0, 4, 9, 4, 4},
+		{"max register length", testBlockPoint(hr, datatype.TypeUint16, 0), testBlockPoints(hr, 1, 300), 0, 2000, 0, 125, 125},
+		{"max register length before", testBlockPoint(hr, datatype.TypeUint16, 200), testBlockPoints(hr, 0, 200), 0, 2000, 76, 125, 125},
+		{"point over max length", testBlockPoint(hr, datatype.TypeUint16, 0), append(testBlockPoints(hr, 1, 122),
+			testBlockPoint(hr, datatype.TypeFloat64, 122), // ends at register 126
+		), 0, 125, 0, 122, 122},
+		{"input registers", testBlockPoint(datatype.ObjTypeInputRegister, datatype.TypeUint16, 0), testBlockPoints(datatype.ObjTypeInputRegister, 1, 300), 0, 2000, 0, 125, 125},
+		{"coils", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 0), testBlockPoints(datatype.ObjTypeCoil, 1, 3000), 0, 2000, 0, 2000, 2000},
+		{"coils over max length", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 0), testBlockPoints(datatype.ObjTypeCoil, 1, 3000), 0, 5000, 0, 2000, 2000},
+		{"discrete inputs", testBlockPoint(datatype.ObjTypeDiscreteInput, datatype.TypeDigital, 2500), testBlockPoints(datatype.ObjTypeDiscreteInput, 0, 2500), 0, 2000, 501, 2000, 2000},
+		{"bit gap", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 0), testBlockPoints(datatype.ObjTypeCoil, 50, 51), 49, 2000, 0, 51, 2},
+		{"bits of any data type", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeFloat64, 0), []*blockPoint{
+			testBlockPoint(datatype.ObjTypeCoil, datatype.TypeUint32, 1),
+		}, 0, 2000, 0, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := planPointBlock(tt.primary, tt.candidates, tt.maxGap, tt.maxLength)
+			checkBlock(t, block, tt.primary, tt.start, tt.length, tt.points)
+		})
+	}
+}
+
+func TestPlanPointBlockSkipsPrimary(t *testing.T) {
+	// the primary point is also queued, so it is one of the candidates
+	primary := testBlockPoint(datatype.ObjTypeHoldingRegister, datatype.TypeUint16, 10)
+	block := planPointBlock(primary, []*blockPoint{primary, testBlockPoint(datatype.ObjTypeHoldingRegister, datatype.TypeUint16, 11)}, 0, 125)
+	checkBlock(t, block, primary, 10, 2, 2)
+}
+
+func TestBlockReadMaxLengthConfig(t *testing.T) {
+	tests := []struct {
+		maxLength int
+		want      int
+	}{
+		{0, maxRegisterBlockLength},
+		{-1, maxRegisterBlockLength},
+		{10, 10},
+		{125, 125},
+		{1500, 1500},
+		{2000, maxBitBlockLength},
+		{5000, maxBitBlockLength},
+	}
+	for _, tt := range tests {
+		m := &Module{}
+		if _, err := m.ValidateAndSetConfig([]byte(fmt.Sprintf("block_read_max_length: %d\n", tt.maxLength))); err != nil {
+			t.Fatal(err)
+		}
+		if m.config.BlockReadMaxLength != tt.want {
+			t.Errorf("block_read_max_length %d = %d, want %d", tt.maxLength, m.config.BlockReadMaxLength, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+const (
+	maxRegisterWriteLength = 123  // max registers in one FC16 request
+	maxBitWriteLength      = 1968 // max coils in one FC15 request
+)
+
+// isBlockWriteable checks that the point only requires a write of a whole coil or holding register value, so it can be
+// written together with other points. Bitwise points need a read first, and string points, points that verify their
+// writes and values that are out of the range of their data type are written on their own.
+func isBlockWriteable(pnt *model.Point) bool {
+	if !boolean.IsTrue(pnt.Enable) || !IsWriteable(pnt.WriteMode) || !boolean.IsTrue(pnt.WritePollRequired) || pnt.WriteValue == nil {
+		return false
+	}
+	if _, verify := pointWriteVerification(pnt); verify || boolean.IsTrue(pnt.IsBitwise) {
+		return false
+	}
+	switch pointRegType(pnt) {
+	case smod.Coil:
+		return convertOldObjectType(nstring.NewString(pnt.ObjectType).ToSnakeCase()) == string(datatype.ObjTypeCoil)
+	case smod.HoldingRegister:
+		dataType := nstring.NewString(pnt.DataType).ToSnakeCase()
+		if smod.CheckValueRange(dataType, *pnt.WriteValue) != nil {
+			return false
+		}
+		switch dataType {
+		case string(datatype.TypeUint16), string(datatype.TypeInt16), string(datatype.TypeUint32), string(datatype.TypeInt32),
+			string(datatype.TypeUint64), string(datatype.TypeInt64), string(datatype.TypeFloat32), string(datatype.TypeFloat64),
+			string(datatype.TypeMod10U32):
+			return true
+		}
+		return smod.RegisterCount(dataType) > 0
+	}
+	return false
+}
+
+// planWriteBlock groups the primary point with the candidates of the same register type that are right next to the
+// block, without exceeding the max length of a write request. There can't be any gaps, as the request writes every
+// register (or coil) it covers. Returns nil if no other point can be written with the primary point.
+func planWriteBlock(primary *blockPoint, candidates []*blockPoint) *pointBlock {
+	regType := pointRegType(primary.pnt)
+	maxLength := maxRegisterWriteLength
+	if regType == smod.Coil {
+		maxLength = maxBitWriteLength
+	}
+
+	// the first queued point at each start and end address, so points that overlap others can't be added twice
+	byStart := make(map[int]*blockPoint)
+	byEnd := make(map[int]*blockPoint)
+	for _, bp := range candidates {
+		if bp.pnt.UUID == primary.pnt.UUID || pointRegType(bp.pnt) != regType {
+			continue
+		}
+		if _, ok := byStart[bp.address]; !ok {
+			byStart[bp.address] = bp
+		}
+		if _, ok := byEnd[bp.address+bp.length]; !ok {
+			byEnd[bp.address+bp.length] = bp
+		}
+	}
+
+	block := &pointBlock{regType: regType, start: primary.address, length: primary.length, points: []*blockPoint{primary}}
+	for {
+		added := false
+		if bp, ok := byStart[block.start+block.length]; ok && block.length+bp.length <= maxLength {
+			block.points = append(block.points, bp)
+			block.length += bp.length
+			added = true
+		}
+		if bp, ok := byEnd[block.start]; ok && block.length+bp.length <= maxLength {
+			block.points = append(block.points, bp)
+			block.start = bp.address
+			block.length += bp.length
+			added = true
+		}
+		if !added {
+			break
+		}
+	}
+
+	if len(block.points) < 2 {
+		return nil
+	}
+	return block
+}
+
+// pollPointWriteBlock writes the polling point in one request with the other queued writes of the device that are
+// next to it, eg: a bank of relay outputs. Returns false if there are no points to group with it, and it should be
+// polled on its own.
+func (m *Module) pollPointWriteBlock(netPollMan *pollqueue.NetworkPollManager, mbClient *smod.ModbusClient, dev *model.Device, pp *pollqueue.PollingPoint, pnt *model.Point, pollStartTime time.Time) bool {
+	if !isBlockWriteable(pnt) {
+		return false
+	}
+
+	candidates := make([]*blockPoint, 0)
+	for _, queuedPP := range netPollMan.PollQueue.GetQueuedPollingPointsByDeviceUUID(dev.UUID) {
+		queuedPnt, err := m.grpcMarshaller.GetPoint(queuedPP.FFPointUUID, &nmodule.Opts{Args: &nargs.Args{WithPriority: true, WithMetaTags: true}})
+		if err != nil || queuedPnt == nil || !isBlockWriteable(queuedPnt) {
+			continue
+		}
+		candidates = append(candidates, newBlockPoint(queuedPP, queuedPnt, mbClient.DeviceZeroMode))
+	}
+
+	block := planWriteBlock(newBlockPoint(pp, pnt, mbClient.DeviceZeroMode), candidates)
+	if block == nil {
+		return false
+	}
+
+	// take the grouped points out of the queue. A point removed in the meantime leaves a gap, so the block is cut at it
+	drawn := []*blockPoint{block.points[0]}
+	for _, bp := range block.points[1:] {
+		if netPollMan.PollQueue.DrawBlockPollingPoint(bp.pp.FFPointUUID) != nil {
+			drawn = append(drawn, bp)
+		}
+	}
+	block.points = drawn
+	if !block.isContiguous() {
+		m.writeBlockPointsIndividually(netPollMan, mbClient, dev, block)
+		return true
+	}
+
+	var err error
+	m.modbusPollingMsg(fmt.Sprintf("BLOCK-WRITE: device: %s, start: %d, length: %d, points: %d", dev.Name, block.start, block.length, len(block.points)))
+	if block.regType == smod.Coil {
+		values := make([]bool, block.length)
+		for _, bp := range block.points {
+			values[bp.address-block.start] = *bp.pnt.WriteValue > 0
+		}
+		_, err = mbClient.WriteCoils(uint16(block.start), values)
+	} else {
+		value := make([]byte, block.length*2)
+		for _, bp := range block.points {
+			setEncoding(mbClient, bp.pnt.ObjectEncoding)
+			dataType := nstring.NewString(bp.pnt.DataType).ToSnakeCase()
+			registers, encodeErr := mbClient.EncodeRegisters(dataType, *bp.pnt.WriteValue)
+			if encodeErr != nil { // isBlockWriteable has checked the range, but each point reports its own error
+				m.writeBlockPointsIndividually(netPollMan, mbClient, dev, block)
+				return true
+			}
+			copy(value[(bp.address-block.start)*2:], registers)
+		}
+		_, err = mbClient.WriteRegisters(uint16(block.start), value)
+	}
+	reportDevicePoll(netPollMan, dev.UUID, err)
+	if err != nil {
+		// the device may not support the multiple write function codes, so fall back to writing each point on its own
+		m.modbusPollingMsg(fmt.Sprintf("BLOCK-WRITE: failed, writing points individually. device: %s, err: %v", dev.Name, err))
+		m.writeBlockPointsIndividually(netPollMan, mbClient, dev, block)
+		return true
+	}
+
+	pollTimeSecs := time.Since(pollStartTime).Seconds() / float64(len(block.points))
+	for _, bp := range block.points {
+		value := *bp.pnt.WriteValue
+		if block.regType == smod.Coil {
+			value = 0
+			if *bp.pnt.WriteValue > 0 {
+				value = 1
+			}
+		}
+		m.blockWritePointFinished(netPollMan, bp, value, nil, pollTimeSecs)
+	}
+	return true
+}
+
+// writeBlockPointsIndividually writes each point of the block with its own request.
+func (m *Module) writeBlockPointsIndividually(netPollMan *pollqueue.NetworkPollManager, mbClient *smod.ModbusClient, dev *model.Device, block *pointBlock) {
+	for _, bp := range block.points {
+		if netPollMan.IsDeviceOffline(dev.UUID) { // don't spend the bus time on the timeouts of the remaining points
+			netPollMan.PollingPointCompleteNotification(bp.pp, bp.pnt, false, false, 0, false, true, pollqueue.DELAYED_RETRY, true)
+			continue
+		}
+		writeStartTime := time.Now()
+		_, value, writeErr := m.networkWrite(mbClient, bp.pnt)
+		reportDevicePoll(netPollMan, dev.UUID, writeErr)
+		m.blockWritePointFinished(netPollMan, bp, value, writeErr, time.Since(writeStartTime).Seconds())
+	}
+}
+
+// isContiguous checks that the points of the block cover it without gaps.
+func (b *pointBlock) isContiguous() bool {
+	covered := 0
+	for _, bp := range b.points {
+		covered += bp.length
+	}
+	return covered == b.length
+}
+
+// blockWritePointFinished updates the point with its written value, and re-adds it to the poll queue.
+// The first point of the block must be one of the CurrentPollPoints of the poll queue.
+func (m *Module) blockWritePointFinished(netPollMan *pollqueue.NetworkPollManager, bp *blockPoint, value float64, err error, pollTimeSecs float64) {
+	pnt := bp.pnt
+	if err != nil {
+		retryType := m.pointRequestFailed(pnt, err, dto.CommonFaultCode.PointWriteError)
+		netPollMan.PollingPointCompleteNotification(bp.pp, pnt, false, false, pollTimeSecs, false, true, retryType, false)
+		return
+	}
+
+	m.modbusPollingMsg(fmt.Sprintf("BLOCK-WRITE-RESPONSE: responseValue %f, point UUID: %s", value, pnt.UUID))
+	if !float.ComparePtrValues(pnt.OriginalValue, &value) {
+		updatedPnt, updateErr := m.internalPointUpdate(pnt, value)
+		if updateErr == nil && updatedPnt != nil {
+			pnt = updatedPnt
+		}
+	}
+	netPollMan.PollingPointCompleteNotification(bp.pp, pnt, true, false, pollTimeSecs, false, true, pollqueue.NORMAL_RETRY, false)
+}
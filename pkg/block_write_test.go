@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+func TestIsBlockWriteable(t *testing.T) {
+	writeable := func(objectType datatype.ObjectType, dataType string, value float64) *model.Point {
+		return &model.Point{
+			Enable:            boolean.NewTrue(),
+			ObjectType:        string(objectType),
+			DataType:          dataType,
+			AddressID:         integer.New(1),
+			WriteMode:         datatype.WriteOnce,
+			WritePollRequired: boolean.NewTrue(),
+			WriteValue:        float.New(value),
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(pnt *model.Point)
+		pnt    *model.Point
+		want   bool
+	}{
+		{"coil", nil, writeable(datatype.ObjTypeCoil, string(datatype.TypeDigital), 1), true},
+		{"uint16", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeUint16), 65535), true},
+		{"int32", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeInt32), -5), true},
+		{"float32", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeFloat32), 1.5), true},
+		{"float64", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeFloat64), -1.5), true},
+		{"bcd32", nil, writeable(datatype.ObjTypeHoldingRegister, smod.TypeBCD32, 1234), true},
+		{"string", nil, writeable(datatype.ObjTypeHoldingRegister, smod.TypeString, 0), false},
+		{"out of range", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeUint16), 65536), false},
+		{"negative unsigned", nil, writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeUint32), -1), false},
+		{"input register", nil, writeable(datatype.ObjTypeInputRegister, string(datatype.TypeUint16), 1), false},
+		{"discrete input", nil, writeable(datatype.ObjTypeDiscreteInput, string(datatype.TypeDigital), 1), false},
+		{"disabled", func(pnt *model.Point) { pnt.Enable = boolean.NewFalse() }, nil, false},
+		{"read only", func(pnt *model.Point) { pnt.WriteMode = datatype.ReadOnly }, nil, false},
+		{"no write required", func(pnt *model.Point) { pnt.WritePollRequired = boolean.NewFalse() }, nil, false},
+		{"no write value", func(pnt *model.Point) { pnt.WriteValue = nil }, nil, false},
+		{"bitwise", func(pnt *model.Point) { pnt.IsBitwise = boolean.NewTrue(); pnt.BitwiseIndex = integer.New(2) }, nil, false},
+		{"verify write", func(pnt *model.Point) {
+			pnt.MetaTags = []*model.PointMetaTag{{Key: metaTagVerifyWrite, Value: "true"}}
+		}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pnt := tt.pnt
+			if pnt == nil {
+				pnt = writeable(datatype.ObjTypeHoldingRegister, string(datatype.TypeUint16), 1)
+				tt.modify(pnt)
+			}
+			if got := isBlockWriteable(pnt); got != tt.want {
+				t.Errorf("isBlockWriteable() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanWriteBlock(t *testing.T) {
+	hr := datatype.ObjTypeHoldingRegister
+	tests := []struct {
+		name       string
+		primary    *blockPoint
+		candidates []*blockPoint
+		start      int
+		length     int
+		points     int // 0 if there is no block
+	}{
+		{"no candidates", testBlockPoint(hr, datatype.TypeUint16, 10), nil, 0, 0, 0},
+		{"adjacent", testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoints(hr, 9, 12), 9, 3, 3},
+		{"gap", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeUint16, 12),
+			testBlockPoint(hr, datatype.TypeUint16, 8),
+		}, 0, 0, 0},
+		{"stops at a gap", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeUint16, 11),
+			testBlockPoint(hr, datatype.TypeUint16, 13),
+		}, 10, 2, 2},
+		{"mixed data types", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeFloat32, 11),
+			testBlockPoint(hr, datatype.TypeFloat64, 13),
+			testBlockPoint(hr, datatype.TypeInt32, 8),
+			testBlockPoint(hr, datatype.TypeUint64, 4),
+		}, 4, 13, 5},
+		{"overlapping points", testBlockPoint(hr, datatype.TypeFloat32, 10), []*blockPoint{
+			testBlockPoint(hr, datatype.TypeUint16, 10),
+			testBlockPoint(hr, datatype.TypeUint16, 11),
+			testBlockPoint(hr, datatype.TypeUint16, 12),
+			testBlockPoint(hr, datatype.TypeUint32, 12),
+			testBlockPoint(hr, datatype.TypeUint32, 8),
+			testBlockPoint(hr, datatype.TypeUint16, 9),
+		}, 8, 5, 3},
+		{"other register types", testBlockPoint(hr, datatype.TypeUint16, 10), []*blockPoint{
+			testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 11),
+			testBlockPoint(datatype.ObjTypeInputRegister, datatype.TypeUint16, 9),
+		}, 0, 0, 0},
+		{"max register length", testBlockPoint(hr, datatype.TypeUint16, 0), testBlockPoints(hr, 1, 300), 0, 123, 123},
+		{"max register length before", testBlockPoint(hr, datatype.TypeUint16, 200), testBlockPoints(hr, 0, 200), 78, 123, 123},
+		{"point over max length", testBlockPoint(hr, datatype.TypeUint16, 0), append(testBlockPoints(hr, 1, 121),
+			testBlockPoint(hr, datatype.TypeFloat64, 121), // ends at register 125
+		), 0, 121, 121},
+		{"coils", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 0), testBlockPoints(datatype.ObjTypeCoil, 1, 3000), 0, 1968, 1968},
+		{"coils before", testBlockPoint(datatype.ObjTypeCoil, datatype.TypeDigital, 2500), testBlockPoints(datatype.ObjTypeCoil, 0, 2500), 533, 1968, 1968},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := planWriteBlock(tt.primary, tt.candidates)
+			checkBlock(t, block, tt.primary, tt.start, tt.length, tt.points)
+			if block != nil && !block.isContiguous() {
+				t.Error("block has gaps")
+			}
+		})
+	}
+}
+
+func TestPointBlockIsContiguous(t *testing.T) {
+	hr := datatype.ObjTypeHoldingRegister
+	tests := []struct {
+		name   string
+		start  int
+		length int
+		points []*blockPoint
+		want   bool
+	}{
+		{"contiguous", 10, 4, []*blockPoint{testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoint(hr, datatype.TypeFloat32, 11), testBlockPoint(hr, datatype.TypeUint16, 13)}, true},
+		{"point removed", 10, 4, []*blockPoint{testBlockPoint(hr, datatype.TypeUint16, 10), testBlockPoint(hr, datatype.TypeUint16, 13)}, false},
+		{"single point", 10, 2, []*blockPoint{testBlockPoint(hr, datatype.TypeInt32, 10)}, true},
+	}
+	for _, tt := range tests {
+		block := &pointBlock{regType: smod.HoldingRegister, start: tt.start, length: tt.length, points: tt.points}
+		if got := block.isContiguous(); got != tt.want {
+			t.Errorf("%s: isContiguous() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -8,16 +8,34 @@ import (
 )
 
 type Config struct {
-	EnablePolling     bool   `yaml:"enable_polling"`
-	LogLevel          string `yaml:"log_level"`
-	PollQueueLogLevel string `yaml:"poll_queue_log_level"`
+	EnablePolling         bool   `yaml:"enable_polling"`
+	LogLevel              string `yaml:"log_level"`
+	PollQueueLogLevel     string `yaml:"poll_queue_log_level"`
+	EnableBlockReads      bool   `yaml:"enable_block_reads"`
+	EnableBlockWrites     bool   `yaml:"enable_block_writes"`     // write queued writes of adjacent coils or holding registers in one request
+	BlockReadMaxGap       int    `yaml:"block_read_max_gap"`      // max number of unused registers (or bits) between two points read in one request
+	BlockReadMaxLength    int    `yaml:"block_read_max_length"`   // max number of registers (or bits) read in one request, up to 125 registers or 2000 bits
+	ServerWritePriority   int    `yaml:"server_write_priority"`   // priority array slot that writes from masters to tcp_server networks go to
+	DeviceProfilesDir     string `yaml:"device_profiles_dir"`     // directory of the device profile .yaml and .json files
+	MaxPollWorkers        int    `yaml:"max_poll_workers"`        // devices of a tcp network that are polled at the same time, set to 1 to poll them one by one
+	ConnectionIdleTimeout int    `yaml:"connection_idle_timeout"` // seconds before an unused tcp socket is closed, 0 keeps it open
+	TrafficCaptureDir     string `yaml:"traffic_capture_dir"`     // directory that traffic captures are exported to, as .pcap files
 }
 
 func (m *Module) DefaultConfig() *Config {
 	return &Config{
-		EnablePolling:     true,
-		LogLevel:          "ERROR",
-		PollQueueLogLevel: "ERROR",
+		EnablePolling:         true,
+		LogLevel:              "ERROR",
+		PollQueueLogLevel:     "ERROR",
+		EnableBlockReads:      true,
+		EnableBlockWrites:     true,
+		BlockReadMaxGap:       0,
+		BlockReadMaxLength:    100,
+		ServerWritePriority:   16,
+		DeviceProfilesDir:     "/data/module-core-modbus/device-profiles",
+		MaxPollWorkers:        8,
+		ConnectionIdleTimeout: 60,
+		TrafficCaptureDir:     "/data/module-core-modbus/traffic-captures",
 	}
 }
 
@@ -36,6 +54,26 @@ func (m *Module) ValidateAndSetConfig(config []byte) ([]byte, error) {
 	logger.SetLogger(logLevel)
 	newConfig.LogLevel = strings.ToUpper(logLevel.String())
 
+	if newConfig.BlockReadMaxGap < 0 {
+		newConfig.BlockReadMaxGap = 0
+	}
+	if newConfig.BlockReadMaxLength < 1 {
+		newConfig.BlockReadMaxLength = maxRegisterBlockLength
+	} else if newConfig.BlockReadMaxLength > maxBitBlockLength {
+		newConfig.BlockReadMaxLength = maxBitBlockLength // planPointBlock clamps it to the max of each register type
+	}
+	if newConfig.ServerWritePriority < 1 || newConfig.ServerWritePriority > 16 {
+		newConfig.ServerWritePriority = 16
+	}
+	if newConfig.ConnectionIdleTimeout < 0 {
+		newConfig.ConnectionIdleTimeout = 0
+	}
+	if newConfig.MaxPollWorkers < 1 {
+		newConfig.MaxPollWorkers = 1
+	} else if newConfig.MaxPollWorkers > maxPollWorkers {
+		newConfig.MaxPollWorkers = maxPollWorkers
+	}
+
 	newConfValid, err := yaml.Marshal(newConfig)
 	if err != nil {
 		return nil, err
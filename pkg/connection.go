@@ -0,0 +1,332 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/nurl"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/grid-x/modbus"
+)
+
+const (
+	connStateIdle      = "idle"      // no socket open, it connects on the next request
+	connStateConnected = "connected" // socket open
+	connStateBackoff   = "backoff"   // connecting failed, no requests are sent until the retry time
+
+	minReconnectBackoff     = time.Second
+	maxReconnectBackoff     = time.Minute
+	connectionSweepInterval = 10 * time.Second
+	connectionExpiry        = time.Hour // unused connections are forgotten after this long, eg: when a device ip changes
+)
+
+// ConnectionState is the state of the socket to a host:port.
+type ConnectionState struct {
+	Address   string     `json:"address"`
+	Transport string     `json:"transport"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"` // failed connects in a row
+	LastError string     `json:"last_error,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+}
+
+// connectionManager keeps a socket open to each host:port that devices are polled on. Devices behind the same address
+// (eg: the unit ids of a gateway) share the socket, and take turns on it.
+type connectionManager struct {
+	mutex       sync.Mutex
+	connections map[string]*tcpConnection
+	idleTimeout time.Duration // 0 keeps idle sockets open
+}
+
+// tcpConnection is the client of one host:port. The client is only used while the connection is acquired.
+type tcpConnection struct {
+	address   string
+	transport string
+	timeout   time.Duration
+	client    *smod.ModbusClient
+	closer    func() error
+	capture   *TrafficCapture // of the network of the device that acquired the connection, nil if not captured
+	mutex     sync.Mutex      // held from acquire to release, so requests of different devices don't interleave
+
+	stateMutex sync.Mutex // guards the state, which is also read by the api and the idle sweep
+	state      string
+	failures   int
+	lastError  string
+	lastUsed   time.Time
+	retryAt    time.Time
+}
+
+// connectionTransporter sends the requests of a connection, and tracks the connection state from their results.
+type connectionTransporter struct {
+	conn        *tcpConnection
+	transporter modbus.Transporter
+}
+
+func newConnectionManager(idleTimeout time.Duration) *connectionManager {
+	return &connectionManager{connections: make(map[string]*tcpConnection), idleTimeout: idleTimeout}
+}
+
+// isTCPTransport checks if the devices of the network are polled on their own host:port.
+func isTCPTransport(network *model.Network) bool {
+	return network.TransportType == dto.TransType.IP || network.TransportType == transTypeRTUOverTCP
+}
+
+func deviceAddress(device *model.Device) (string, error) {
+	url, err := nurl.JoinIPPort(nurl.Parts{Host: device.Host, Port: strconv.Itoa(device.Port)})
+	if err != nil {
+		return "", fmt.Errorf("failed to validate device address: %s, %s", url, err.Error())
+	}
+	return url, nil
+}
+
+// acquire takes the connection of the device, and sets its client to address the device, and to record its requests
+// to the traffic capture of the network. It waits while another device is using the connection. release must be called
+// when done.
+func (cm *connectionManager) acquire(network *model.Network, device *model.Device, capture *TrafficCapture) (*tcpConnection, error) {
+	address, err := deviceAddress(device)
+	if err != nil {
+		return nil, err
+	}
+	cm.mutex.Lock()
+	conn, ok := cm.connections[address]
+	if !ok {
+		conn = &tcpConnection{address: address, state: connStateIdle}
+		cm.connections[address] = conn
+	}
+	cm.mutex.Unlock()
+
+	conn.mutex.Lock()
+	timeout := time.Duration(0) // tcp keeps the handler default
+	if network.TransportType == transTypeRTUOverTCP {
+		timeout = networkTimeout(network)
+	}
+	if conn.client == nil || conn.transport != network.TransportType || conn.timeout != timeout {
+		conn.setup(network.TransportType, timeout)
+	}
+	conn.capture = capture
+	if conn.client.RTUOverTCPClientHandler != nil {
+		conn.client.RTUOverTCPClientHandler.SlaveID = byte(device.AddressId)
+	} else {
+		conn.client.TCPClientHandler.SlaveID = byte(device.AddressId)
+	}
+	return conn, nil
+}
+
+// getState returns the state of the connection of the device, or nil if it hasn't been used.
+func (cm *connectionManager) getState(device *model.Device) *ConnectionState {
+	address, err := deviceAddress(device)
+	if err != nil {
+		return nil
+	}
+	cm.mutex.Lock()
+	conn, ok := cm.connections[address]
+	cm.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return conn.getState()
+}
+
+func (cm *connectionManager) getStates() []*ConnectionState {
+	cm.mutex.Lock()
+	states := make([]*ConnectionState, 0, len(cm.connections))
+	for _, conn := range cm.connections {
+		states = append(states, conn.getState())
+	}
+	cm.mutex.Unlock()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Address < states[j].Address
+	})
+	return states
+}
+
+// run closes idle sockets until ctx is done, then closes all sockets.
+func (cm *connectionManager) run(ctx context.Context) {
+	ticker := time.NewTicker(connectionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cm.closeIdle()
+		case <-ctx.Done():
+			cm.closeAll()
+			return
+		}
+	}
+}
+
+func (cm *connectionManager) closeIdle() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	for address, conn := range cm.connections {
+		if !conn.mutex.TryLock() { // in use
+			continue
+		}
+		conn.stateMutex.Lock()
+		idle := time.Since(conn.lastUsed)
+		if conn.state == connStateConnected && cm.idleTimeout > 0 && idle >= cm.idleTimeout {
+			conn.close()
+			conn.state = connStateIdle
+		}
+		if conn.state == connStateIdle && idle >= connectionExpiry {
+			delete(cm.connections, address)
+		}
+		conn.stateMutex.Unlock()
+		conn.mutex.Unlock()
+	}
+}
+
+func (cm *connectionManager) closeAll() {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	for address, conn := range cm.connections {
+		conn.mutex.Lock()
+		conn.close()
+		conn.mutex.Unlock()
+		delete(cm.connections, address)
+	}
+}
+
+// setup builds the client of the connection. The socket is opened on the first request.
+func (c *tcpConnection) setup(transport string, timeout time.Duration) {
+	c.close()
+	c.transport = transport
+	c.timeout = timeout
+	c.client = &smod.ModbusClient{}
+	if transport == transTypeRTUOverTCP {
+		handler := modbus.NewRTUOverTCPClientHandler(c.address)
+		handler.Timeout = timeout
+		handler.IdleTimeout = 0 // idle sockets are closed by the connection manager
+		c.client.RTUOverTCPClientHandler = handler
+		c.client.Client = modbus.NewClient2(handler, &connectionTransporter{conn: c, transporter: c.captureTransporter(handler)})
+		c.closer = handler.Close
+		return
+	}
+	handler := modbus.NewTCPClientHandler(c.address)
+	if timeout > 0 {
+		handler.Timeout = timeout
+	}
+	handler.IdleTimeout = 0
+	c.client.TCPClientHandler = handler
+	c.client.Client = modbus.NewClient2(handler, &connectionTransporter{conn: c, transporter: c.captureTransporter(handler)})
+	c.closer = handler.Close
+}
+
+// captureTransporter records the requests that are sent on the socket, so requests that aren't sent while the
+// connection backs off aren't captured.
+func (c *tcpConnection) captureTransporter(transporter modbus.Transporter) *captureTransporter {
+	return &captureTransporter{
+		transporter: transporter,
+		framing:     transportFraming(c.transport),
+		capture: func() *TrafficCapture {
+			return c.capture
+		},
+	}
+}
+
+func (c *tcpConnection) release() {
+	c.mutex.Unlock()
+}
+
+func (c *tcpConnection) close() {
+	if c.closer != nil {
+		_ = c.closer()
+	}
+}
+
+func (c *tcpConnection) getState() *ConnectionState {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	state := &ConnectionState{
+		Address:   c.address,
+		Transport: c.transport,
+		State:     c.state,
+		Failures:  c.failures,
+		LastError: c.lastError,
+	}
+	if !c.lastUsed.IsZero() {
+		lastUsed := c.lastUsed
+		state.LastUsed = &lastUsed
+	}
+	if c.state == connStateBackoff {
+		retryAt := c.retryAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
+
+// checkBackoff returns an error while the connection is waiting to reconnect.
+func (c *tcpConnection) checkBackoff() error {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if c.state == connStateBackoff && time.Now().Before(c.retryAt) {
+		err := fmt.Errorf("connection to %s failed, reconnecting in %s: %s", c.address, time.Until(c.retryAt).Round(time.Second), c.lastError)
+		return &smod.RequestError{Class: smod.ErrorConnection, Err: err}
+	}
+	return nil
+}
+
+// requestFinished updates the connection state. A failed connect backs off, and any other failure closes the socket,
+// so that the next request starts on a new one (and doesn't read a late response of this request).
+func (c *tcpConnection) requestFinished(err error) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.lastUsed = time.Now()
+	if err == nil {
+		c.state = connStateConnected
+		c.failures = 0
+		c.lastError = ""
+		return
+	}
+	c.lastError = err.Error()
+	c.close()
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		c.failures++
+		backoff := minReconnectBackoff << uint(c.failures-1)
+		if backoff > maxReconnectBackoff || backoff <= 0 {
+			backoff = maxReconnectBackoff
+		}
+		c.state = connStateBackoff
+		c.retryAt = c.lastUsed.Add(backoff)
+		return
+	}
+	c.state = connStateIdle
+}
+
+func (t *connectionTransporter) Send(aduRequest []byte) ([]byte, error) {
+	if err := t.conn.checkBackoff(); err != nil {
+		return nil, err
+	}
+	aduResponse, err := t.transporter.Send(aduRequest)
+	t.conn.requestFinished(err)
+	return aduResponse, err
+}
+
+// getDeviceConnection returns the connection state of the device, or nil if it hasn't been polled yet.
+func (m *Module) getDeviceConnection(deviceUUID string) (*ConnectionState, error) {
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID)
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+	if m.connections == nil {
+		return nil, nil
+	}
+	return m.connections.getState(dev), nil
+}
+
+func (m *Module) getConnections() []*ConnectionState {
+	if m.connections == nil {
+		return []*ConnectionState{}
+	}
+	return m.connections.getStates()
+}
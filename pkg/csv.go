@@ -0,0 +1,466 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+const (
+	csvDeviceName           = "device_name"
+	csvDeviceAddressID      = "device_address_id"
+	csvDeviceHost           = "device_host"
+	csvDevicePort           = "device_port"
+	csvPointName            = "point_name"
+	csvDescription          = "description"
+	csvObjectType           = "object_type"
+	csvAddressID            = "address_id"
+	csvAddressLength        = "address_length"
+	csvDataType             = "data_type"
+	csvObjectEncoding       = "object_encoding"
+	csvWriteMode            = "write_mode"
+	csvPollPriority         = "poll_priority"
+	csvPollRate             = "poll_rate"
+	csvMultiplicationFactor = "multiplication_factor"
+	csvScaleEnable          = "scale_enable"
+	csvScaleInMin           = "scale_in_min"
+	csvScaleInMax           = "scale_in_max"
+	csvScaleOutMin          = "scale_out_min"
+	csvScaleOutMax          = "scale_out_max"
+	csvOffset               = "offset"
+	csvDecimal              = "decimal"
+	csvUnit                 = "unit"
+	csvIsBitwise            = "is_bitwise"
+	csvBitwiseIndex         = "bitwise_index"
+)
+
+// csvColumns is the column order of an export. An import finds the columns by their header, so they can be in any order.
+var csvColumns = []string{
+	csvDeviceName, csvDeviceAddressID, csvDeviceHost, csvDevicePort, csvPointName, csvDescription, csvObjectType,
+	csvAddressID, csvDataType, csvObjectEncoding, csvWriteMode, csvPollPriority, csvPollRate, csvMultiplicationFactor,
+	csvScaleEnable, csvScaleInMin, csvScaleInMax, csvScaleOutMin, csvScaleOutMax, csvOffset, csvDecimal, csvUnit,
+	csvIsBitwise, csvBitwiseIndex, csvAddressLength,
+}
+
+// CSVImportResult is the result of a register map import.
+type CSVImportResult struct {
+	DevicesCreated int            `json:"devices_created"`
+	Created        int            `json:"created"`
+	Updated        int            `json:"updated"`
+	Unchanged      int            `json:"unchanged"`
+	Errors         []*CSVRowError `json:"errors,omitempty"`
+}
+
+// CSVRowError is the reason a row of an import was skipped.
+type CSVRowError struct {
+	Row   int    `json:"row"` // line of the file, the header is row 1
+	Error string `json:"error"`
+}
+
+// csvRow is a parsed row of an import.
+type csvRow struct {
+	row             int
+	deviceName      string
+	deviceAddressID int
+	deviceHost      string
+	devicePort      int
+	point           *ProfilePoint
+	blank           map[string]bool // columns that are missing or empty
+}
+
+// exportNetworkCSV returns every point of the network, one row per point.
+func (m *Module) exportNetworkCSV(netUUID string) ([]byte, error) {
+	net, err := m.grpcMarshaller.GetNetwork(netUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
+	if err != nil || net == nil {
+		return nil, fmt.Errorf("failed to find network %s", netUUID)
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err = w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	for _, dev := range net.Devices {
+		for _, pnt := range dev.Points {
+			pp := newProfilePoint(pnt)
+			if pp.ObjectType != "" {
+				pp.ObjectType = convertOldObjectType(pp.ObjectType)
+			}
+			values := map[string]string{
+				csvDeviceName:           dev.Name,
+				csvDeviceAddressID:      strconv.Itoa(dev.AddressId),
+				csvDeviceHost:           dev.Host,
+				csvDevicePort:           formatCSVInt(dev.Port),
+				csvPointName:            pp.Name,
+				csvDescription:          pp.Description,
+				csvObjectType:           pp.ObjectType,
+				csvAddressID:            strconv.Itoa(pp.AddressID),
+				csvDataType:             pp.DataType,
+				csvObjectEncoding:       pp.ObjectEncoding,
+				csvWriteMode:            string(pp.WriteMode),
+				csvPollPriority:         string(pp.PollPriority),
+				csvPollRate:             string(pp.PollRate),
+				csvMultiplicationFactor: formatCSVFloat(pp.MultiplicationFactor),
+				csvScaleEnable:          formatCSVBool(pp.ScaleEnable),
+				csvScaleInMin:           formatCSVFloat(pp.ScaleInMin),
+				csvScaleInMax:           formatCSVFloat(pp.ScaleInMax),
+				csvScaleOutMin:          formatCSVFloat(pp.ScaleOutMin),
+				csvScaleOutMax:          formatCSVFloat(pp.ScaleOutMax),
+				csvOffset:               formatCSVFloat(pp.Offset),
+				csvIsBitwise:            formatCSVBool(pp.IsBitwise),
+			}
+			if pp.Decimal != nil {
+				values[csvDecimal] = strconv.FormatUint(uint64(*pp.Decimal), 10)
+			}
+			if pp.Unit != nil {
+				values[csvUnit] = *pp.Unit
+			}
+			if pp.BitwiseIndex != nil {
+				values[csvBitwiseIndex] = strconv.Itoa(*pp.BitwiseIndex)
+			}
+			if pp.AddressLength != nil {
+				values[csvAddressLength] = strconv.Itoa(*pp.AddressLength)
+			}
+			record := make([]string, len(csvColumns))
+			for i, column := range csvColumns {
+				record[i] = values[column]
+			}
+			if err = w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// importNetworkCSV creates or updates the points of the file. Points are matched by device name and point name, so
+// importing the same file again changes nothing. Empty columns keep the values of existing points. Rows that fail
+// validation, or don't match their existing device, are skipped and returned as errors.
+func (m *Module) importNetworkCSV(netUUID string, data []byte) (*CSVImportResult, error) {
+	net, err := m.grpcMarshaller.GetNetwork(netUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
+	if err != nil || net == nil {
+		return nil, fmt.Errorf("failed to find network %s", netUUID)
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv file")
+	} else if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[nstring.NewString(strings.TrimSpace(column)).ToSnakeCase()] = i
+	}
+	for _, column := range []string{csvDeviceName, csvPointName, csvObjectType, csvAddressID} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("missing csv column %s", column)
+		}
+	}
+
+	result := &CSVImportResult{}
+	rowErr := func(row int, err error) {
+		result.Errors = append(result.Errors, &CSVRowError{Row: row, Error: err.Error()})
+	}
+
+	// parse every row, before anything is created
+	rows := make([]*csvRow, 0)
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			rowErr(line, err)
+			continue
+		}
+		row, err := parseCSVRow(line, columns, record)
+		if err != nil {
+			rowErr(line, err)
+			continue
+		}
+		key := row.deviceName + "/" + row.point.Name
+		if first, ok := seen[key]; ok {
+			rowErr(line, fmt.Errorf("point %s of device %s is already on row %d", row.point.Name, row.deviceName, first))
+			continue
+		}
+		seen[key] = line
+		rows = append(rows, row)
+	}
+
+	devices := make(map[string]*model.Device)
+	for _, dev := range net.Devices {
+		devices[dev.Name] = dev
+	}
+	for _, row := range rows {
+		var existing *model.Point
+		dev, ok := devices[row.deviceName]
+		if ok {
+			if err = row.checkDevice(dev); err != nil {
+				rowErr(row.row, err)
+				continue
+			}
+			for _, pnt := range dev.Points {
+				if pnt.Name == row.point.Name {
+					existing = pnt
+					break
+				}
+			}
+		}
+		if existing != nil {
+			row.keepBlankColumns(newProfilePoint(existing))
+		}
+		if err = validateCSVPoint(row.point); err != nil {
+			rowErr(row.row, err)
+			continue
+		}
+
+		if !ok {
+			dev, err = m.addDevice(&model.Device{
+				Name:        row.deviceName,
+				NetworkUUID: net.UUID,
+				Enable:      boolean.NewTrue(),
+				AddressId:   row.deviceAddressID,
+				Host:        row.deviceHost,
+				Port:        row.devicePort,
+			})
+			if err != nil {
+				rowErr(row.row, fmt.Errorf("failed to create device %s: %v", row.deviceName, err))
+				continue
+			}
+			devices[dev.Name] = dev
+			result.DevicesCreated++
+		}
+
+		if existing == nil {
+			if _, err = m.addPoint(row.point.toPoint(dev.UUID)); err != nil {
+				rowErr(row.row, err)
+				continue
+			}
+			result.Created++
+			continue
+		}
+		if reflect.DeepEqual(newProfilePoint(existing), row.point) {
+			result.Unchanged++
+			continue
+		}
+		row.point.applyTo(existing)
+		if _, err = m.updatePoint(existing.UUID, existing); err != nil {
+			rowErr(row.row, err)
+			continue
+		}
+		result.Updated++
+	}
+	return result, nil
+}
+
+// parseCSVRow parses a row of an import. Its point is checked against the rules of addPoint once the values of the
+// existing point are kept for its empty columns.
+func parseCSVRow(line int, columns map[string]int, record []string) (*csvRow, error) {
+	get := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &csvRow{row: line, deviceName: get(csvDeviceName), deviceHost: get(csvDeviceHost), deviceAddressID: 1, blank: make(map[string]bool)}
+	for _, column := range csvColumns {
+		if get(column) == "" {
+			row.blank[column] = true
+		}
+	}
+	if row.deviceName == "" {
+		return nil, errors.New("device_name is required")
+	}
+	var err error
+	if v := get(csvDeviceAddressID); v != "" {
+		if row.deviceAddressID, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvDeviceAddressID, v)
+		}
+	}
+	if v := get(csvDevicePort); v != "" {
+		if row.devicePort, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvDevicePort, v)
+		}
+	}
+
+	pp := &ProfilePoint{
+		Name:           get(csvPointName),
+		Description:    get(csvDescription),
+		ObjectType:     nstring.NewString(get(csvObjectType)).ToSnakeCase(),
+		DataType:       get(csvDataType),
+		ObjectEncoding: get(csvObjectEncoding),
+		WriteMode:      datatype.WriteMode(get(csvWriteMode)),
+		PollPriority:   datatype.PollPriority(get(csvPollPriority)),
+		PollRate:       datatype.PollRate(get(csvPollRate)),
+	}
+	if pp.Name == "" {
+		return nil, errors.New("point_name is required")
+	}
+	switch pp.ObjectType {
+	case string(datatype.ObjTypeCoil), string(datatype.ObjTypeDiscreteInput), string(datatype.ObjTypeInputRegister), string(datatype.ObjTypeHoldingRegister):
+	default:
+		return nil, fmt.Errorf("invalid object_type %s", get(csvObjectType))
+	}
+	if v := get(csvAddressID); v != "" {
+		if pp.AddressID, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvAddressID, v)
+		}
+	}
+
+	floats := map[string]**float64{
+		csvMultiplicationFactor: &pp.MultiplicationFactor,
+		csvScaleInMin:           &pp.ScaleInMin,
+		csvScaleInMax:           &pp.ScaleInMax,
+		csvScaleOutMin:          &pp.ScaleOutMin,
+		csvScaleOutMax:          &pp.ScaleOutMax,
+		csvOffset:               &pp.Offset,
+	}
+	for column, field := range floats {
+		if v := get(column); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s", column, v)
+			}
+			*field = &f
+		}
+	}
+	bools := map[string]**bool{
+		csvScaleEnable: &pp.ScaleEnable,
+		csvIsBitwise:   &pp.IsBitwise,
+	}
+	for column, field := range bools {
+		if v := get(column); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s", column, v)
+			}
+			*field = &b
+		}
+	}
+	if v := get(csvDecimal); v != "" {
+		d, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvDecimal, v)
+		}
+		decimal := uint32(d)
+		pp.Decimal = &decimal
+	}
+	if v := get(csvUnit); v != "" {
+		pp.Unit = &v
+	}
+	if v := get(csvBitwiseIndex); v != "" {
+		index, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvBitwiseIndex, v)
+		}
+		pp.BitwiseIndex = &index
+	}
+	if v := get(csvAddressLength); v != "" {
+		length, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvAddressLength, v)
+		}
+		pp.AddressLength = &length
+	}
+
+	row.point = pp
+	return row, nil
+}
+
+// validateCSVPoint checks the point of a row against the rules of addPoint.
+func validateCSVPoint(pp *ProfilePoint) error {
+	if IsWriteable(pp.WriteMode) && !isWriteableObjectType(pp.ObjectType) {
+		return fmt.Errorf("write_mode %s is not allowed, object type %s is not writeable", pp.WriteMode, pp.ObjectType)
+	}
+	return validatePoint(pp.toPoint(""))
+}
+
+// checkDevice checks that the device columns of the row match the existing device, as an import doesn't update devices.
+// Empty columns match any device.
+func (row *csvRow) checkDevice(dev *model.Device) error {
+	if !row.blank[csvDeviceAddressID] && row.deviceAddressID != dev.AddressId {
+		return fmt.Errorf("%s %d doesn't match address %d of device %s", csvDeviceAddressID, row.deviceAddressID, dev.AddressId, dev.Name)
+	}
+	if !row.blank[csvDeviceHost] && row.deviceHost != dev.Host {
+		return fmt.Errorf("%s %s doesn't match host %s of device %s", csvDeviceHost, row.deviceHost, dev.Host, dev.Name)
+	}
+	if !row.blank[csvDevicePort] && row.devicePort != dev.Port {
+		return fmt.Errorf("%s %d doesn't match port %d of device %s", csvDevicePort, row.devicePort, dev.Port, dev.Name)
+	}
+	return nil
+}
+
+// keepBlankColumns keeps the values of the existing point for the columns that are missing or empty in the row, so
+// that importing a file with fewer columns, eg: an older export, doesn't clear them.
+func (row *csvRow) keepBlankColumns(existing *ProfilePoint) {
+	pp := row.point
+	keep := map[string]func(){
+		csvDescription:          func() { pp.Description = existing.Description },
+		csvAddressID:            func() { pp.AddressID = existing.AddressID },
+		csvAddressLength:        func() { pp.AddressLength = existing.AddressLength },
+		csvDataType:             func() { pp.DataType = existing.DataType },
+		csvObjectEncoding:       func() { pp.ObjectEncoding = existing.ObjectEncoding },
+		csvWriteMode:            func() { pp.WriteMode = existing.WriteMode },
+		csvPollPriority:         func() { pp.PollPriority = existing.PollPriority },
+		csvPollRate:             func() { pp.PollRate = existing.PollRate },
+		csvMultiplicationFactor: func() { pp.MultiplicationFactor = existing.MultiplicationFactor },
+		csvScaleEnable:          func() { pp.ScaleEnable = existing.ScaleEnable },
+		csvScaleInMin:           func() { pp.ScaleInMin = existing.ScaleInMin },
+		csvScaleInMax:           func() { pp.ScaleInMax = existing.ScaleInMax },
+		csvScaleOutMin:          func() { pp.ScaleOutMin = existing.ScaleOutMin },
+		csvScaleOutMax:          func() { pp.ScaleOutMax = existing.ScaleOutMax },
+		csvOffset:               func() { pp.Offset = existing.Offset },
+		csvDecimal:              func() { pp.Decimal = existing.Decimal },
+		csvUnit:                 func() { pp.Unit = existing.Unit },
+		csvIsBitwise:            func() { pp.IsBitwise = existing.IsBitwise },
+		csvBitwiseIndex:         func() { pp.BitwiseIndex = existing.BitwiseIndex },
+	}
+	for column, keepValue := range keep {
+		if row.blank[column] {
+			keepValue()
+		}
+	}
+}
+
+func formatCSVInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+func formatCSVFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatCSVBool(v *bool) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v)
+}
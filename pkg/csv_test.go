@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/module-core-modbus/simulator"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+// importCSV imports the csv into the network, with {host} and {port} in it replaced by the address of its device.
+func (tn *testNetwork) importCSV(data string) *CSVImportResult {
+	tn.t.Helper()
+	data = strings.NewReplacer("{host}", tn.device.Host, "{port}", strconv.Itoa(tn.device.Port)).Replace(data)
+	result, err := tn.module.importNetworkCSV(tn.network.UUID, []byte(data))
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	return result
+}
+
+// checkImport checks the counts of an import, and that it has the errors of the rows.
+func checkImport(t *testing.T, result *CSVImportResult, created, updated, unchanged int, errorRows ...int) {
+	t.Helper()
+	if result.Created != created || result.Updated != updated || result.Unchanged != unchanged {
+		t.Errorf("import created %d, updated %d, unchanged %d, want %d, %d, %d", result.Created, result.Updated, result.Unchanged, created, updated, unchanged)
+	}
+	rows := make([]int, 0, len(result.Errors))
+	for _, rowErr := range result.Errors {
+		rows = append(rows, rowErr.Row)
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(append([]int{}, errorRows...)) {
+		t.Errorf("import errors of rows %v, want %v", rows, errorRows)
+		for _, rowErr := range result.Errors {
+			t.Logf("row %d: %s", rowErr.Row, rowErr.Error)
+		}
+	}
+}
+
+// devicePoint returns the point of the device by its name.
+func (tn *testNetwork) devicePoint(deviceName, pointName string) *model.Point {
+	tn.t.Helper()
+	for _, dev := range tn.devices() {
+		if dev.Name != deviceName {
+			continue
+		}
+		for _, pnt := range dev.Points {
+			if pnt.Name == pointName {
+				return pnt
+			}
+		}
+	}
+	tn.t.Fatalf("point %s of device %s not found", pointName, deviceName)
+	return nil
+}
+
+func (tn *testNetwork) devices() []*model.Device {
+	net, err := tn.marshaller.GetNetwork(tn.network.UUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	return net.Devices
+}
+
+func TestImportNetworkCSVKeepsEmptyColumns(t *testing.T) {
+	tn := newTCPNetwork(t)
+	checkImport(t, tn.importCSV(
+		"device_name,device_host,device_port,point_name,object_type,address_id,data_type,write_mode,multiplication_factor,offset,decimal,unit,object_encoding,poll_rate,description\n"+
+			"device,{host},{port},temp,holding_register,1,float32,read_only,0.1,-2.5,1,C,beb_lew,slow,inside\n",
+	), 1, 0, 0)
+
+	// an older export without most columns, and a file with empty columns, only change the columns they have values of
+	checkImport(t, tn.importCSV(
+		"device_name,point_name,object_type,address_id,description\n"+
+			"device,temp,holding_register,1,outside\n",
+	), 0, 1, 0)
+	checkImport(t, tn.importCSV(
+		"device_name,device_host,device_port,point_name,object_type,address_id,data_type,write_mode,multiplication_factor,offset,decimal,unit,object_encoding,poll_rate,description\n"+
+			"device,,,temp,holding_register,3,,,,,,,,,\n",
+	), 0, 1, 0)
+
+	pnt := tn.devicePoint("device", "temp")
+	if pnt.AddressID == nil || *pnt.AddressID != 3 || pnt.DataType != "float32" || pnt.WriteMode != "read_only" ||
+		float.NonNil(pnt.MultiplicationFactor) != 0.1 || float.NonNil(pnt.Offset) != -2.5 || pnt.Decimal == nil || *pnt.Decimal != 1 ||
+		pnt.Unit == nil || *pnt.Unit != "C" || pnt.ObjectEncoding != "beb_lew" || pnt.PollRate != "slow" || pnt.Description != "outside" {
+		t.Errorf("import changed the columns it doesn't have: %+v", newProfilePoint(pnt))
+	}
+
+	// an import of the same values changes nothing
+	checkImport(t, tn.importCSV(
+		"device_name,point_name,object_type,address_id,unit\n"+
+			"device,temp,holding_register,3,C\n",
+	), 0, 0, 1)
+}
+
+func TestImportNetworkCSVDeviceMismatch(t *testing.T) {
+	tn := newTCPNetwork(t)
+	checkImport(t, tn.importCSV(
+		"device_name,device_address_id,device_host,device_port,point_name,object_type,address_id\n"+
+			"device,2,{host},{port},address,holding_register,1\n"+
+			"device,1,10.0.0.1,{port},host,holding_register,2\n"+
+			"device,1,{host},1,port,holding_register,3\n"+
+			"device,1,{host},{port},match,holding_register,4\n"+
+			"device,,,,blank,holding_register,5\n",
+	), 2, 0, 0, 2, 3, 4)
+
+	names := make([]string, 0)
+	for _, dev := range tn.devices() {
+		if dev.Name != "device" {
+			t.Errorf("import created device %s", dev.Name)
+		}
+		if dev.AddressId != testUnitId || dev.Host != tn.device.Host || dev.Port != tn.device.Port {
+			t.Errorf("import changed the device to address %d, %s:%d", dev.AddressId, dev.Host, dev.Port)
+		}
+		for _, pnt := range dev.Points {
+			names = append(names, pnt.Name)
+		}
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "blank,match" {
+		t.Errorf("points of the device = %v, want the points of the matching rows", names)
+	}
+}
+
+func TestNetworkCSVRoundTrip(t *testing.T) {
+	tn := newTCPNetwork(t)
+	checkImport(t, tn.importCSV(
+		"device_name,device_host,device_port,point_name,object_type,address_id,data_type,object_encoding,write_mode,poll_priority,poll_rate,"+
+			"multiplication_factor,scale_enable,scale_in_min,scale_in_max,scale_out_min,scale_out_max,offset,decimal,unit,is_bitwise,bitwise_index,address_length,description\n"+
+			"device,{host},{port},temp,holding_register,1,float32,beb_lew,read_only,high,slow,0.1,,,,,,-2.5,1,C,,,,\"outside, north\"\n"+
+			"device,{host},{port},mode,holding_register,3,uint16,,write_once,,,,,,,,,,,,true,4,,\n"+
+			"device,{host},{port},level,input_register,5,int16,,read_only,,,,true,0,1000,0,100,,,%,,,,\n"+
+			"device,{host},{port},serial,holding_register,10,string,,read_only,low,,,,,,,,,,,,,8,\n"+
+			"device,{host},{port},relay,coil,1,,,write_always,,fast,,,,,,,,,,,,,\n"+
+			"meter,{host},{port},energy,input_register,1,uint32,leb_lew,read_only,,normal,,,,,,,,,kWh,,,,\n",
+	), 6, 0, 0)
+
+	exported, err := tn.module.exportNetworkCSV(tn.network.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an import of an export changes nothing
+	result, err := tn.module.importNetworkCSV(tn.network.UUID, exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImport(t, result, 0, 0, 6)
+
+	// an import of an export into another network creates the same devices and points
+	other := newTestNetwork(t, simulator.New(), &model.Network{TransportType: dto.TransType.IP})
+	result, err = other.module.importNetworkCSV(other.network.UUID, exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImport(t, result, 6, 0, 0)
+	if result.DevicesCreated != 2 {
+		t.Errorf("import created %d devices, want 2", result.DevicesCreated)
+	}
+	reexported, err := other.module.exportNetworkCSV(other.network.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedLines(reexported), sortedLines(exported); got != want {
+		t.Errorf("export of the imported network =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// sortedLines sorts the rows of a csv, as the points are exported in any order.
+func sortedLines(data []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(lines[1:])
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/grid-x/modbus"
+)
+
+// OperationResponse is the result of an ad-hoc read or write on a network.
+type OperationResponse struct {
+	Raw       string  `json:"raw"`                 // response data, hex bytes
+	Value     float64 `json:"value"`               // decoded value
+	Exception string  `json:"exception,omitempty"` // modbus exception returned by the device
+	Error     string  `json:"error,omitempty"`     // any other failure, eg: timeout
+}
+
+// operationObjectType returns the object type of the operation, which defaults to holding registers.
+func operationObjectType(op *Operation) string {
+	if op.ObjectType != "" {
+		return convertOldObjectType(nstring.NewString(op.ObjectType).ToSnakeCase())
+	} else if op.IsCoil {
+		return string(datatype.ObjTypeCoil)
+	}
+	return string(datatype.ObjTypeHoldingRegister)
+}
+
+// networkOperation runs a single read or write on the network, without a point. It shares the network client with the
+// polling, and runs between polls.
+func (m *Module) networkOperation(netUUID string, body *Body, doWrite bool) (*OperationResponse, error) {
+	if body == nil {
+		return nil, errors.New("empty body")
+	}
+	net, err := m.grpcMarshaller.GetNetwork(netUUID)
+	if err != nil || net == nil {
+		return nil, fmt.Errorf("failed to find network %s", netUUID)
+	}
+	if isServerNetwork(net) {
+		return nil, errors.New("ad-hoc requests are not supported on tcp_server networks")
+	}
+
+	op := body.Operation
+	objectType := operationObjectType(&op)
+	if doWrite && !isWriteableObjectType(objectType) {
+		return nil, fmt.Errorf("object type %s is not writeable", objectType)
+	}
+	if op.Length == 0 {
+		op.Length = 1
+	}
+	dataType := op.DataType
+	if dataType == "" {
+		dataType = string(datatype.TypeUint16)
+	}
+
+	port := 502
+	if body.Client.Port != "" {
+		port, err = strconv.Atoi(body.Client.Port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s", body.Client.Port)
+		}
+	}
+	dev := &model.Device{Name: "ad-hoc", AddressId: int(op.UnitId), Host: body.Client.Host, Port: port}
+
+	// the operation addresses registers from 0 when zero mode is set, and from 1 (like points) otherwise
+	address := int(op.Addr)
+	writeValue := op.WriteValue
+	pnt := &model.Point{
+		Name:           "ad-hoc",
+		ObjectType:     objectType,
+		ObjectEncoding: op.Encoding,
+		DataType:       dataType,
+		AddressID:      &address,
+		AddressLength:  integer.New(int(op.Length)),
+		WriteValue:     &writeValue,
+	}
+
+	var response interface{}
+	var value float64
+	var requestErr error
+	err = m.runOnNetwork(net, dev, func(mbClient *smod.ModbusClient) {
+		// the network client is shared with the polling, so its settings are put back when done
+		zeroMode := mbClient.DeviceZeroMode
+		mbClient.DeviceZeroMode = op.ZeroMode
+		defer func() {
+			mbClient.DeviceZeroMode = zeroMode
+		}()
+
+		m.modbusDebugMsg(fmt.Sprintf("networkOperation(): network: %s, unit: %d, object type: %s, addr: %d, length: %d, write: %t", net.Name, op.UnitId, objectType, op.Addr, op.Length, doWrite))
+		response, value, requestErr = m.networkRequest(mbClient, pnt, doWrite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &OperationResponse{Value: value}
+	switch raw := response.(type) {
+	case nil:
+	case []byte:
+		result.Raw = fmt.Sprintf("% x", raw)
+	default:
+		result.Raw = fmt.Sprint(raw)
+	}
+	if requestErr != nil {
+		var mbErr *modbus.Error
+		if errors.As(requestErr, &mbErr) {
+			result.Exception = mbErr.Error()
+		} else {
+			result.Error = requestErr.Error()
+		}
+	} else if response == nil {
+		result.Error = fmt.Sprintf("data type %s is not supported for %s", dataType, objectType)
+	}
+	return result, nil
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"time"
 
 	"github.com/NubeIO/module-core-modbus/pollqueue"
 	"github.com/NubeIO/module-core-modbus/smod"
@@ -19,6 +20,13 @@ func (m *Module) Enable() error {
 
 	m.pollingContext, m.pollingCancel = context.WithCancel(context.Background())
 
+	m.servers = make(map[string]*modbusServer)
+	for _, net := range nets {
+		if isServerNetwork(net) {
+			m.initiateServer(m.pollingContext, net)
+		}
+	}
+
 	if m.config.EnablePolling {
 		for _, pm := range m.NetworkPollManagers {
 			pm.StopPolling()
@@ -29,9 +37,13 @@ func (m *Module) Enable() error {
 		}
 		m.NetworkPollManagers = make([]*pollqueue.NetworkPollManager, 0, len(nets))
 		m.mbClients = make(map[string]*smod.ModbusClient, len(nets))
+		m.connections = newConnectionManager(time.Duration(m.config.ConnectionIdleTimeout) * time.Second)
+		go m.connections.run(m.pollingContext)
 
 		for _, net := range nets {
-			m.initiatePolling(m.pollingContext, net)
+			if !isServerNetwork(net) {
+				m.initiatePolling(m.pollingContext, net)
+			}
 		}
 	}
 	return nil
@@ -46,5 +58,7 @@ func (m *Module) Disable() error {
 	}
 	m.NetworkPollManagers = nil
 	m.mbClients = nil
+	m.connections = nil // its sockets are closed as the polling context is cancelled
+	m.stopServers()
 	return nil
 }
@@ -0,0 +1,222 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+var metricsPriorities = []datatype.PollPriority{datatype.PriorityASAP, datatype.PriorityHigh, datatype.PriorityNormal, datatype.PriorityLow}
+
+// networkMetrics are the metrics of a network, with the names of its devices for the labels.
+type networkMetrics struct {
+	*pollqueue.PollMetrics
+	deviceNames map[string]string
+}
+
+// metricsWriter writes metrics in the OpenMetrics text format. The samples of a metric family must be written right
+// after its family line.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *metricsWriter) family(name, metricType, help string) {
+	fmt.Fprintf(&w.buf, "# TYPE %s %s\n# HELP %s %s\n", name, metricType, name, help)
+}
+
+// sample writes a sample, with labels as name and value pairs.
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, `%s="%s"`, labels[i], escapeLabelValue(labels[i+1]))
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatMetricValue(value))
+	w.buf.WriteByte('\n')
+}
+
+// histogram writes the cumulative buckets, count and sum of the histogram.
+func (w *metricsWriter) histogram(name string, histogram pollqueue.Histogram, labels ...string) {
+	var cumulative uint64
+	for i, count := range histogram.Counts {
+		cumulative += count
+		le := math.Inf(1)
+		if i < len(histogram.Buckets) {
+			le = histogram.Buckets[i]
+		}
+		w.sample(name+"_bucket", float64(cumulative), append(labels, "le", formatMetricValue(le))...)
+	}
+	w.sample(name+"_count", float64(histogram.Count), labels...)
+	w.sample(name+"_sum", histogram.Sum, labels...)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func priorityLabel(priority datatype.PollPriority) string {
+	return strings.ToLower(string(priority))
+}
+
+// getMetrics returns the polling and bus health metrics of the polled networks, in the OpenMetrics text format, eg: for
+// Prometheus to scrape.
+func (m *Module) getMetrics() []byte {
+	networks := make([]*networkMetrics, 0, len(m.NetworkPollManagers))
+	for _, netPollMan := range m.NetworkPollManagers {
+		if netPollMan == nil {
+			continue
+		}
+		metrics := &networkMetrics{PollMetrics: netPollMan.GetPollMetrics(), deviceNames: make(map[string]string)}
+		network, err := m.grpcMarshaller.GetNetwork(metrics.NetworkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true}})
+		if err == nil && network != nil {
+			for _, device := range network.Devices {
+				metrics.deviceNames[device.UUID] = device.Name
+			}
+		}
+		sort.Slice(metrics.Devices, func(i, j int) bool {
+			return metrics.Devices[i].DeviceUUID < metrics.Devices[j].DeviceUUID
+		})
+		networks = append(networks, metrics)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].NetworkName < networks[j].NetworkName
+	})
+
+	w := &metricsWriter{}
+	networkLabels := func(net *networkMetrics) []string {
+		return []string{"network", net.NetworkName, "network_uuid", net.NetworkUUID}
+	}
+	deviceLabels := func(net *networkMetrics, dev *pollqueue.DeviceMetrics) []string {
+		return append(networkLabels(net), "device", net.deviceNames[dev.DeviceUUID], "device_uuid", dev.DeviceUUID)
+	}
+	priorityStats := func(stats *pollqueue.PollStatistics, priority datatype.PollPriority) (count, queueLength int64, averagePollTime float64, lockupAlert bool) {
+		switch priority {
+		case datatype.PriorityASAP:
+			return stats.ASAPPriorityPollCount, stats.ASAPPriorityPollQueueLength, stats.ASAPPriorityAveragePollTime, stats.ASAPPriorityLockupAlert
+		case datatype.PriorityHigh:
+			return stats.HighPriorityPollCount, stats.HighPriorityPollQueueLength, stats.HighPriorityAveragePollTime, stats.HighPriorityLockupAlert
+		case datatype.PriorityNormal:
+			return stats.NormalPriorityPollCount, stats.NormalPriorityPollQueueLength, stats.NormalPriorityAveragePollTime, stats.NormalPriorityLockupAlert
+		}
+		return stats.LowPriorityPollCount, stats.LowPriorityPollQueueLength, stats.LowPriorityAveragePollTime, stats.LowPriorityLockupAlert
+	}
+
+	// network metrics
+	w.family("modbus_network_polling_enabled", "gauge", "Whether polling of the network is running.")
+	for _, net := range networks {
+		w.sample("modbus_network_polling_enabled", boolMetric(net.Enable), networkLabels(net)...)
+	}
+	w.family("modbus_network_polls", "counter", "Completed polls of the network, by poll priority.")
+	for _, net := range networks {
+		for _, priority := range metricsPriorities {
+			count, _, _, _ := priorityStats(&net.Statistics, priority)
+			w.sample("modbus_network_polls_total", float64(count), append(networkLabels(net), "priority", priorityLabel(priority))...)
+		}
+	}
+	w.family("modbus_network_poll_execute_seconds", "histogram", "Time to execute a poll of the network, without the time in the queue.")
+	for _, net := range networks {
+		w.histogram("modbus_network_poll_execute_seconds", net.PollExecuteTime, networkLabels(net)...)
+	}
+	w.family("modbus_network_poll_cycle_seconds", "gauge", "Average time from a point entering the queue to its poll completing, by poll priority.")
+	for _, net := range networks {
+		for _, priority := range metricsPriorities {
+			_, _, averagePollTime, _ := priorityStats(&net.Statistics, priority)
+			w.sample("modbus_network_poll_cycle_seconds", averagePollTime, append(networkLabels(net), "priority", priorityLabel(priority))...)
+		}
+	}
+	w.family("modbus_network_busy_ratio", "gauge", "Fraction of the time that the network is polling.")
+	for _, net := range networks {
+		w.sample("modbus_network_busy_ratio", net.Statistics.BusyTime/100, networkLabels(net)...)
+	}
+	w.family("modbus_network_queue_points", "gauge", "Points in the poll queue by poll priority, in the standby list, and out for polling.")
+	for _, net := range networks {
+		for _, priority := range metricsPriorities {
+			_, queueLength, _, _ := priorityStats(&net.Statistics, priority)
+			w.sample("modbus_network_queue_points", float64(queueLength), append(networkLabels(net), "queue", priorityLabel(priority))...)
+		}
+		w.sample("modbus_network_queue_points", float64(net.Statistics.TotalStandbyPointsLength), append(networkLabels(net), "queue", "standby")...)
+		w.sample("modbus_network_queue_points", float64(net.Statistics.TotalPointsOutForPolling), append(networkLabels(net), "queue", "out_for_polling")...)
+	}
+	w.family("modbus_network_lockup_alert", "gauge", "Whether a poll of the priority took longer than its max cycle time.")
+	for _, net := range networks {
+		for _, priority := range metricsPriorities {
+			_, _, _, lockupAlert := priorityStats(&net.Statistics, priority)
+			w.sample("modbus_network_lockup_alert", boolMetric(lockupAlert), append(networkLabels(net), "priority", priorityLabel(priority))...)
+		}
+	}
+	w.family("modbus_network_enabled_seconds", "counter", "Time that the polling statistics of the network have been running for.")
+	for _, net := range networks {
+		w.sample("modbus_network_enabled_seconds_total", net.Statistics.EnabledTime, networkLabels(net)...)
+	}
+	w.family("modbus_network_port_unavailable_seconds", "counter", "Time that the serial port of the network has been unavailable.")
+	for _, net := range networks {
+		w.sample("modbus_network_port_unavailable_seconds_total", net.Statistics.PortUnavailableTime, networkLabels(net)...)
+	}
+
+	// device metrics
+	w.family("modbus_device_polls", "counter", "Completed polls of the points of the device, by poll priority.")
+	for _, net := range networks {
+		for _, dev := range net.Devices {
+			for _, priority := range metricsPriorities {
+				w.sample("modbus_device_polls_total", float64(dev.PollCounts[priority]), append(deviceLabels(net, dev), "priority", priorityLabel(priority))...)
+			}
+		}
+	}
+	w.family("modbus_device_poll_execute_seconds", "histogram", "Time to execute a poll of a point of the device, without the time in the queue.")
+	for _, net := range networks {
+		for _, dev := range net.Devices {
+			w.histogram("modbus_device_poll_execute_seconds", dev.PollExecuteTime, deviceLabels(net, dev)...)
+		}
+	}
+	deviceCounters := []struct {
+		name  string
+		help  string
+		value func(dev *pollqueue.DeviceMetrics) int64
+	}{
+		{"modbus_device_requests", "Requests sent to the device, a block read or write is one request.", func(dev *pollqueue.DeviceMetrics) int64 { return dev.Requests }},
+		{"modbus_device_timeouts", "Requests to the device that got no response.", func(dev *pollqueue.DeviceMetrics) int64 { return dev.Timeouts }},
+		{"modbus_device_exceptions", "Requests to the device that got an exception response.", func(dev *pollqueue.DeviceMetrics) int64 { return dev.Exceptions }},
+		{"modbus_device_crc_errors", "Requests to the device that got a corrupted response.", func(dev *pollqueue.DeviceMetrics) int64 { return dev.CRCErrors }},
+		{"modbus_device_request_errors", "Requests to the device that failed with any other error, eg: the connection failed.", func(dev *pollqueue.DeviceMetrics) int64 { return dev.FailedRequests }},
+	}
+	for _, counter := range deviceCounters {
+		w.family(counter.name, "counter", counter.help)
+		for _, net := range networks {
+			for _, dev := range net.Devices {
+				w.sample(counter.name+"_total", float64(counter.value(dev)), deviceLabels(net, dev)...)
+			}
+		}
+	}
+
+	w.buf.WriteString("# EOF\n")
+	return w.buf.Bytes()
+}
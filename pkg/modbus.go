@@ -1,18 +1,25 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/NubeIO/module-core-modbus/pollqueue"
 	"github.com/NubeIO/module-core-modbus/smod"
 	"github.com/NubeIO/nubeio-rubix-lib-helpers-go/pkg/nils"
-	"github.com/NubeIO/nubeio-rubix-lib-helpers-go/pkg/uurl"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
 	"github.com/grid-x/modbus"
 )
 
+// transport types of this module, in addition to dto.TransType
+const (
+	transTypeRTUOverTCP = "rtu_over_tcp" // RTU frames (with CRC) over a raw TCP socket, eg: serial to ethernet converters
+	transTypeASCII      = "ascii"        // modbus ASCII frames (with LRC) over a serial port
+	transTypeTCPServer  = "tcp_server"   // network that serves its points to other modbus masters
+)
+
 type Client struct {
 	Host       string        `json:"ip"`
 	Port       string        `json:"port"`
@@ -27,14 +34,10 @@ type Client struct {
 func (m *Module) createMbClient(netPollMan *pollqueue.NetworkPollManager, net *model.Network, dev *model.Device) (*smod.ModbusClient, error) {
 	mbClient, err := m.setClient(net, dev, true)
 	if err != nil {
-		// TODO: why this null pointer exception code is here
-		// if mbClient.PortUnavailable {
-		// 	netPollMan.PortUnavailable()
-		// 	unpauseFunc := func() {
-		// 		netPollMan.PortAvailable()
-		// 	}
-		// 	netPollMan.PortUnavailableTimeout = time.AfterFunc(10*time.Second, unpauseFunc)
-		// }
+		if requestErr := smod.ClassifyError(err); requestErr.Class == smod.ErrorPortUnavailable {
+			m.serialPortFailed(netPollMan, net, err)
+			return nil, err
+		}
 		m.updateNetworkMessage(net, "", err, netPollMan.PollCounter)
 		return nil, err
 	}
@@ -42,18 +45,95 @@ func (m *Module) createMbClient(netPollMan *pollqueue.NetworkPollManager, net *m
 	return mbClient, nil
 }
 
+// isSerialTransport checks if the network polls its devices over a serial port.
+func isSerialTransport(network *model.Network) bool {
+	switch network.TransportType {
+	case dto.TransType.Serial, dto.TransType.LoRa, transTypeASCII:
+		return true
+	}
+	return false
+}
+
+func networkTimeout(network *model.Network) time.Duration {
+	timeout := 2 * time.Second
+	if network.SerialTimeout != nil {
+		timeoutSecs := int64(nils.IntIsNil(network.SerialTimeout))
+		if timeoutSecs > 0 {
+			timeout = time.Duration(timeoutSecs) * time.Second
+		}
+	}
+	return timeout
+}
+
+// setClientDevice sets the network client to address the device, by its unit id.
+func setClientDevice(mbClient *smod.ModbusClient, network *model.Network, device *model.Device) error {
+	switch network.TransportType {
+	case dto.TransType.Serial, dto.TransType.LoRa:
+		mbClient.RTUClientHandler.SlaveID = byte(device.AddressId)
+	case transTypeASCII:
+		mbClient.ASCIIClientHandler.SlaveID = byte(device.AddressId)
+	default:
+		return fmt.Errorf("invalid network transport type: %s, net: %s", network.TransportType, network.Name)
+	}
+	return nil
+}
+
+// runOnNetwork runs fn with the network client set to address the device. Polls of the network (or on tcp networks,
+// of the devices on the same host:port) wait until it is done.
+func (m *Module) runOnNetwork(network *model.Network, device *model.Device, fn func(mbClient *smod.ModbusClient)) error {
+	if isTCPTransport(network) {
+		if m.connections == nil {
+			return errors.New("module is not enabled")
+		}
+		conn, err := m.connections.acquire(network, device, m.getTrafficCapture(network.UUID))
+		if err != nil {
+			return err
+		}
+		defer conn.release()
+		fn(conn.client)
+		return nil
+	}
+
+	netPollMan, _ := m.getNetworkPollManagerByUUID(network.UUID)
+	if netPollMan != nil {
+		netPollMan.BusLock.Lock()
+		defer netPollMan.BusLock.Unlock()
+		if netPollMan.PortUnavailableTimeout != nil {
+			return fmt.Errorf("serial port %s is unavailable", networkSerialPort(network))
+		}
+	}
+
+	mbClient, ok := m.mbClients[network.UUID]
+	if !ok {
+		var err error
+		mbClient, err = m.setClient(network, device, true)
+		if err != nil {
+			return err
+		}
+		if m.mbClients != nil {
+			m.mbClients[network.UUID] = mbClient
+		}
+	}
+	if err := setClientDevice(mbClient, network, device); err != nil {
+		return err
+	}
+	fn(mbClient)
+	if netPollMan != nil {
+		m.checkSerialClient(netPollMan, network.UUID)
+	}
+	return nil
+}
+
+// setClient builds the client of a serial network. Clients of tcp networks are built by the connection manager.
 func (m *Module) setClient(network *model.Network, device *model.Device, cacheClient bool) (mbClient *smod.ModbusClient, err error) {
 	mbClient = &smod.ModbusClient{}
-	if network.TransportType == dto.TransType.Serial || network.TransportType == dto.TransType.LoRa {
-		serialPort := "/dev/ttyUSB0"
+	if isSerialTransport(network) {
+		serialPort := networkSerialPort(network)
 		baudRate := 38400
 		stopBits := 1
 		dataBits := 8
 		parity := "N"
-		timeout := 2 * time.Second
-		if network.SerialPort != nil && *network.SerialPort != "" {
-			serialPort = nils.StringIsNil(network.SerialPort)
-		}
+		timeout := networkTimeout(network)
 		if network.SerialBaudRate != nil {
 			baudRate = int(nils.UnitIsNil(network.SerialBaudRate))
 		}
@@ -66,11 +146,28 @@ func (m *Module) setClient(network *model.Network, device *model.Device, cacheCl
 		if network.SerialParity != nil {
 			parity = nils.StringIsNil(network.SerialParity)
 		}
-		if network.SerialTimeout != nil {
-			timeoutSecs := int64(nils.IntIsNil(network.SerialTimeout))
-			if timeoutSecs > 0 {
-				timeout = time.Duration(timeoutSecs) * time.Second
+		if err := checkSerialPort(serialPort); err != nil {
+			m.modbusErrorMsg(fmt.Sprintf("setClient:  %v", err))
+			return nil, err
+		}
+		if network.TransportType == transTypeASCII {
+			handler := modbus.NewASCIIClientHandler(serialPort)
+			handler.BaudRate = baudRate
+			handler.DataBits = dataBits
+			handler.Parity = setParity(parity)
+			handler.StopBits = stopBits
+			handler.Timeout = timeout
+
+			err := handler.Connect()
+			defer handler.Close()
+			if err != nil {
+				m.modbusErrorMsg(fmt.Sprintf("setClient:  %v. port:%s", err, serialPort))
+				return nil, err
 			}
+			mc := modbus.NewClient2(handler, &portTransporter{transporter: m.serialCaptureTransporter(network, handler), client: mbClient})
+			mbClient.ASCIIClientHandler = handler
+			mbClient.Client = mc
+			return mbClient, nil
 		}
 		handler := modbus.NewRTUClientHandler(serialPort)
 		handler.BaudRate = baudRate
@@ -85,28 +182,24 @@ func (m *Module) setClient(network *model.Network, device *model.Device, cacheCl
 			m.modbusErrorMsg(fmt.Sprintf("setClient:  %v. port:%s", err, serialPort))
 			return nil, err
 		}
-		mc := modbus.NewClient(handler)
+		mc := modbus.NewClient2(handler, &portTransporter{transporter: m.serialCaptureTransporter(network, handler), client: mbClient})
 		mbClient.RTUClientHandler = handler
 		mbClient.Client = mc
 		return mbClient, nil
 
-	} else {
-		url, err := uurl.JoinIpPort(device.Host, device.Port)
-		if err != nil {
-			m.modbusErrorMsg(fmt.Sprintf("modbus: failed to validate device IP %s\n", url))
-			return nil, err
-		}
-		handler := modbus.NewTCPClientHandler(url)
-		err = handler.Connect()
-		defer handler.Close()
-		if err != nil {
-			m.modbusErrorMsg(fmt.Sprintf("setClient:  %v. port:%s", err, url))
-			return nil, err
-		}
-		mc := modbus.NewClient(handler)
-		mbClient.TCPClientHandler = handler
-		mbClient.Client = mc
-		return mbClient, nil
+	}
+	return nil, fmt.Errorf("invalid network transport type: %s, net: %s", network.TransportType, network.Name)
+}
+
+// serialCaptureTransporter records the requests of the serial client to the traffic capture of the network.
+func (m *Module) serialCaptureTransporter(network *model.Network, transporter modbus.Transporter) *captureTransporter {
+	networkUUID := network.UUID
+	return &captureTransporter{
+		transporter: transporter,
+		framing:     transportFraming(network.TransportType),
+		capture: func() *TrafficCapture {
+			return m.getTrafficCapture(networkUUID)
+		},
 	}
 }
 
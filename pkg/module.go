@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"time"
 
 	"github.com/NubeIO/lib-module-go/nmodule"
 	"github.com/NubeIO/module-core-modbus/pollqueue"
@@ -25,6 +26,8 @@ type Module struct {
 	running             bool
 	store               *cache.Cache
 	mbClients           map[string]*smod.ModbusClient
+	connections         *connectionManager // sockets of tcp networks, by host:port
+	servers             map[string]*modbusServer
 }
 
 func (m *Module) Init(dbHelper nmodule.DBHelper, moduleName string) error {
@@ -33,6 +36,17 @@ func (m *Module) Init(dbHelper nmodule.DBHelper, moduleName string) error {
 	m.dbHelper = dbHelper
 	m.moduleName = moduleName
 	m.grpcMarshaller = &grpcMarshaller
+	m.store = cache.New(cache.NoExpiration, 10*time.Minute)
+	return nil
+}
+
+// InitWithMarshaller initialises the module with its own marshaller instead of the one of Rubix OS, eg: the marshaller
+// of the simulator package, to run the module in process.
+func (m *Module) InitWithMarshaller(marshaller nmodule.Marshaller, moduleName string) error {
+	InitRouter()
+	m.moduleName = moduleName
+	m.grpcMarshaller = marshaller
+	m.store = cache.New(cache.NoExpiration, 10*time.Minute)
 	return nil
 }
 
@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+// PollingControl is the runtime polling state of a network, which isn't stored in the database.
+type PollingControl struct {
+	NetworkUUID   string   `json:"network_uuid"`
+	Enable        bool     `json:"enable"`
+	Paused        bool     `json:"paused"`
+	PausedDevices []string `json:"paused_devices"`
+}
+
+func newPollingControl(netPollMan *pollqueue.NetworkPollManager) *PollingControl {
+	return &PollingControl{
+		NetworkUUID:   netPollMan.FFNetworkUUID,
+		Enable:        netPollMan.Enable,
+		Paused:        netPollMan.NetworkPaused(),
+		PausedDevices: netPollMan.PausedDeviceUUIDs(),
+	}
+}
+
+func (m *Module) getPollingControl(networkUUID string) (*PollingControl, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	return newPollingControl(netPollMan), nil
+}
+
+// pauseNetworkPolling stops the polling of the network until it is resumed, without disabling it in the database.
+func (m *Module) pauseNetworkPolling(networkUUID string) (*PollingControl, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	netPollMan.PauseNetwork()
+	return newPollingControl(netPollMan), nil
+}
+
+func (m *Module) resumeNetworkPolling(networkUUID string) (*PollingControl, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	netPollMan.ResumeNetwork()
+	return newPollingControl(netPollMan), nil
+}
+
+// flushNetworkPolling empties the poll queue of the network and rebuilds it from the database, eg: if points were lost
+// from the queue.
+func (m *Module) flushNetworkPolling(networkUUID string) (*PollingControl, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err = netPollMan.FlushPollingQueue(); err != nil {
+		return nil, err
+	}
+	return newPollingControl(netPollMan), nil
+}
+
+func (m *Module) getDevicePollManager(deviceUUID string, withPoints bool) (*model.Device, *pollqueue.NetworkPollManager, error) {
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID, &nmodule.Opts{Args: &nargs.Args{WithPoints: withPoints}})
+	if err != nil || dev == nil {
+		return nil, nil, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dev, netPollMan, nil
+}
+
+// pauseDevicePolling stops the polling of the device until it is resumed, without disabling it in the database.
+func (m *Module) pauseDevicePolling(deviceUUID string) (*PollingControl, error) {
+	dev, netPollMan, err := m.getDevicePollManager(deviceUUID, false)
+	if err != nil {
+		return nil, err
+	}
+	netPollMan.PauseDevice(dev.UUID)
+	return newPollingControl(netPollMan), nil
+}
+
+func (m *Module) resumeDevicePolling(deviceUUID string) (*PollingControl, error) {
+	dev, netPollMan, err := m.getDevicePollManager(deviceUUID, false)
+	if err != nil {
+		return nil, err
+	}
+	netPollMan.ResumeDevice(dev.UUID)
+	return newPollingControl(netPollMan), nil
+}
+
+// pollDeviceNow polls all enabled points of the device with ASAP priority.
+func (m *Module) pollDeviceNow(deviceUUID string) (bool, error) {
+	dev, netPollMan, err := m.getDevicePollManager(deviceUUID, true)
+	if err != nil {
+		return false, err
+	}
+	if err = checkPollNowAllowed(netPollMan, dev); err != nil {
+		return false, err
+	}
+	for _, pnt := range dev.Points {
+		if boolean.IsFalse(pnt.Enable) {
+			continue
+		}
+		if err = m.pollPointNowInQueue(netPollMan, pnt); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// pollPointNow polls the point with ASAP priority.
+func (m *Module) pollPointNow(pointUUID string) (bool, error) {
+	pnt, err := m.grpcMarshaller.GetPoint(pointUUID)
+	if err != nil || pnt == nil {
+		return false, fmt.Errorf("failed to find point %s", pointUUID)
+	}
+	dev, netPollMan, err := m.getDevicePollManager(pnt.DeviceUUID, false)
+	if err != nil {
+		return false, err
+	}
+	if err = checkPollNowAllowed(netPollMan, dev); err != nil {
+		return false, err
+	}
+	if boolean.IsFalse(pnt.Enable) {
+		return false, fmt.Errorf("point %s is disabled", pnt.Name)
+	}
+	if err = m.pollPointNowInQueue(netPollMan, pnt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func checkPollNowAllowed(netPollMan *pollqueue.NetworkPollManager, dev *model.Device) error {
+	if boolean.IsFalse(dev.Enable) {
+		return fmt.Errorf("device %s is disabled", dev.Name)
+	}
+	if netPollMan.NetworkPaused() {
+		return errors.New("polling of the network is paused")
+	}
+	if netPollMan.DevicePaused(dev.UUID) {
+		return fmt.Errorf("polling of device %s is paused", dev.Name)
+	}
+	return nil
+}
+
+// pollPointNowInQueue sets the poll required flags of the point for a poll of it now, then moves it to the front of
+// the queue.
+func (m *Module) pollPointNowInQueue(netPollMan *pollqueue.NetworkPollManager, pnt *model.Point) error {
+	if setPollNowRequired(pnt) {
+		if _, err := m.grpcMarshaller.UpdatePoint(pnt.UUID, pnt); err != nil {
+			return err
+		}
+	}
+	if !netPollMan.PollPointNow(pnt.UUID) {
+		return fmt.Errorf("point %s is not in the poll queue", pnt.Name)
+	}
+	return nil
+}
+
+// setPollNowRequired sets the poll required flags of the point as its write mode requires for a poll, a write for the
+// write only modes and a read for the others. A pending write of the other modes is kept. Returns true if the flags
+// were changed.
+func setPollNowRequired(pnt *model.Point) bool {
+	writeRequired := boolean.IsTrue(pnt.WritePollRequired)
+	readRequired := boolean.IsTrue(pnt.ReadPollRequired)
+	switch pnt.WriteMode {
+	case datatype.WriteOnce, datatype.WriteAlways:
+		writeRequired = pnt.WriteValue != nil
+	default:
+		readRequired = true
+	}
+	changed := pnt.WritePollRequired == nil || pnt.ReadPollRequired == nil ||
+		*pnt.WritePollRequired != writeRequired || *pnt.ReadPollRequired != readRequired
+	pnt.WritePollRequired = boolean.New(writeRequired)
+	pnt.ReadPollRequired = boolean.New(readRequired)
+	return changed
+}
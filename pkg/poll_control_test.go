@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/NubeIO/module-core-modbus/simulator"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+func TestFlushNetworkWithoutDevices(t *testing.T) {
+	sim := simulator.New()
+	t.Cleanup(func() { _ = sim.Close() })
+	host, port, err := sim.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn := newTestNetwork(t, sim, &model.Network{TransportType: dto.TransType.IP})
+
+	control, err := tn.module.flushNetworkPolling(tn.network.UUID)
+	if err != nil {
+		t.Fatalf("flushNetworkPolling() error = %v", err)
+	}
+	if !control.Enable {
+		t.Fatal("polling was stopped by the flush")
+	}
+
+	// the points of devices that are added after the flush are polled
+	tn.addDevice(&model.Device{Host: host, Port: port})
+	tn.simDevice.SetHoldingRegisters(0, 11)
+	point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnly)
+	tn.waitForValue(point.UUID, 11)
+}
@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+// getDevicePollStats returns the poll stats of the device and its points, or nil if it hasn't been polled yet.
+func (m *Module) getDevicePollStats(deviceUUID string) (*pollqueue.DevicePollStats, error) {
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID, &nmodule.Opts{Args: &nargs.Args{WithPoints: true}})
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+	if err != nil {
+		return nil, err
+	}
+	stats := netPollMan.GetDevicePollStats(dev.UUID)
+	if stats == nil {
+		return nil, nil
+	}
+	stats.DeviceName = dev.Name
+	pointNames := make(map[string]string, len(dev.Points))
+	for _, pnt := range dev.Points {
+		pointNames[pnt.UUID] = pnt.Name
+	}
+	for _, pointStats := range stats.Points {
+		pointStats.PointName = pointNames[pointStats.PointUUID]
+	}
+	return stats, nil
+}
+
+func (m *Module) resetDevicePollStats(deviceUUID string) (bool, error) {
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID)
+	if err != nil || dev == nil {
+		return false, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+	if err != nil {
+		return false, err
+	}
+	netPollMan.ResetDevicePollStats(dev.UUID)
+	return true, nil
+}
+
+// getPointPollStats returns the poll stats of the point, or nil if it hasn't been polled yet.
+func (m *Module) getPointPollStats(pointUUID string) (*pollqueue.PointPollStats, error) {
+	pnt, err := m.grpcMarshaller.GetPoint(pointUUID)
+	if err != nil || pnt == nil {
+		return nil, fmt.Errorf("failed to find point %s", pointUUID)
+	}
+	dev, err := m.grpcMarshaller.GetDevice(pnt.DeviceUUID)
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", pnt.DeviceUUID)
+	}
+	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+	if err != nil {
+		return nil, err
+	}
+	stats := netPollMan.GetPointPollStats(pnt.UUID)
+	if stats == nil {
+		return nil, nil
+	}
+	stats.PointName = pnt.Name
+	return stats, nil
+}
+
+func (m *Module) resetPointPollStats(pointUUID string) (bool, error) {
+	pnt, err := m.grpcMarshaller.GetPoint(pointUUID)
+	if err != nil || pnt == nil {
+		return false, fmt.Errorf("failed to find point %s", pointUUID)
+	}
+	dev, err := m.grpcMarshaller.GetDevice(pnt.DeviceUUID)
+	if err != nil || dev == nil {
+		return false, fmt.Errorf("failed to find device %s", pnt.DeviceUUID)
+	}
+	netPollMan, err := m.getNetworkPollManagerByUUID(dev.NetworkUUID)
+	if err != nil {
+		return false, err
+	}
+	netPollMan.ResetPointPollStats(pnt.UUID)
+	return true, nil
+}
+
+func (m *Module) getNetworkPollManagerByName(networkName string) (*pollqueue.NetworkPollManager, error) {
+	for _, netPollMan := range m.NetworkPollManagers {
+		if netPollMan != nil && netPollMan.NetworkName == networkName {
+			return netPollMan, nil
+		}
+	}
+	return nil, fmt.Errorf("couldn't find network %s for polling statistics", networkName)
+}
+
+// getNetworkDevicePollStats returns the poll stats of the polled devices of the network, the devices that spend the
+// most time on the bus first.
+func (m *Module) getNetworkDevicePollStats(networkName string) ([]*pollqueue.DevicePollStats, error) {
+	netPollMan, err := m.getNetworkPollManagerByName(networkName)
+	if err != nil {
+		return nil, err
+	}
+	return m.networkDevicePollStats(netPollMan), nil
+}
+
+func (m *Module) getNetworkDevicePollStatsByUUID(networkUUID string) ([]*pollqueue.DevicePollStats, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	return m.networkDevicePollStats(netPollMan), nil
+}
+
+func (m *Module) networkDevicePollStats(netPollMan *pollqueue.NetworkPollManager) []*pollqueue.DevicePollStats {
+	stats := netPollMan.GetAllDevicePollStats()
+	network, err := m.grpcMarshaller.GetNetwork(netPollMan.FFNetworkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true}})
+	if err == nil && network != nil {
+		deviceNames := make(map[string]string, len(network.Devices))
+		for _, dev := range network.Devices {
+			deviceNames[dev.UUID] = dev.Name
+		}
+		for _, deviceStats := range stats {
+			deviceStats.DeviceName = deviceNames[deviceStats.DeviceUUID]
+		}
+	}
+	return stats
+}
+
+func (m *Module) resetNetworkPollStats(networkName string) (bool, error) {
+	netPollMan, err := m.getNetworkPollManagerByName(networkName)
+	if err != nil {
+		return false, err
+	}
+	netPollMan.ResetAllPollStats()
+	return true, nil
+}
+
+func (m *Module) resetNetworkPollStatsByUUID(networkUUID string) (bool, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return false, err
+	}
+	netPollMan.ResetAllPollStats()
+	return true, nil
+}
+
+func (m *Module) getPollingStatsByNetworkUUID(networkUUID string) (*dto.PollQueueStatistics, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	return netPollMan.GetPollingQueueStatistics(), nil
+}
+
+// getPollQueueSnapshot returns the points in each part of the poll queue of the network, with their names.
+func (m *Module) getPollQueueSnapshot(networkUUID string) (*pollqueue.PollQueueSnapshot, error) {
+	netPollMan, err := m.getNetworkPollManagerByUUID(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := netPollMan.GetPollQueueSnapshot()
+	network, err := m.grpcMarshaller.GetNetwork(networkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
+	if err != nil || network == nil {
+		return snapshot, nil
+	}
+	deviceNames := make(map[string]string, len(network.Devices))
+	pointNames := make(map[string]string)
+	for _, dev := range network.Devices {
+		deviceNames[dev.UUID] = dev.Name
+		for _, pnt := range dev.Points {
+			pointNames[pnt.UUID] = pnt.Name
+		}
+	}
+	queued := make([]*pollqueue.QueuedPoint, 0)
+	queued = append(queued, snapshot.CurrentPoints...)
+	queued = append(queued, snapshot.BlockPoints...)
+	queued = append(queued, snapshot.PriorityQueue...)
+	queued = append(queued, snapshot.StandbyPoints...)
+	if snapshot.NextPoint != nil {
+		queued = append(queued, snapshot.NextPoint)
+	}
+	for _, queuedPoint := range queued {
+		queuedPoint.DeviceName = deviceNames[queuedPoint.DeviceUUID]
+		queuedPoint.PointName = pointNames[queuedPoint.PointUUID]
+	}
+	return snapshot, nil
+}
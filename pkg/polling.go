@@ -5,23 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
-	"math"
-	"strconv"
 	"time"
 
 	"github.com/NubeIO/lib-module-go/nmodule"
 	"github.com/NubeIO/lib-utils-go/boolean"
 	"github.com/NubeIO/lib-utils-go/float"
 	"github.com/NubeIO/lib-utils-go/integer"
-	"github.com/NubeIO/lib-utils-go/nurl"
+	"github.com/NubeIO/lib-utils-go/nstring"
 	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
 	log "github.com/sirupsen/logrus"
 )
 
-const minimumMaxPollRate = 0.001
+const (
+	minimumMaxPollRate = 0.001
+	maxPollWorkers     = 64
+)
 
 func (m *Module) initiatePolling(ctx context.Context, network *model.Network) {
 	pollQueueConfig := pollqueue.Config{EnablePolling: m.config.EnablePolling, LogLevel: m.config.PollQueueLogLevel}
@@ -35,8 +37,8 @@ func (m *Module) initiatePolling(ctx context.Context, network *model.Network) {
 	}
 	interval := time.Duration(maxPollRate * float64(time.Second))
 
-	go func() {
-		res, err := m.pollSingleNetwork(netPollMan)
+	pollLoop := func(poll func(*pollqueue.NetworkPollManager) (bool, error)) {
+		res, err := poll(netPollMan)
 		if err != nil || res {
 			return
 		}
@@ -45,7 +47,7 @@ func (m *Module) initiatePolling(ctx context.Context, network *model.Network) {
 		for {
 			select {
 			case <-timer.C:
-				res, err := m.pollSingleNetwork(netPollMan)
+				res, err := poll(netPollMan)
 				if err != nil || res {
 					return
 				}
@@ -54,12 +56,35 @@ func (m *Module) initiatePolling(ctx context.Context, network *model.Network) {
 			}
 
 		}
-	}()
+	}
+
+	// devices of tcp networks have their own connections, so they are polled in parallel. Serial buses are sequential.
+	if isTCPTransport(network) && m.config.MaxPollWorkers > 1 {
+		for i := 0; i < m.config.MaxPollWorkers; i++ {
+			go pollLoop(m.pollDeviceWorker)
+		}
+		return
+	}
+	go pollLoop(m.pollSingleNetwork)
 }
 
+// pollSingleNetwork polls the next point of the network, while holding the network bus.
 func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bool, error) {
-	netPollMan.PollCounter++
-	m.modbusDebugMsg("LOOP COUNT: ", netPollMan.PollCounter)
+	netPollMan.BusLock.Lock()
+	defer netPollMan.BusLock.Unlock()
+	res, err := m.pollNextPoint(netPollMan, false)
+	m.checkSerialClient(netPollMan, netPollMan.FFNetworkUUID)
+	return res, err
+}
+
+// pollDeviceWorker polls the next point of a device that no other worker is polling.
+func (m *Module) pollDeviceWorker(netPollMan *pollqueue.NetworkPollManager) (bool, error) {
+	return m.pollNextPoint(netPollMan, true)
+}
+
+func (m *Module) pollNextPoint(netPollMan *pollqueue.NetworkPollManager, deviceWorker bool) (bool, error) {
+	pollCounter := netPollMan.IncrementPollCounter()
+	m.modbusDebugMsg("LOOP COUNT: ", pollCounter)
 
 	if len(m.NetworkPollManagers) == 0 {
 		m.modbusDebugMsg("NO MODBUS NETWORKS FOUND")
@@ -72,12 +97,22 @@ func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bo
 		return false, nil
 	}
 
+	if !netPollMan.Enable {
+		m.modbusDebugMsg("skipping poll, polling paused ", netPollMan.FFNetworkUUID)
+		return false, nil
+	}
+
 	net, ok := m.getAndCheckNetwork(netPollMan.FFNetworkUUID)
 	if !ok {
 		return false, nil
 	}
 
-	pp := netPollMan.GetNextPollingPoint()
+	var pp *pollqueue.PollingPoint
+	if deviceWorker {
+		pp = netPollMan.PollQueue.GetNextIdleDevicePollingPoint()
+	} else {
+		pp = netPollMan.GetNextPollingPoint()
+	}
 	if pp == nil {
 		m.modbusDebugMsg("skipping poll, no points to poll ", net.Name, net.UUID)
 		return false, nil
@@ -96,75 +131,97 @@ func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bo
 		return false, nil
 	}
 
+	if netPollMan.DevicePaused(dev.UUID) {
+		m.modbusPollingMsg(fmt.Sprintf("skipping poll, device paused. Device: %s, Point: %s", dev.Name, pnt.Name))
+		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, true, pollqueue.DELAYED_RETRY)
+		return false, nil
+	}
+
+	if !netPollMan.DevicePollAllowed(dev.UUID) {
+		m.modbusPollingMsg(fmt.Sprintf("skipping poll, device offline. Device: %s, Point: %s", dev.Name, pnt.Name))
+		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, true, pollqueue.DELAYED_RETRY)
+		return false, nil
+	}
+
 	m.modbusPollingMsg(fmt.Sprintf("next poll drawn. Network: %s, Device: %s, Point: %s, Priority: %s, Device-Add: %d, Point-Add: %d, Point Type: %s, WriteRequired: %t, ReadRequired: %t", net.Name, dev.Name, pnt.Name, pnt.PollPriority, dev.AddressId, integer.NonNil(pnt.AddressID), pnt.ObjectType, boolean.IsTrue(pnt.WritePollRequired), boolean.IsTrue(pnt.ReadPollRequired)))
 
 	var err error = nil
-	mbClient, ok := m.mbClients[net.UUID]
-	if !ok {
-		mbClient, err = m.createMbClient(netPollMan, net, dev)
+	var mbClient *smod.ModbusClient
+	if isTCPTransport(net) {
+		var conn *tcpConnection
+		conn, err = m.connections.acquire(net, dev, m.getTrafficCapture(net.UUID))
 		if err != nil {
-			m.modbusErrorMsg(fmt.Sprintf("failed to set client error: %v. network name:%s", err, net.Name))
-			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.NORMAL_RETRY)
+			m.modbusErrorMsg(err.Error())
+			_ = m.deviceUpdateErr(dev, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.DeviceError)
+			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
 			return false, nil
 		}
-	}
-	if net.TransportType == dto.TransType.Serial || net.TransportType == dto.TransType.LoRa {
-		mbClient.RTUClientHandler.SlaveID = byte(dev.AddressId)
-	} else if net.TransportType == dto.TransType.IP {
-		url, err1 := nurl.JoinIPPort(nurl.Parts{Host: dev.Host, Port: strconv.Itoa(dev.Port)})
-		if err1 != nil {
-			errMes := fmt.Sprintf("failed to validate device address: %s, %s", url, err1.Error())
-			m.modbusErrorMsg(errMes)
-			m.updateNetworkMessage(net, "", errors.New(errMes), netPollMan.PollCounter)
+		defer conn.release()
+		mbClient = conn.client
+	} else {
+		mbClient, ok = m.mbClients[net.UUID]
+		if !ok {
+			mbClient, err = m.createMbClient(netPollMan, net, dev)
+			if err != nil {
+				m.modbusErrorMsg(fmt.Sprintf("failed to set client error: %v. network name:%s", err, net.Name))
+				netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.NORMAL_RETRY)
+				return false, nil
+			}
+		}
+		if err = setClientDevice(mbClient, net, dev); err != nil {
+			m.modbusErrorMsg(err.Error())
+			m.updateNetworkMessage(net, "", err, pollCounter)
 			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
 			return false, nil
 		}
-		mbClient.TCPClientHandler.Address = url
-		mbClient.TCPClientHandler.SlaveID = byte(dev.AddressId)
-	} else {
-		errMes := fmt.Sprintf("invalid network transport type: %s, net: %s, err: %v", net.TransportType, net.Name, err)
-		m.modbusDebugMsg(errMes)
-		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
-		m.updateNetworkMessage(net, "", errors.New(errMes), netPollMan.PollCounter)
+	}
+
+	if m.pollPointWriteVerification(netPollMan, mbClient, dev, pp, pnt, pollStartTime) {
+		return false, nil
+	}
+	if m.config.EnableBlockWrites && m.pollPointWriteBlock(netPollMan, mbClient, dev, pp, pnt, pollStartTime) {
+		return false, nil
+	}
+	if m.config.EnableBlockReads && m.pollPointBlock(netPollMan, mbClient, dev, pp, pnt, pollStartTime) {
 		return false, nil
 	}
 
 	var readResponseValue float64
 	var writeResponseValue float64
 	var bitwiseResponseValue float64
-	var bitwiseWriteValueFloat float64
-	var bitwiseWriteValueBool bool
 	var readResponse interface{}
 	var writeResponse interface{}
 
-	bitwiseType := boolean.IsTrue(pnt.IsBitwise) && pnt.BitwiseIndex != nil && *pnt.BitwiseIndex >= 0
+	field, bitwiseType := pointBitField(pnt)
+	maskWrite := bitwiseType && canMaskWrite(dev, pnt, field)
+	writeReadBack := bitwiseType && !maskWrite && canWriteReadBack(dev, pnt, field)
 
 	// READ POINT
 	readSuccess := false
 	if boolean.IsTrue(pnt.ReadPollRequired) && (boolean.IsFalse(pnt.WritePollRequired) || (bitwiseType && boolean.IsTrue(pnt.WritePollRequired))) { // DO READ IF REQUIRED
 		readResponse, readResponseValue, err = m.networkRead(mbClient, pnt)
+		reportDevicePoll(netPollMan, dev.UUID, err)
 		if err != nil {
-			err = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointError)
-			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.IMMEDIATE_RETRY)
+			retryType := m.pointRequestFailed(pnt, err, dto.CommonFaultCode.PointError)
+			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, retryType)
 			return false, nil
 		}
 		if bitwiseType {
-			var bitValue bool
-			bitValue, err = getBitFromFloat64(readResponseValue, *pnt.BitwiseIndex)
+			bitwiseResponseValue, err = field.read(readResponseValue)
 			if err != nil {
 				m.modbusDebugMsg("Bitwise Error: ", err)
 				err = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointError)
 				netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
 				return false, nil
 			}
-			if bitValue {
-				bitwiseResponseValue = float64(1)
-			} else {
-				bitwiseResponseValue = float64(0)
-			}
 		}
 		readSuccess = true
 		m.modbusPollingMsg(fmt.Sprintf("READ-RESPONSE: responseValue %f, point UUID: %s, response: %+v ", readResponseValue, pnt.UUID, readResponse))
+		if text, ok := readResponse.(string); ok && isStringPoint(pnt) {
+			_ = m.internalPointStringUpdate(pnt, text)
+			netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, true, false, pollqueue.NORMAL_RETRY)
+			return false, nil
+		}
 	}
 
 	// WRITE POINT
@@ -172,7 +229,7 @@ func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bo
 	if IsWriteable(pnt.WriteMode) && boolean.IsTrue(pnt.WritePollRequired) { // DO WRITE IF REQUIRED
 		if pnt.WriteValue != nil {
 			// TODO: should this be here?????
-			if readSuccess {
+			if readSuccess && !maskWrite {
 				if net.MaxPollRate == nil {
 					*net.MaxPollRate = 0.1
 				}
@@ -180,38 +237,67 @@ func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bo
 				m.modbusDebugMsg(sleepTime.String(), " delay between read and write.")
 				time.Sleep(sleepTime)
 			}
-			if bitwiseType {
-				if !readSuccess || math.Mod(readResponseValue, 1) != 0 {
-					err = m.internalPointUpdateErr(pnt, "read fail: bitwise point needs successful read before write", dto.MessageLevel.Fail, dto.CommonFaultCode.PointError)
+			fieldValue := *pnt.WriteValue
+			if maskWrite {
+				// only the bits of the field are changed by the device, so no read is needed before the write
+				var andMask, orMask uint16
+				andMask, orMask, err = field.maskWrite(fieldValue)
+				if err != nil {
+					err = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointWriteError)
 					netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
 					return false, nil
 				}
-				// Set appropriate writeValue for the bitwise type.  This value is the readResponseValue with the bit index modified
-				if *pnt.WriteValue == 1 {
-					bitwiseWriteValueBool = true
-					bitwiseWriteValueFloat = float64(setBit(int(readResponseValue), uint(*pnt.BitwiseIndex)))
-				} else if *pnt.WriteValue == 0 {
-					bitwiseWriteValueBool = false
-					bitwiseWriteValueFloat = float64(clearBit(int(readResponseValue), uint(*pnt.BitwiseIndex)))
+				writeResponse, err = mbClient.MaskWriteRegister(pointAddress(pnt, mbClient.DeviceZeroMode), andMask, orMask)
+				writeResponseValue = fieldValue
+			} else {
+				if bitwiseType {
+					if !readSuccess {
+						err = m.internalPointUpdateErr(pnt, "read fail: bitwise point needs successful read before write", dto.MessageLevel.Fail, dto.CommonFaultCode.PointError)
+						netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
+						return false, nil
+					}
+					// the register is written with the read value, with only the bits of the field modified
+					var registerValue float64
+					registerValue, err = field.write(readResponseValue, fieldValue)
+					if err != nil {
+						err = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointWriteError)
+						netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
+						return false, nil
+					}
+					pnt.WriteValue = float.New(registerValue)
+				}
+				if writeReadBack {
+					// the register is read back in the same request, so a change made by another master shows in the point
+					var readBackValue float64
+					dataType := nstring.NewString(pnt.DataType).ToSnakeCase()
+					writeResponse, readBackValue, err = mbClient.WriteReadRegister(pointAddress(pnt, mbClient.DeviceZeroMode), uint16(*pnt.WriteValue), dataType)
+					if err == nil {
+						writeResponseValue, err = field.read(readBackValue)
+					}
+				} else {
+					writeResponse, writeResponseValue, err = m.networkWrite(mbClient, pnt)
+				}
+				if bitwiseType {
+					pnt.WriteValue = float.New(fieldValue)
+					if !writeReadBack {
+						writeResponseValue = fieldValue
+					}
 				}
-				pnt.WriteValue = float.New(bitwiseWriteValueFloat)
 			}
-			writeResponse, writeResponseValue, err = m.networkWrite(mbClient, pnt)
+			reportDevicePoll(netPollMan, dev.UUID, err)
 			if err != nil {
-				err = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointWriteError)
-				netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.IMMEDIATE_RETRY)
+				retryType := m.pointRequestFailed(pnt, err, dto.CommonFaultCode.PointWriteError)
+				netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, retryType)
 				return false, nil
 			}
-			if bitwiseType {
-				if bitwiseWriteValueBool {
-					writeResponseValue = float64(1)
-				} else {
-					writeResponseValue = float64(0)
-				}
+			m.modbusPollingMsg(fmt.Sprintf("WRITE-RESPONSE: responseValue %f, point UUID: %s, response: %+v", writeResponseValue, pnt.UUID, writeResponse))
+
+			if verify, ok := pointWriteVerification(pnt); ok {
+				// the value is read back by the next poll of the point, after the verify delay
+				m.scheduleWriteVerification(netPollMan, pp, pnt, verify, writeResponseValue, pollStartTime)
+				return false, nil
 			}
 			writeSuccess = true
-
-			m.modbusPollingMsg(fmt.Sprintf("WRITE-RESPONSE: responseValue %f, point UUID: %s, response: %+v", writeResponseValue, pnt.UUID, writeResponse))
 		} else {
 			writeSuccess = true // successful because there is no value to write.  Otherwise the point will short cycle.
 			m.modbusDebugMsg("modbus write point error: no value in priority array to write")
@@ -242,19 +328,15 @@ func (m *Module) pollSingleNetwork(netPollMan *pollqueue.NetworkPollManager) (bo
 			pnt, _ = m.internalPointUpdate(pnt, newValue)
 		}
 
-		if netPollMan.PollCounter == 1 || netPollMan.PollCounter%100 == 0 { // give the user some feedback on how the polling has been working
+		if pollCounter == 1 || pollCounter%100 == 0 { // give the user some feedback on how the polling has been working
 			deviceMessage := fmt.Sprintf("last 100th poll: %s", TimeStamp())
-			m.updateNetworkMessage(net, deviceMessage, nil, netPollMan.PollCounter)
-			if netPollMan.PollCounter > 100000 {
-				netPollMan.PollCounter = 100
-			}
+			m.updateNetworkMessage(net, deviceMessage, nil, pollCounter)
 			device, err := m.grpcMarshaller.GetDevice(dev.UUID, &nmodule.Opts{Args: &nargs.Args{}})
-			if err != nil || device == nil {
-				return false, nil
+			if err == nil && device != nil { // the poll must still be finished below, so only the message is skipped
+				device.Message = deviceMessage
+				device.CommonFault.LastOk = time.Now().UTC()
+				m.grpcMarshaller.UpdateDevice(device.UUID, device)
 			}
-			device.Message = deviceMessage
-			device.CommonFault.LastOk = time.Now().UTC()
-			m.grpcMarshaller.UpdateDevice(device.UUID, device)
 		}
 	}
 
@@ -271,6 +353,25 @@ func (m *Module) getNetworkPollManagerByUUID(netUUID string) (*pollqueue.Network
 	return nil, errors.New("modbus getNetworkPollManagerByUUID(): couldn't find NetworkPollManager")
 }
 
+// reportDevicePoll updates the health and request counters of the device from the result of a request. An exception
+// response means that the device is reachable, so only requests that got no valid response count as failures. A failed
+// serial port isn't a failure of the device, and a write of a value that is out of range isn't sent to it.
+func reportDevicePoll(netPollMan *pollqueue.NetworkPollManager, deviceUUID string, err error) {
+	requestErr := smod.ClassifyError(err)
+	if requestErr != nil && requestErr.Class == smod.ErrorValueRange {
+		return // the request wasn't sent
+	}
+	netPollMan.CountDeviceRequest(deviceUUID, requestResult(requestErr))
+	if requestErr != nil && requestErr.Class == smod.ErrorPortUnavailable {
+		return // the request didn't get to the device
+	}
+	if requestErr == nil || requestErr.IsException() {
+		netPollMan.DevicePollSucceeded(deviceUUID)
+		return
+	}
+	netPollMan.DevicePollFailed(deviceUUID, err)
+}
+
 func (m *Module) getAndCheckNetwork(uuid string) (*model.Network, bool) {
 	net, err := m.grpcMarshaller.GetNetwork(uuid)
 	if err != nil || net == nil || net.PluginUUID != m.pluginUUID {
@@ -286,7 +387,7 @@ func (m *Module) getAndCheckNetwork(uuid string) (*model.Network, bool) {
 }
 
 func (m *Module) getAndCheckDevice(uuid string) (*model.Device, bool, pollqueue.PollRetryType) {
-	dev, err := m.grpcMarshaller.GetDevice(uuid)
+	dev, err := m.grpcMarshaller.GetDevice(uuid, &nmodule.Opts{Args: &nargs.Args{WithMetaTags: true}})
 	if dev == nil || err != nil {
 		m.modbusErrorMsg("skipping poll, could not find device ", uuid)
 		return nil, false, pollqueue.DELAYED_RETRY
@@ -303,7 +404,7 @@ func (m *Module) getAndCheckDevice(uuid string) (*model.Device, bool, pollqueue.
 }
 
 func (m *Module) getAndCheckPoint(uuid string) (*model.Point, bool, pollqueue.PollRetryType) {
-	pnt, err := m.grpcMarshaller.GetPoint(uuid, &nmodule.Opts{Args: &nargs.Args{WithPriority: true}})
+	pnt, err := m.grpcMarshaller.GetPoint(uuid, &nmodule.Opts{Args: &nargs.Args{WithPriority: true, WithMetaTags: true}})
 	if pnt == nil || err != nil {
 		m.modbusErrorMsg("could not find pointID: ", uuid)
 		return nil, false, pollqueue.DELAYED_RETRY
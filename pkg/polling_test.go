@@ -0,0 +1,377 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/module-core-modbus/mbserver"
+	"github.com/NubeIO/module-core-modbus/simulator"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+const (
+	testModuleName = "module-core-modbus"
+	testUnitId     = 1
+	testTimeout    = 5 * time.Second
+)
+
+// testNetwork is a network of the module, polled against a simulated device.
+type testNetwork struct {
+	t          *testing.T
+	module     *Module
+	marshaller *simulator.Marshaller
+	simDevice  *simulator.Device
+	network    *model.Network
+	device     *model.Device
+}
+
+// newTestModule enables a module on the marshaller of the simulator.
+func newTestModule(t *testing.T) (*Module, *simulator.Marshaller) {
+	marshaller := simulator.NewMarshaller(testModuleName)
+	m := &Module{}
+	if err := m.InitWithMarshaller(marshaller, testModuleName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateAndSetConfig([]byte("log_level: ERROR\nconnection_idle_timeout: 0\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enable(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Disable() })
+	return m, marshaller
+}
+
+// newTCPNetwork polls a device of the simulator over modbus tcp.
+func newTCPNetwork(t *testing.T) *testNetwork {
+	sim := simulator.New()
+	t.Cleanup(func() { _ = sim.Close() })
+	host, port, err := sim.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := &model.Network{TransportType: dto.TransType.IP}
+	device := &model.Device{Host: host, Port: port}
+	return addTestNetwork(t, sim, network, device)
+}
+
+// newRTUNetwork polls a device of the simulator over modbus rtu, on a pseudo terminal. The timeout is 1 second.
+func newRTUNetwork(t *testing.T) *testNetwork {
+	sim := simulator.New()
+	t.Cleanup(func() { _ = sim.Close() })
+	serialPort, err := sim.ListenRTU()
+	if err != nil {
+		t.Skipf("no pseudo terminal for the rtu network: %v", err)
+	}
+	network := &model.Network{
+		TransportType: dto.TransType.Serial,
+		SerialPort:    &serialPort,
+		SerialTimeout: integer.New(1),
+	}
+	return addTestNetwork(t, sim, network, &model.Device{})
+}
+
+// addTestNetwork adds the network with a device of the simulator, which polls its fast rate points every 200ms.
+func addTestNetwork(t *testing.T, sim *simulator.Simulator, network *model.Network, device *model.Device) *testNetwork {
+	tn := newTestNetwork(t, sim, network)
+	tn.addDevice(device)
+	return tn
+}
+
+// newTestNetwork adds the network, without devices, to a new module.
+func newTestNetwork(t *testing.T, sim *simulator.Simulator, network *model.Network) *testNetwork {
+	m, marshaller := newTestModule(t)
+	network.Name = "network"
+	network.PluginUUID = m.pluginUUID
+	network.Enable = boolean.NewTrue()
+	network.MaxPollRate = float.New(0.02)
+	network, err := m.addNetwork(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testNetwork{t: t, module: m, marshaller: marshaller, simDevice: sim.AddDevice(testUnitId), network: network}
+}
+
+func (tn *testNetwork) addDevice(device *model.Device) {
+	device.Name = "device"
+	device.NetworkUUID = tn.network.UUID
+	device.AddressId = testUnitId
+	device.Enable = boolean.NewTrue()
+	device.FastPollRate = float.New(0.2)
+	device, err := tn.module.addDevice(device)
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	tn.device = device
+}
+
+// addPoint adds a uint16 point of the register, which is polled at the fast rate.
+func (tn *testNetwork) addPoint(objectType datatype.ObjectType, register int, writeMode datatype.WriteMode, metaTags ...*model.PointMetaTag) *model.Point {
+	point, err := tn.module.addPoint(&model.Point{
+		Name:         "point",
+		DeviceUUID:   tn.device.UUID,
+		Enable:       boolean.NewTrue(),
+		ObjectType:   string(objectType),
+		DataType:     string(datatype.TypeUint16),
+		AddressID:    integer.New(register),
+		WriteMode:    writeMode,
+		PollPriority: datatype.PriorityNormal,
+		PollRate:     datatype.RateFast,
+		MetaTags:     metaTags,
+	})
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	return point
+}
+
+func (tn *testNetwork) getPoint(uuid string) *model.Point {
+	point, err := tn.marshaller.GetPoint(uuid)
+	if err != nil {
+		tn.t.Fatal(err)
+	}
+	return point
+}
+
+// write writes the value to priority 16 of the point, like a user.
+func (tn *testNetwork) write(uuid string, value float64) {
+	priority := map[string]*float64{"_16": float.New(value)}
+	if _, err := tn.module.writePoint(uuid, &dto.PointWriter{Priority: &priority}); err != nil {
+		tn.t.Fatal(err)
+	}
+}
+
+// waitForValue waits for the present value of the point.
+func (tn *testNetwork) waitForValue(uuid string, value float64) {
+	tn.t.Helper()
+	var point *model.Point
+	waitFor(tn.t, func() bool {
+		point = tn.getPoint(uuid)
+		return !point.CommonFault.InFault && point.OriginalValue != nil && *point.OriginalValue == value
+	}, "point value %v, got %v (%s)", value, func() interface{} { return float.NonNil(point.OriginalValue) }, func() interface{} { return point.CommonFault.Message })
+}
+
+// waitForFault waits for the point to fault with a message that contains the text.
+func (tn *testNetwork) waitForFault(uuid, text string) {
+	tn.t.Helper()
+	var point *model.Point
+	waitFor(tn.t, func() bool {
+		point = tn.getPoint(uuid)
+		return point.CommonFault.InFault && strings.Contains(point.CommonFault.Message, text)
+	}, "point fault %q, got %q", text, func() interface{} { return point.CommonFault.Message })
+}
+
+// waitForRegister waits for the holding register of the simulated device.
+func (tn *testNetwork) waitForRegister(addr, value uint16) {
+	tn.t.Helper()
+	var register uint16
+	waitFor(tn.t, func() bool {
+		register, _ = tn.simDevice.HoldingRegister(addr)
+		return register == value
+	}, "holding register %d to be %d, got %d", addr, value, func() interface{} { return register })
+}
+
+// waitFor waits for the condition, the values of the args are taken when it times out.
+func waitFor(t *testing.T, condition func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			values := make([]interface{}, len(args))
+			for i, arg := range args {
+				if value, ok := arg.(func() interface{}); ok {
+					arg = value()
+				}
+				values[i] = arg
+			}
+			t.Fatalf("timed out waiting for "+format, values...)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// settle lets the points be polled a few more times, to check what didn't happen.
+func settle() {
+	time.Sleep(time.Second)
+}
+
+// forTransports runs the test on a tcp and on an rtu network.
+func forTransports(t *testing.T, test func(t *testing.T, tn *testNetwork)) {
+	for _, transport := range []struct {
+		name       string
+		newNetwork func(*testing.T) *testNetwork
+	}{
+		{"tcp", newTCPNetwork},
+		{"rtu", newRTUNetwork},
+	} {
+		t.Run(transport.name, func(t *testing.T) {
+			test(t, transport.newNetwork(t))
+		})
+	}
+}
+
+func TestPollingRead(t *testing.T) {
+	forTransports(t, func(t *testing.T, tn *testNetwork) {
+		tn.simDevice.SetHoldingRegisters(0, 11)
+		tn.simDevice.SetInputRegisters(4, 22)
+		holding := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnly)
+		input := tn.addPoint(datatype.ObjTypeInputRegister, 5, datatype.ReadOnly)
+		tn.waitForValue(holding.UUID, 11)
+		tn.waitForValue(input.UUID, 22)
+
+		// read only points are polled again at their poll rate
+		tn.simDevice.SetHoldingRegisters(0, 12)
+		tn.waitForValue(holding.UUID, 12)
+	})
+}
+
+func TestPollingReadOnce(t *testing.T) {
+	tn := newTCPNetwork(t)
+	tn.simDevice.SetHoldingRegisters(0, 11)
+	point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnce)
+	tn.waitForValue(point.UUID, 11)
+
+	tn.simDevice.SetHoldingRegisters(0, 12)
+	settle()
+	tn.waitForValue(point.UUID, 11)
+}
+
+func TestPollingWriteModes(t *testing.T) {
+	tests := []struct {
+		writeMode datatype.WriteMode
+		rewrite   bool // the write value is written again after the device changed the register
+		reread    bool // the register is read again after the write
+	}{
+		{datatype.WriteOnce, false, false},
+		{datatype.WriteOnceReadOnce, false, false},
+		{datatype.WriteAlways, true, false},
+		{datatype.WriteOnceThenRead, false, true},
+		{datatype.WriteAndMaintain, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.writeMode), func(t *testing.T) {
+			forTransports(t, func(t *testing.T, tn *testNetwork) {
+				tn.simDevice.SetHoldingRegisters(0, 0)
+				point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, tt.writeMode)
+
+				tn.write(point.UUID, 42)
+				tn.waitForRegister(0, 42)
+				tn.waitForValue(point.UUID, 42)
+				if !tt.rewrite {
+					// the write, and the read of write_once_read_once, are done
+					waitFor(t, func() bool {
+						point = tn.getPoint(point.UUID)
+						return boolean.IsFalse(point.WritePollRequired) && (tt.reread || boolean.IsFalse(point.ReadPollRequired))
+					}, "the polls after the write")
+				}
+
+				// another master changes the register
+				tn.simDevice.SetHoldingRegisters(0, 7)
+				switch {
+				case tt.rewrite:
+					tn.waitForRegister(0, 42)
+				case tt.reread:
+					tn.waitForValue(point.UUID, 7)
+				default:
+					settle()
+					tn.waitForValue(point.UUID, 42)
+				}
+				if register, _ := tn.simDevice.HoldingRegister(0); !tt.rewrite && register != 7 {
+					t.Errorf("holding register was written again: %d", register)
+				}
+
+				// a new write value is written by all write modes
+				tn.write(point.UUID, 43)
+				tn.waitForRegister(0, 43)
+			})
+		})
+	}
+}
+
+func TestPollingException(t *testing.T) {
+	forTransports(t, func(t *testing.T, tn *testNetwork) {
+		tn.simDevice.SetHoldingRegisters(0, 11)
+		tn.simDevice.SetException(0, mbserver.ServerDeviceBusy)
+		point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnly)
+		tn.waitForFault(point.UUID, string(smod.ErrorDeviceBusy))
+
+		// busy devices are retried, so the point recovers
+		tn.simDevice.ClearExceptions()
+		tn.waitForValue(point.UUID, 11)
+
+		// a missing register is a misconfigured point
+		missing := tn.addPoint(datatype.ObjTypeHoldingRegister, 100, datatype.ReadOnly)
+		tn.waitForFault(missing.UUID, "misconfigured point")
+	})
+}
+
+func TestPollingDroppedFrames(t *testing.T) {
+	tn := newRTUNetwork(t)
+	tn.simDevice.SetHoldingRegisters(0, 11)
+	tn.simDevice.DropRequests(1)
+	point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnly)
+	tn.waitForFault(point.UUID, "timeout")
+	tn.waitForValue(point.UUID, 11)
+	if requests := tn.simDevice.Requests(); requests < 2 {
+		t.Errorf("the dropped request wasn't retried, requests: %d", requests)
+	}
+}
+
+func TestPollingDelay(t *testing.T) {
+	tn := newRTUNetwork(t)
+	tn.simDevice.SetHoldingRegisters(0, 11)
+
+	// a slow device is still polled within the timeout
+	tn.simDevice.SetDelay(300 * time.Millisecond)
+	point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.ReadOnly)
+	tn.waitForValue(point.UUID, 11)
+
+	// responses after the timeout are lost
+	tn.simDevice.SetDelay(1500 * time.Millisecond)
+	tn.waitForFault(point.UUID, "timeout")
+
+	tn.simDevice.SetDelay(0)
+	tn.simDevice.SetHoldingRegisters(0, 12)
+	tn.waitForValue(point.UUID, 12)
+}
+
+func TestPollingWriteVerification(t *testing.T) {
+	forTransports(t, func(t *testing.T, tn *testNetwork) {
+		tn.simDevice.SetHoldingRegisters(0, 0, 0)
+		point := tn.addPoint(datatype.ObjTypeHoldingRegister, 1, datatype.WriteOnce,
+			&model.PointMetaTag{Key: metaTagVerifyWrite, Value: "true"},
+			&model.PointMetaTag{Key: metaTagVerifyWriteDelay, Value: "1000"},
+			&model.PointMetaTag{Key: metaTagVerifyWriteTolerance, Value: "1"},
+		)
+		other := tn.addPoint(datatype.ObjTypeHoldingRegister, 2, datatype.ReadOnly)
+		tn.waitForValue(other.UUID, 0)
+
+		tn.write(point.UUID, 42)
+		tn.waitForRegister(0, 42)
+		// the device changes the value within the tolerance before the read back
+		tn.simDevice.SetHoldingRegisters(0, 43)
+
+		// the other point is polled while the write waits for its read back
+		tn.simDevice.SetHoldingRegisters(1, 5)
+		tn.waitForValue(other.UUID, 5)
+		if value := tn.getPoint(point.UUID).OriginalValue; value != nil && *value == 42 {
+			t.Error("write was verified before the delay")
+		}
+		tn.waitForValue(point.UUID, 42)
+
+		// a value that the device doesn't keep is written again
+		tn.write(point.UUID, 50)
+		tn.waitForRegister(0, 50)
+		tn.simDevice.SetHoldingRegisters(0, 60)
+		tn.waitForFault(point.UUID, "write verification failed: wrote 50, read back 60")
+		tn.waitForRegister(0, 50)
+		tn.waitForValue(point.UUID, 50)
+	})
+}
@@ -0,0 +1,287 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+	"github.com/go-yaml/yaml"
+)
+
+var profileIdRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// DeviceProfile is the register map of a device model, that points can be created from.
+type DeviceProfile struct {
+	ID          string          `json:"id" yaml:"-"` // file name, without the extension
+	Name        string          `json:"name" yaml:"name"`
+	Description string          `json:"description,omitempty" yaml:"description,omitempty"`
+	Points      []*ProfilePoint `json:"points,omitempty" yaml:"points"`
+	PointCount  int             `json:"point_count" yaml:"-"`
+}
+
+// ProfilePoint is a point of a device profile, with the same properties as the point schema.
+type ProfilePoint struct {
+	Name                 string                `json:"name" yaml:"name"`
+	Description          string                `json:"description,omitempty" yaml:"description,omitempty"`
+	ObjectType           string                `json:"object_type" yaml:"object_type"`
+	AddressID            int                   `json:"address_id" yaml:"address_id"`
+	AddressLength        *int                  `json:"address_length,omitempty" yaml:"address_length,omitempty"` // registers of string points
+	DataType             string                `json:"data_type,omitempty" yaml:"data_type,omitempty"`
+	ObjectEncoding       string                `json:"object_encoding,omitempty" yaml:"object_encoding,omitempty"`
+	WriteMode            datatype.WriteMode    `json:"write_mode,omitempty" yaml:"write_mode,omitempty"`
+	PollPriority         datatype.PollPriority `json:"poll_priority,omitempty" yaml:"poll_priority,omitempty"`
+	PollRate             datatype.PollRate     `json:"poll_rate,omitempty" yaml:"poll_rate,omitempty"`
+	MultiplicationFactor *float64              `json:"multiplication_factor,omitempty" yaml:"multiplication_factor,omitempty"`
+	ScaleEnable          *bool                 `json:"scale_enable,omitempty" yaml:"scale_enable,omitempty"`
+	ScaleInMin           *float64              `json:"scale_in_min,omitempty" yaml:"scale_in_min,omitempty"`
+	ScaleInMax           *float64              `json:"scale_in_max,omitempty" yaml:"scale_in_max,omitempty"`
+	ScaleOutMin          *float64              `json:"scale_out_min,omitempty" yaml:"scale_out_min,omitempty"`
+	ScaleOutMax          *float64              `json:"scale_out_max,omitempty" yaml:"scale_out_max,omitempty"`
+	Offset               *float64              `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Decimal              *uint32               `json:"decimal,omitempty" yaml:"decimal,omitempty"`
+	Unit                 *string               `json:"unit,omitempty" yaml:"unit,omitempty"`
+	IsBitwise            *bool                 `json:"is_bitwise,omitempty" yaml:"is_bitwise,omitempty"`
+	BitwiseIndex         *int                  `json:"bitwise_index,omitempty" yaml:"bitwise_index,omitempty"`
+}
+
+// ExportProfile is the body of a device profile export.
+type ExportProfile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func newProfilePoint(pnt *model.Point) *ProfilePoint {
+	address := 0
+	if pnt.AddressID != nil {
+		address = *pnt.AddressID
+	}
+	return &ProfilePoint{
+		Name:                 pnt.Name,
+		Description:          pnt.Description,
+		ObjectType:           pnt.ObjectType,
+		AddressID:            address,
+		AddressLength:        pnt.AddressLength,
+		DataType:             pnt.DataType,
+		ObjectEncoding:       pnt.ObjectEncoding,
+		WriteMode:            pnt.WriteMode,
+		PollPriority:         pnt.PollPriority,
+		PollRate:             pnt.PollRate,
+		MultiplicationFactor: pnt.MultiplicationFactor,
+		ScaleEnable:          pnt.ScaleEnable,
+		ScaleInMin:           pnt.ScaleInMin,
+		ScaleInMax:           pnt.ScaleInMax,
+		ScaleOutMin:          pnt.ScaleOutMin,
+		ScaleOutMax:          pnt.ScaleOutMax,
+		Offset:               pnt.Offset,
+		Decimal:              pnt.Decimal,
+		Unit:                 pnt.Unit,
+		IsBitwise:            pnt.IsBitwise,
+		BitwiseIndex:         pnt.BitwiseIndex,
+	}
+}
+
+// toPoint returns the body of a new point on the device.
+func (pp *ProfilePoint) toPoint(deviceUUID string) *model.Point {
+	pnt := &model.Point{Enable: boolean.NewTrue(), DeviceUUID: deviceUUID}
+	pp.applyTo(pnt)
+	return pnt
+}
+
+// applyTo sets the profile properties on the point.
+func (pp *ProfilePoint) applyTo(pnt *model.Point) {
+	address := pp.AddressID
+	pnt.Name = pp.Name
+	pnt.Description = pp.Description
+	pnt.ObjectType = pp.ObjectType
+	pnt.AddressID = &address
+	pnt.AddressLength = pp.AddressLength
+	pnt.DataType = pp.DataType
+	pnt.ObjectEncoding = pp.ObjectEncoding
+	pnt.WriteMode = pp.WriteMode
+	pnt.PollPriority = pp.PollPriority
+	pnt.PollRate = pp.PollRate
+	pnt.MultiplicationFactor = pp.MultiplicationFactor
+	pnt.ScaleEnable = pp.ScaleEnable
+	pnt.ScaleInMin = pp.ScaleInMin
+	pnt.ScaleInMax = pp.ScaleInMax
+	pnt.ScaleOutMin = pp.ScaleOutMin
+	pnt.ScaleOutMax = pp.ScaleOutMax
+	pnt.Offset = pp.Offset
+	pnt.Decimal = pp.Decimal
+	pnt.Unit = pp.Unit
+	pnt.IsBitwise = pp.IsBitwise
+	pnt.BitwiseIndex = pp.BitwiseIndex
+}
+
+// readProfile reads a .yaml, .yml or .json profile file.
+func readProfile(path string) (*DeviceProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profile := &DeviceProfile{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, profile)
+	} else {
+		err = yaml.Unmarshal(data, profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid device profile %s: %v", filepath.Base(path), err)
+	}
+	profile.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	profile.PointCount = len(profile.Points)
+	return profile, nil
+}
+
+// profileFiles returns the profile files by profile id.
+func (m *Module) profileFiles() (map[string]string, error) {
+	entries, err := os.ReadDir(m.config.DeviceProfilesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		files[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = filepath.Join(m.config.DeviceProfilesDir, entry.Name())
+	}
+	return files, nil
+}
+
+// getProfiles lists the device profiles, without their points.
+func (m *Module) getProfiles() ([]*DeviceProfile, error) {
+	files, err := m.profileFiles()
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]*DeviceProfile, 0, len(files))
+	for _, path := range files {
+		profile, err := readProfile(path)
+		if err != nil {
+			m.modbusErrorMsg("getProfiles(): ", err)
+			continue
+		}
+		profile.Points = nil
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].ID < profiles[j].ID
+	})
+	return profiles, nil
+}
+
+func (m *Module) getProfile(id string) (*DeviceProfile, error) {
+	files, err := m.profileFiles()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := files[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to find device profile %s", id)
+	}
+	return readProfile(path)
+}
+
+// applyProfile creates the points of the profile on the device. Either all points are created, or none of them.
+func (m *Module) applyProfile(deviceUUID, profileId string) ([]*model.Point, error) {
+	profile, err := m.getProfile(profileId)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID, &nmodule.Opts{Args: &nargs.Args{WithPoints: true}})
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+
+	// check every point before creating any of them
+	names := make(map[string]bool)
+	for _, pnt := range dev.Points {
+		names[pnt.Name] = true
+	}
+	for i, pp := range profile.Points {
+		if strings.TrimSpace(pp.Name) == "" {
+			return nil, fmt.Errorf("profile point %d has no name", i+1)
+		}
+		if names[pp.Name] {
+			return nil, fmt.Errorf("point %s already exists on device %s", pp.Name, dev.Name)
+		}
+		names[pp.Name] = true
+		if err = validatePoint(pp.toPoint(dev.UUID)); err != nil {
+			return nil, fmt.Errorf("point %s: %v", pp.Name, err)
+		}
+	}
+
+	points := make([]*model.Point, 0, len(profile.Points))
+	for _, pp := range profile.Points {
+		pnt, err := m.addPoint(pp.toPoint(dev.UUID))
+		if err != nil {
+			m.modbusErrorMsg(fmt.Sprintf("applyProfile(): failed to create point %s, removing the %d points created", pp.Name, len(points)))
+			for _, created := range points {
+				if _, deleteErr := m.deletePoint(created); deleteErr != nil {
+					m.modbusErrorMsg("applyProfile(): failed to remove point ", created.UUID, " err: ", deleteErr)
+				}
+			}
+			return nil, fmt.Errorf("failed to create point %s: %v", pp.Name, err)
+		}
+		points = append(points, pnt)
+	}
+	return points, nil
+}
+
+// exportProfile saves the points of the device as a new device profile.
+func (m *Module) exportProfile(deviceUUID string, body *ExportProfile) (*DeviceProfile, error) {
+	if body == nil || !profileIdRegex.MatchString(body.ID) {
+		return nil, errors.New("profile id is required, and can only have letters, numbers, '_', '-' and '.'")
+	}
+	files, err := m.profileFiles()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := files[body.ID]; ok {
+		return nil, fmt.Errorf("device profile %s already exists", body.ID)
+	}
+	dev, err := m.grpcMarshaller.GetDevice(deviceUUID, &nmodule.Opts{Args: &nargs.Args{WithPoints: true}})
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", deviceUUID)
+	}
+
+	profile := &DeviceProfile{ID: body.ID, Name: body.Name, Description: body.Description}
+	if profile.Name == "" {
+		profile.Name = dev.Name
+	}
+	for _, pnt := range dev.Points {
+		profile.Points = append(profile.Points, newProfilePoint(pnt))
+	}
+	sort.SliceStable(profile.Points, func(i, j int) bool {
+		return profile.Points[i].AddressID < profile.Points[j].AddressID
+	})
+	profile.PointCount = len(profile.Points)
+
+	data, err := yaml.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(m.config.DeviceProfilesDir, 0755); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(m.config.DeviceProfilesDir, body.ID+".yaml"), data, 0644); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+// requestRetryType returns how a point is retried after its request failed with an error of the class.
+func requestRetryType(class smod.ErrorClass) pollqueue.PollRetryType {
+	switch class {
+	case smod.ErrorIllegalFunction, smod.ErrorIllegalAddress: // the point won't work until it is changed
+		return pollqueue.NEVER_RETRY
+	case smod.ErrorValueRange: // the write won't work until the write value is changed
+		return pollqueue.NEVER_RETRY
+	case smod.ErrorIllegalValue, smod.ErrorDeviceFailure, smod.ErrorDeviceBusy, smod.ErrorGatewayPath,
+		smod.ErrorGatewayTarget, smod.ErrorUnknownException, smod.ErrorConnection:
+		return pollqueue.DELAYED_RETRY
+	case smod.ErrorPortUnavailable: // polling pauses until the port is re-opened, then the point is polled first
+		return pollqueue.IMMEDIATE_RETRY
+	default: // timeouts and corrupted responses, which are often gone on the next request
+		return pollqueue.IMMEDIATE_RETRY
+	}
+}
+
+// isMisconfigured checks if the error means that the point doesn't match the device, eg: a wrong register address.
+func isMisconfigured(class smod.ErrorClass) bool {
+	return class == smod.ErrorIllegalFunction || class == smod.ErrorIllegalAddress
+}
+
+// pointRequestFailed sets the fault of the point from the error of its request, and returns how it should be retried.
+// Misconfigured points get a config error fault, other errors get faultCode.
+func (m *Module) pointRequestFailed(pnt *model.Point, err error, faultCode string) pollqueue.PollRetryType {
+	requestErr := smod.ClassifyError(err)
+	message := fmt.Sprintf("%s (%s)", err.Error(), requestErr.Class)
+	if isMisconfigured(requestErr.Class) {
+		message = fmt.Sprintf("misconfigured point, check its register and object type: %s", message)
+		faultCode = dto.CommonFaultCode.ConfigError
+	}
+	_ = m.internalPointUpdateErr(pnt, message, dto.MessageLevel.Fail, faultCode)
+	return requestRetryType(requestErr.Class)
+}
+
+// requestResult is the result of a request, for the request counters of the device.
+func requestResult(requestErr *smod.RequestError) pollqueue.RequestResult {
+	switch {
+	case requestErr == nil:
+		return pollqueue.REQUEST_OK
+	case requestErr.IsException():
+		return pollqueue.REQUEST_EXCEPTION
+	case requestErr.Class == smod.ErrorTimeout:
+		return pollqueue.REQUEST_TIMEOUT
+	case requestErr.Class == smod.ErrorCRC:
+		return pollqueue.REQUEST_CRC_ERROR
+	}
+	return pollqueue.REQUEST_FAILED
+}
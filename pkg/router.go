@@ -13,9 +13,10 @@ import (
 )
 
 type Scan struct {
-	Start  uint32 `json:"start"`
-	Count  uint32 `json:"count"`
-	IsCoil bool   `json:"is_coil"`
+	Start     uint32 `json:"start"`
+	Count     uint32 `json:"count"`
+	IsCoil    bool   `json:"is_coil"`
+	ChunkSize uint16 `json:"chunk_size"` // registers (or bits) per request of a register scan
 }
 
 type Body struct {
@@ -52,17 +53,57 @@ func InitRouter() {
 	route.Handle(nhttp.POST, "/api/networks", CreateNetwork)
 	route.Handle(nhttp.PATCH, "/api/networks/:uuid", UpdateNetwork)
 	route.Handle(nhttp.DELETE, "/api/networks/:uuid", DeleteNetwork)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/read", NetworkRead)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/write", NetworkWrite)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/scan/devices", ScanDevices)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/scan/registers", ScanRegisters)
+	route.Handle(nhttp.GET, "/api/networks/:uuid/csv", ExportNetworkCSV)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/csv", ImportNetworkCSV)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/capture", StartTrafficCapture)
+	route.Handle(nhttp.DELETE, "/api/networks/:uuid/capture", StopTrafficCapture)
+	route.Handle(nhttp.GET, "/api/networks/:uuid/capture", GetTrafficCapture)
+	route.Handle(nhttp.GET, "/api/networks/:uuid/capture/pcap", GetTrafficCapturePcap)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/capture/export", ExportTrafficCapture)
+	route.Handle(nhttp.GET, "/api/networks/:uuid/polling", GetPollingControl)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/polling/pause", PauseNetworkPolling)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/polling/resume", ResumeNetworkPolling)
+	route.Handle(nhttp.POST, "/api/networks/:uuid/polling/flush", FlushNetworkPolling)
+	route.Handle(nhttp.GET, "/api/scans/:id", GetScan)
+	route.Handle(nhttp.DELETE, "/api/scans/:id", CancelScan)
 
 	route.Handle(nhttp.POST, "/api/devices", CreateDevice)
 	route.Handle(nhttp.PATCH, "/api/devices/:uuid", UpdateDevice)
 	route.Handle(nhttp.DELETE, "/api/devices/:uuid", DeleteDevice)
+	route.Handle(nhttp.GET, "/api/devices/:uuid/connection", GetDeviceConnection)
+	route.Handle(nhttp.POST, "/api/devices/:uuid/polling/pause", PauseDevicePolling)
+	route.Handle(nhttp.POST, "/api/devices/:uuid/polling/resume", ResumeDevicePolling)
+	route.Handle(nhttp.POST, "/api/devices/:uuid/polling/poll", PollDeviceNow)
+	route.Handle(nhttp.GET, "/api/connections", GetConnections)
 
 	route.Handle(nhttp.POST, "/api/points", CreatePoint)
 	route.Handle(nhttp.PATCH, "/api/points/:uuid", UpdatePoint)
 	route.Handle(nhttp.DELETE, "/api/points/:uuid", DeletePoint)
 	route.Handle(nhttp.PATCH, "/api/points/:uuid/write", PointWrite)
+	route.Handle(nhttp.POST, "/api/points/:uuid/string", PointWriteString)
+	route.Handle(nhttp.POST, "/api/points/:uuid/polling/poll", PollPointNow)
+
+	route.Handle(nhttp.GET, "/api/profiles", GetProfiles)
+	route.Handle(nhttp.GET, "/api/profiles/:id", GetProfile)
+	route.Handle(nhttp.POST, "/api/devices/:uuid/profiles/:id/apply", ApplyProfile)
+	route.Handle(nhttp.POST, "/api/devices/:uuid/profiles/export", ExportDeviceProfile)
 
 	route.Handle(nhttp.GET, "/api/polling/stats/network/name/:name", GetNetworkPollingStats)
+	route.Handle(nhttp.GET, "/api/polling/stats/network/name/:name/devices", GetNetworkDevicePollStats)
+	route.Handle(nhttp.DELETE, "/api/polling/stats/network/name/:name/devices", ResetNetworkPollStats)
+	route.Handle(nhttp.GET, "/api/polling/stats/network/uuid/:uuid", GetNetworkPollingStatsByUUID)
+	route.Handle(nhttp.GET, "/api/polling/stats/network/uuid/:uuid/devices", GetNetworkDevicePollStatsByUUID)
+	route.Handle(nhttp.DELETE, "/api/polling/stats/network/uuid/:uuid/devices", ResetNetworkPollStatsByUUID)
+	route.Handle(nhttp.GET, "/api/polling/stats/devices/:uuid", GetDevicePollStats)
+	route.Handle(nhttp.DELETE, "/api/polling/stats/devices/:uuid", ResetDevicePollStats)
+	route.Handle(nhttp.GET, "/api/polling/stats/points/:uuid", GetPointPollStats)
+	route.Handle(nhttp.DELETE, "/api/polling/stats/points/:uuid", ResetPointPollStats)
+	route.Handle(nhttp.GET, "/api/polling/queue/network/uuid/:uuid", GetPollQueueSnapshot)
+	route.Handle(nhttp.GET, "/api/metrics", GetMetrics)
 }
 
 func GetNetworkSchema(m *nmodule.Module, r *router.Request) ([]byte, error) {
@@ -111,6 +152,116 @@ func DeleteNetwork(m *nmodule.Module, r *router.Request) ([]byte, error) {
 	return json.Marshal(ok)
 }
 
+func NetworkRead(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *Body
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := (*m).(*Module).networkOperation(r.PathParams["uuid"], body, false)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+func NetworkWrite(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *Body
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := (*m).(*Module).networkOperation(r.PathParams["uuid"], body, true)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+func ScanDevices(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *Body
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	job, err := (*m).(*Module).startScan(r.PathParams["uuid"], body, scanTypeDevices)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(job)
+}
+
+func ScanRegisters(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *Body
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	job, err := (*m).(*Module).startScan(r.PathParams["uuid"], body, scanTypeRegisters)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(job)
+}
+
+func StartTrafficCapture(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *TrafficCaptureBody
+	if len(r.Body) > 0 {
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			return nil, err
+		}
+	}
+	capture, err := (*m).(*Module).startTrafficCapture(r.PathParams["uuid"], body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(capture)
+}
+
+func StopTrafficCapture(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	capture, err := (*m).(*Module).stopTrafficCapture(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(capture)
+}
+
+func GetTrafficCapture(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	capture, err := (*m).(*Module).getTrafficCaptureFrames(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(capture)
+}
+
+func GetTrafficCapturePcap(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	return (*m).(*Module).exportTrafficCapturePcap(r.PathParams["uuid"])
+}
+
+func ExportTrafficCapture(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	export, err := (*m).(*Module).exportTrafficCaptureFile(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(export)
+}
+
+func GetScan(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	job, err := (*m).(*Module).getScanJob(r.PathParams["id"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(job)
+}
+
+func CancelScan(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	job, err := (*m).(*Module).cancelScanJob(r.PathParams["id"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(job)
+}
+
 func CreateDevice(m *nmodule.Module, r *router.Request) ([]byte, error) {
 	var device *model.Device
 	err := json.Unmarshal(r.Body, &device)
@@ -200,6 +351,144 @@ func PointWrite(m *nmodule.Module, r *router.Request) ([]byte, error) {
 	return json.Marshal(pnt)
 }
 
+func PointWriteString(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *StringWrite
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	pnt, err := (*m).(*Module).writePointString(r.PathParams["uuid"], body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(pnt)
+}
+
+func GetDeviceConnection(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	state, err := (*m).(*Module).getDeviceConnection(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+func GetConnections(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	return json.Marshal((*m).(*Module).getConnections())
+}
+
+func ExportNetworkCSV(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	return (*m).(*Module).exportNetworkCSV(r.PathParams["uuid"])
+}
+
+func ImportNetworkCSV(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	result, err := (*m).(*Module).importNetworkCSV(r.PathParams["uuid"], r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func GetProfiles(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	profiles, err := (*m).(*Module).getProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(profiles)
+}
+
+func GetProfile(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	profile, err := (*m).(*Module).getProfile(r.PathParams["id"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(profile)
+}
+
+func ApplyProfile(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	points, err := (*m).(*Module).applyProfile(r.PathParams["uuid"], r.PathParams["id"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(points)
+}
+
+func ExportDeviceProfile(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	var body *ExportProfile
+	err := json.Unmarshal(r.Body, &body)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := (*m).(*Module).exportProfile(r.PathParams["uuid"], body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(profile)
+}
+
+func GetPollingControl(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).getPollingControl(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func PauseNetworkPolling(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).pauseNetworkPolling(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func ResumeNetworkPolling(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).resumeNetworkPolling(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func FlushNetworkPolling(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).flushNetworkPolling(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func PauseDevicePolling(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).pauseDevicePolling(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func ResumeDevicePolling(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	control, err := (*m).(*Module).resumeDevicePolling(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(control)
+}
+
+func PollDeviceNow(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).pollDeviceNow(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
+func PollPointNow(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).pollPointNow(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
 func GetNetworkPollingStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
 	stats, err := (*m).(*Module).getPollingStats(r.PathParams["name"])
 	if err != nil {
@@ -207,3 +496,87 @@ func GetNetworkPollingStats(m *nmodule.Module, r *router.Request) ([]byte, error
 	}
 	return json.Marshal(stats)
 }
+
+func GetNetworkDevicePollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	stats, err := (*m).(*Module).getNetworkDevicePollStats(r.PathParams["name"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stats)
+}
+
+func ResetNetworkPollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).resetNetworkPollStats(r.PathParams["name"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
+func GetNetworkPollingStatsByUUID(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	stats, err := (*m).(*Module).getPollingStatsByNetworkUUID(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stats)
+}
+
+func GetNetworkDevicePollStatsByUUID(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	stats, err := (*m).(*Module).getNetworkDevicePollStatsByUUID(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stats)
+}
+
+func ResetNetworkPollStatsByUUID(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).resetNetworkPollStatsByUUID(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
+func GetPollQueueSnapshot(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	snapshot, err := (*m).(*Module).getPollQueueSnapshot(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(snapshot)
+}
+
+func GetDevicePollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	stats, err := (*m).(*Module).getDevicePollStats(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stats)
+}
+
+func ResetDevicePollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).resetDevicePollStats(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
+func GetPointPollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	stats, err := (*m).(*Module).getPointPollStats(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stats)
+}
+
+func ResetPointPollStats(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	ok, err := (*m).(*Module).resetPointPollStats(r.PathParams["uuid"])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ok)
+}
+
+func GetMetrics(m *nmodule.Module, r *router.Request) ([]byte, error) {
+	return (*m).(*Module).getMetrics(), nil
+}
@@ -0,0 +1,342 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/nuuid"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/grid-x/modbus"
+)
+
+const (
+	scanJobExpiry = time.Hour // finished jobs can be fetched for this long
+
+	scanTypeDevices   = "devices"
+	scanTypeRegisters = "registers"
+
+	scanStatusRunning   = "running"
+	scanStatusDone      = "done"
+	scanStatusCancelled = "cancelled"
+	scanStatusFailed    = "failed"
+
+	registerReadable       = "readable"
+	registerIllegalAddress = "illegal_address"
+	registerTimeout        = "timeout"
+	registerError          = "error"
+
+	minUnitId            = 1
+	maxUnitId            = 247
+	defaultScanChunkSize = 10
+)
+
+// ScanRegister is the result of a single register (or bit) of a register scan.
+type ScanRegister struct {
+	Address uint16  `json:"address"` // protocol address, starting at 0
+	Status  string  `json:"status"`
+	Value   *uint16 `json:"value,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// ScanJob is a device or register scan that runs in the background, between the polls of the network.
+type ScanJob struct {
+	ID            string            `json:"id"`
+	NetworkUUID   string            `json:"network_uuid"`
+	Type          string            `json:"type"`
+	Status        string            `json:"status"`
+	Progress      float64           `json:"progress"` // percent
+	Error         string            `json:"error,omitempty"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       *time.Time        `json:"end_time,omitempty"`
+	Devices       []uint8           `json:"devices,omitempty"`        // unit ids that answered
+	Registers     []*ScanRegister   `json:"registers,omitempty"`      // every scanned register, by address
+	LiveRegisters map[uint16]uint16 `json:"live_registers,omitempty"` // values of the readable registers, by address
+
+	mutex  sync.Mutex
+	cancel func()
+}
+
+func scanJobKey(id string) string {
+	return "scan-job-" + id
+}
+
+// snapshot copies the job, so it can be returned while the scan is running.
+func (j *ScanJob) snapshot() *ScanJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	out := &ScanJob{
+		ID:          j.ID,
+		NetworkUUID: j.NetworkUUID,
+		Type:        j.Type,
+		Status:      j.Status,
+		Progress:    j.Progress,
+		Error:       j.Error,
+		StartTime:   j.StartTime,
+		EndTime:     j.EndTime,
+		Devices:     append([]uint8(nil), j.Devices...),
+		Registers:   append([]*ScanRegister(nil), j.Registers...),
+	}
+	if j.LiveRegisters != nil {
+		out.LiveRegisters = make(map[uint16]uint16, len(j.LiveRegisters))
+		for address, value := range j.LiveRegisters {
+			out.LiveRegisters[address] = value
+		}
+	}
+	return out
+}
+
+func (j *ScanJob) setProgress(done, total int) {
+	j.mutex.Lock()
+	j.Progress = float64(done) / float64(total) * 100
+	j.mutex.Unlock()
+}
+
+func (j *ScanJob) finish(ctx context.Context, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	j.EndTime = &now
+	if err != nil {
+		j.Status = scanStatusFailed
+		j.Error = err.Error()
+	} else if ctx.Err() != nil {
+		j.Status = scanStatusCancelled
+	} else {
+		j.Status = scanStatusDone
+		j.Progress = 100
+	}
+}
+
+// startScan validates the scan and starts it in the background. The returned job is polled with getScanJob.
+func (m *Module) startScan(netUUID string, body *Body, scanType string) (*ScanJob, error) {
+	if body == nil {
+		return nil, errors.New("empty body")
+	}
+	net, err := m.grpcMarshaller.GetNetwork(netUUID)
+	if err != nil || net == nil {
+		return nil, fmt.Errorf("failed to find network %s", netUUID)
+	}
+	if isServerNetwork(net) {
+		return nil, errors.New("scans are not supported on tcp_server networks")
+	}
+	port := 502
+	if body.Client.Port != "" {
+		port, err = strconv.Atoi(body.Client.Port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s", body.Client.Port)
+		}
+	}
+	dev := &model.Device{Name: "scan", AddressId: int(body.Operation.UnitId), Host: body.Client.Host, Port: port}
+
+	job := &ScanJob{
+		ID:          nuuid.ShortUUID("scn"),
+		NetworkUUID: net.UUID,
+		Type:        scanType,
+		Status:      scanStatusRunning,
+		StartTime:   time.Now(),
+	}
+	ctx, cancel := context.WithCancel(m.pollingContext)
+	job.cancel = cancel
+	m.store.Set(scanJobKey(job.ID), job, scanJobExpiry)
+
+	scan := body.Scan
+	op := body.Operation
+	go func() {
+		defer cancel()
+		var scanErr error
+		if scanType == scanTypeDevices {
+			scanErr = m.scanDevices(ctx, job, net, dev, &scan, &op)
+		} else {
+			scanErr = m.scanRegisters(ctx, job, net, dev, &scan, &op)
+		}
+		job.finish(ctx, scanErr)
+		m.modbusDebugMsg(fmt.Sprintf("scan %s finished, network: %s, status: %s", job.ID, net.Name, job.snapshot().Status))
+	}()
+	return job.snapshot(), nil
+}
+
+func (m *Module) getScanJob(id string) (*ScanJob, error) {
+	job, ok := m.store.Get(scanJobKey(id))
+	if !ok {
+		return nil, fmt.Errorf("failed to find scan %s", id)
+	}
+	return job.(*ScanJob).snapshot(), nil
+}
+
+func (m *Module) cancelScanJob(id string) (*ScanJob, error) {
+	job, ok := m.store.Get(scanJobKey(id))
+	if !ok {
+		return nil, fmt.Errorf("failed to find scan %s", id)
+	}
+	job.(*ScanJob).cancel()
+	return job.(*ScanJob).snapshot(), nil
+}
+
+// scanDevices sends one read to each unit id of the range. A device answered if it returns data or a modbus exception.
+func (m *Module) scanDevices(ctx context.Context, job *ScanJob, net *model.Network, dev *model.Device, scan *Scan, op *Operation) error {
+	first := int(scan.Start)
+	if first < minUnitId {
+		first = minUnitId
+	}
+	last := maxUnitId
+	if scan.Count > 0 && first+int(scan.Count)-1 < maxUnitId {
+		last = first + int(scan.Count) - 1
+	}
+	if first > last {
+		return fmt.Errorf("unit ids must be between %d and %d", minUnitId, maxUnitId)
+	}
+	regType := objectRegType(operationObjectType(op))
+
+	for unitId := first; unitId <= last; unitId++ {
+		if ctx.Err() != nil {
+			return nil
+		}
+		dev.AddressId = unitId
+		var readErr error
+		err := m.runOnNetwork(net, dev, func(mbClient *smod.ModbusClient) {
+			_, readErr = mbClient.ReadBlock(op.Addr, 1, regType)
+		})
+		if err != nil {
+			return err
+		}
+		var mbErr *modbus.Error
+		if readErr == nil || errors.As(readErr, &mbErr) {
+			job.mutex.Lock()
+			job.Devices = append(job.Devices, uint8(unitId))
+			job.mutex.Unlock()
+		}
+		job.setProgress(unitId-first+1, last-first+1)
+	}
+	return nil
+}
+
+// scanRegisters reads the register range of one device in chunks. A chunk with an illegal address is read again one
+// register at a time, so that each register can be classified.
+func (m *Module) scanRegisters(ctx context.Context, job *ScanJob, net *model.Network, dev *model.Device, scan *Scan, op *Operation) error {
+	objectType := operationObjectType(op)
+	if scan.IsCoil {
+		objectType = string(datatype.ObjTypeCoil)
+	}
+	regType := objectRegType(objectType)
+	isBits := regType == smod.Coil || regType == smod.DiscreteInput
+
+	count := int(scan.Count)
+	if count <= 0 || int(scan.Start)+count > 0x10000 {
+		return errors.New("scan count must be at least 1, and the range must end before address 65536")
+	}
+	chunkSize := int(scan.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = defaultScanChunkSize
+	}
+	if isBits && chunkSize > maxBitBlockLength {
+		chunkSize = maxBitBlockLength
+	} else if !isBits && chunkSize > maxRegisterBlockLength {
+		chunkSize = maxRegisterBlockLength
+	}
+	job.mutex.Lock()
+	job.LiveRegisters = make(map[uint16]uint16)
+	job.mutex.Unlock()
+
+	// read runs one request between polls, and returns the register values. err is only set if the request couldn't be sent.
+	read := func(addr uint16, quantity uint16) (values []uint16, readErr error, err error) {
+		var raw []byte
+		err = m.runOnNetwork(net, dev, func(mbClient *smod.ModbusClient) {
+			raw, readErr = mbClient.ReadBlock(addr, quantity, regType)
+		})
+		if err != nil || readErr != nil {
+			return
+		}
+		values = make([]uint16, quantity)
+		for i := range values {
+			if isBits {
+				if i/8 < len(raw) && (raw[i/8]>>(uint(i)%8))&0x01 == 0x01 {
+					values[i] = 1
+				}
+			} else if (i+1)*2 <= len(raw) {
+				values[i] = binary.BigEndian.Uint16(raw[i*2:])
+			}
+		}
+		return
+	}
+
+	start := int(scan.Start)
+	end := start + count
+	for chunkStart := start; chunkStart < end; chunkStart += chunkSize {
+		if ctx.Err() != nil {
+			return nil
+		}
+		quantity := chunkSize
+		if chunkStart+quantity > end {
+			quantity = end - chunkStart
+		}
+
+		values, readErr, err := read(uint16(chunkStart), uint16(quantity))
+		if err != nil {
+			return err
+		}
+		results := make([]*ScanRegister, 0, quantity)
+		if readErr == nil {
+			for i, value := range values {
+				results = append(results, newScanRegister(uint16(chunkStart+i), &value, nil))
+			}
+		} else if scanRegisterStatus(readErr) == registerIllegalAddress && quantity > 1 {
+			for address := chunkStart; address < chunkStart+quantity; address++ {
+				if ctx.Err() != nil {
+					return nil
+				}
+				value, addressErr, err := read(uint16(address), 1)
+				if err != nil {
+					return err
+				}
+				if addressErr == nil {
+					results = append(results, newScanRegister(uint16(address), &value[0], nil))
+				} else {
+					results = append(results, newScanRegister(uint16(address), nil, addressErr))
+				}
+			}
+		} else {
+			// no response (or another exception) for the whole chunk, so don't spend the bus time on each register
+			for address := chunkStart; address < chunkStart+quantity; address++ {
+				results = append(results, newScanRegister(uint16(address), nil, readErr))
+			}
+		}
+
+		job.mutex.Lock()
+		job.Registers = append(job.Registers, results...)
+		for _, result := range results {
+			if result.Value != nil {
+				job.LiveRegisters[result.Address] = *result.Value
+			}
+		}
+		job.mutex.Unlock()
+		job.setProgress(chunkStart+quantity-start, count)
+	}
+	return nil
+}
+
+func newScanRegister(address uint16, value *uint16, err error) *ScanRegister {
+	if err != nil {
+		return &ScanRegister{Address: address, Status: scanRegisterStatus(err), Error: err.Error()}
+	}
+	v := *value
+	return &ScanRegister{Address: address, Status: registerReadable, Value: &v}
+}
+
+// scanRegisterStatus classifies a failed read. Any failure that isn't a modbus exception is treated as no response.
+func scanRegisterStatus(err error) string {
+	var mbErr *modbus.Error
+	if errors.As(err, &mbErr) {
+		if mbErr.ExceptionCode == modbus.ExceptionCodeIllegalDataAddress {
+			return registerIllegalAddress
+		}
+		return registerError
+	}
+	return registerTimeout
+}
@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/grid-x/modbus"
+)
+
+const (
+	serialPortRetryMin = 5 * time.Second // delay of the first re-open of an unavailable serial port, doubled on each fail
+	serialPortRetryMax = 2 * time.Minute
+
+	serialPortFaultMessage = "serial port unavailable"
+)
+
+// portTransporter flags the client when a request fails because its serial port is unavailable, eg: the device node
+// is gone or the port returns I/O errors.
+type portTransporter struct {
+	transporter modbus.Transporter
+	client      *smod.ModbusClient
+}
+
+func (t *portTransporter) Send(aduRequest []byte) ([]byte, error) {
+	aduResponse, err := t.transporter.Send(aduRequest)
+	if requestErr := smod.ClassifyError(err); requestErr != nil && requestErr.Class == smod.ErrorPortUnavailable {
+		t.client.PortUnavailable = true
+	}
+	return aduResponse, err
+}
+
+func networkSerialPort(network *model.Network) string {
+	if network.SerialPort != nil && *network.SerialPort != "" {
+		return *network.SerialPort
+	}
+	return "/dev/ttyUSB0"
+}
+
+// checkSerialPort checks that the device node of the serial port exists.
+func checkSerialPort(serialPort string) error {
+	if _, err := os.Stat(serialPort); err != nil {
+		return fmt.Errorf("serial port %s not found: %w", serialPort, err)
+	}
+	return nil
+}
+
+// checkSerialClient pauses polling of the network if a request of its client failed because the serial port is
+// unavailable. Caller must hold the BusLock of the network.
+func (m *Module) checkSerialClient(netPollMan *pollqueue.NetworkPollManager, networkUUID string) {
+	mbClient, ok := m.mbClients[networkUUID]
+	if !ok || !mbClient.PortUnavailable {
+		return
+	}
+	network, err := m.grpcMarshaller.GetNetwork(networkUUID)
+	if err != nil || network == nil {
+		m.closeSerialClient(networkUUID)
+		return
+	}
+	m.serialPortFailed(netPollMan, network, fmt.Errorf("serial port %s failed", networkSerialPort(network)))
+}
+
+// serialPortFailed pauses polling of the network while its serial port is unavailable, and raises a network fault
+// until the port is re-opened. Caller must hold the BusLock of the network.
+func (m *Module) serialPortFailed(netPollMan *pollqueue.NetworkPollManager, network *model.Network, err error) {
+	m.closeSerialClient(network.UUID)
+	if netPollMan.PortUnavailableTimeout != nil {
+		return
+	}
+	m.modbusErrorMsg(fmt.Sprintf("serial port unavailable, polling paused. network: %s, err: %v", network.Name, err))
+	_ = m.networkUpdateErr(network, fmt.Sprintf("%s: %v", serialPortFaultMessage, err), dto.MessageLevel.Fail, dto.CommonFaultCode.NetworkError)
+	netPollMan.PortUnavailable()
+	m.scheduleSerialPortRetry(netPollMan, network.UUID, serialPortRetryMin)
+}
+
+func (m *Module) closeSerialClient(networkUUID string) {
+	mbClient, ok := m.mbClients[networkUUID]
+	if !ok {
+		return
+	}
+	if mbClient.RTUClientHandler != nil {
+		_ = mbClient.RTUClientHandler.Close()
+	}
+	if mbClient.ASCIIClientHandler != nil {
+		_ = mbClient.ASCIIClientHandler.Close()
+	}
+	delete(m.mbClients, networkUUID)
+}
+
+func (m *Module) scheduleSerialPortRetry(netPollMan *pollqueue.NetworkPollManager, networkUUID string, delay time.Duration) {
+	netPollMan.PortUnavailableTimeout = time.AfterFunc(delay, func() {
+		m.retrySerialPort(netPollMan, networkUUID, delay)
+	})
+}
+
+// retrySerialPort re-opens the serial port of the network, and resumes polling if it opens. Otherwise, it is retried
+// after twice the delay, up to serialPortRetryMax.
+func (m *Module) retrySerialPort(netPollMan *pollqueue.NetworkPollManager, networkUUID string, delay time.Duration) {
+	netPollMan.BusLock.Lock()
+	defer netPollMan.BusLock.Unlock()
+	if current, err := m.getNetworkPollManagerByUUID(networkUUID); err != nil || current != netPollMan {
+		return // the network was deleted or the module was disabled
+	}
+	network, err := m.grpcMarshaller.GetNetwork(networkUUID)
+	if err != nil || network == nil {
+		m.modbusErrorMsg("retrySerialPort(): network not found ", networkUUID)
+		return
+	}
+	if !boolean.IsTrue(network.Enable) { // the port is opened again when the network is enabled
+		netPollMan.PortRetryStopped()
+		if strings.HasPrefix(network.CommonFault.Message, serialPortFaultMessage) {
+			_ = m.networkUpdateMessage(network, "", dto.MessageLevel.Normal, dto.CommonFaultCode.Ok)
+		}
+		return
+	}
+	mbClient, err := m.setClient(network, nil, true)
+	if err != nil {
+		delay *= 2
+		if delay > serialPortRetryMax {
+			delay = serialPortRetryMax
+		}
+		m.modbusErrorMsg(fmt.Sprintf("serial port still unavailable, retry in %s. network: %s, err: %v", delay, network.Name, err))
+		m.scheduleSerialPortRetry(netPollMan, networkUUID, delay)
+		return
+	}
+	m.mbClients[networkUUID] = mbClient
+	netPollMan.PortAvailable()
+	m.modbusPollingMsg(fmt.Sprintf("serial port available, polling resumed. network: %s", network.Name))
+	_ = m.networkUpdateMessage(network, "serial port available", dto.MessageLevel.Normal, dto.CommonFaultCode.Ok)
+}
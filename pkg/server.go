@@ -0,0 +1,357 @@
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/mbserver"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/nargs"
+)
+
+const (
+	defaultServerIP       = "0.0.0.0"
+	defaultServerPort     = 502
+	serverRefreshInterval = time.Second
+)
+
+// serverPoint is a point on the register map of a server network device.
+type serverPoint struct {
+	pnt        *model.Point
+	regType    smod.RegType
+	address    uint16
+	dataType   string
+	endianness smod.Endianness
+	wordOrder  smod.WordOrder
+	registers  []uint16 // point value as it is sent to masters, one register per bit for coils and discrete inputs
+}
+
+// serverUnit is the register map of a server network device, by register type and register address.
+type serverUnit map[smod.RegType]map[uint16]*serverPoint
+
+// modbusServer serves the points of a server network to modbus masters. Each device of the network is a unit id.
+type modbusServer struct {
+	module      *Module
+	networkUUID string
+	address     string
+	server      *mbserver.Server
+	cancel      func()
+	mutex       sync.RWMutex
+	units       map[uint8]serverUnit
+}
+
+func isServerNetwork(network *model.Network) bool {
+	return network.TransportType == transTypeTCPServer
+}
+
+func serverAddress(network *model.Network) string {
+	ip := network.IP
+	if ip == "" {
+		ip = defaultServerIP
+	}
+	port := defaultServerPort
+	if network.Port != nil && *network.Port > 0 {
+		port = *network.Port
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}
+
+func (m *Module) initiateServer(ctx context.Context, network *model.Network) {
+	s := &modbusServer{module: m, networkUUID: network.UUID, units: make(map[uint8]serverUnit)}
+	m.servers[network.UUID] = s
+	s.update(ctx, network)
+}
+
+func (m *Module) getServerByNetworkUUID(netUUID string) *modbusServer {
+	return m.servers[netUUID]
+}
+
+// getServerByPointUUID returns the server that serves the point, or nil if the point is on a polled network.
+func (m *Module) getServerByPointUUID(pntUUID string) *modbusServer {
+	if len(m.servers) == 0 {
+		return nil
+	}
+	pnt, err := m.grpcMarshaller.GetPoint(pntUUID)
+	if err != nil || pnt == nil {
+		return nil
+	}
+	dev, err := m.grpcMarshaller.GetDevice(pnt.DeviceUUID)
+	if err != nil || dev == nil {
+		return nil
+	}
+	return m.getServerByNetworkUUID(dev.NetworkUUID)
+}
+
+func (m *Module) stopServers() {
+	for _, s := range m.servers {
+		s.stop()
+	}
+	m.servers = nil
+}
+
+// update starts, stops or restarts the server to match the network. Masters stay connected if nothing changed.
+func (s *modbusServer) update(ctx context.Context, network *model.Network) {
+	address := serverAddress(network)
+	enable := boolean.IsTrue(network.Enable)
+	if s.server != nil && enable && address == s.address {
+		return
+	}
+	s.stop()
+	if !enable {
+		return
+	}
+
+	server := mbserver.NewServer(address, s)
+	if err := server.Start(); err != nil {
+		s.module.modbusErrorMsg(fmt.Sprintf("modbus server: failed to listen on %s, err: %v", address, err))
+		_ = s.module.networkUpdateErr(network, fmt.Sprintf("failed to listen on %s: %v", address, err), dto.MessageLevel.Fail, dto.CommonFaultCode.NetworkError)
+		return
+	}
+	s.module.modbusDebugMsg(fmt.Sprintf("modbus server: listening on %s for network %s", address, network.Name))
+	s.server = server
+	s.address = address
+
+	refreshCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.refresh()
+	go func() {
+		ticker := time.NewTicker(serverRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.refresh()
+			case <-refreshCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *modbusServer) stop() {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	if s.server != nil {
+		_ = s.server.Stop()
+		s.server = nil
+	}
+}
+
+// refresh rebuilds the register maps from the network devices and points, with their latest present values.
+func (s *modbusServer) refresh() {
+	network, err := s.module.grpcMarshaller.GetNetwork(s.networkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
+	if err != nil || network == nil {
+		s.module.modbusErrorMsg("modbus server: failed to get network ", s.networkUUID, " err: ", err)
+		return
+	}
+
+	units := make(map[uint8]serverUnit)
+	for _, dev := range network.Devices {
+		if !boolean.IsTrue(dev.Enable) || dev.AddressId < 0 || dev.AddressId > 255 {
+			continue
+		}
+		unit := serverUnit{
+			smod.Coil:            make(map[uint16]*serverPoint),
+			smod.DiscreteInput:   make(map[uint16]*serverPoint),
+			smod.InputRegister:   make(map[uint16]*serverPoint),
+			smod.HoldingRegister: make(map[uint16]*serverPoint),
+		}
+		for _, pnt := range dev.Points {
+			// bitwise points share their register with other points, so they can't be served on their own
+			if !boolean.IsTrue(pnt.Enable) || boolean.IsTrue(pnt.IsBitwise) {
+				continue
+			}
+			sp, err := newServerPoint(pnt, boolean.IsTrue(dev.ZeroMode))
+			if err != nil {
+				s.module.modbusErrorMsg(fmt.Sprintf("modbus server: point %s isn't served, err: %v", pnt.Name, err))
+				continue
+			}
+			if int(sp.address)+len(sp.registers) > 0x10000 {
+				continue
+			}
+			for i := range sp.registers {
+				unit[sp.regType][sp.address+uint16(i)] = sp
+			}
+		}
+		units[uint8(dev.AddressId)] = unit
+	}
+
+	s.mutex.Lock()
+	s.units = units
+	s.mutex.Unlock()
+}
+
+func newServerPoint(pnt *model.Point, zeroMode bool) (*serverPoint, error) {
+	endianness, wordOrder := pointEncoding(pnt.ObjectEncoding)
+	sp := &serverPoint{
+		pnt:        pnt,
+		regType:    pointRegType(pnt),
+		address:    pointAddress(pnt, zeroMode),
+		dataType:   nstring.NewString(pnt.DataType).ToSnakeCase(),
+		endianness: endianness,
+		wordOrder:  wordOrder,
+	}
+	value := float.NonNil(pnt.PresentValue)
+	if sp.regType == smod.Coil || sp.regType == smod.DiscreteInput {
+		sp.registers = []uint16{0}
+		if value != 0 {
+			sp.registers[0] = 1
+		}
+		return sp, nil
+	}
+	raw, err := smod.EncodeValue(endianness, wordOrder, sp.dataType, value)
+	if err != nil {
+		return nil, err
+	}
+	sp.registers = make([]uint16, len(raw)/2)
+	for i := range sp.registers {
+		sp.registers[i] = binary.BigEndian.Uint16(raw[i*2:])
+	}
+	return sp, nil
+}
+
+// value decodes the point value from its registers.
+func (sp *serverPoint) value() float64 {
+	if sp.regType == smod.Coil || sp.regType == smod.DiscreteInput {
+		return float64(sp.registers[0])
+	}
+	raw := make([]byte, len(sp.registers)*2)
+	for i, register := range sp.registers {
+		binary.BigEndian.PutUint16(raw[i*2:], register)
+	}
+	return smod.DecodeValue(sp.endianness, sp.wordOrder, sp.dataType, raw)
+}
+
+func (s *modbusServer) read(unitId uint8, regType smod.RegType, addr, quantity uint16) ([]uint16, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	unit, ok := s.units[unitId]
+	if !ok {
+		return nil, mbserver.GatewayTargetFailedToRespond
+	}
+	if int(addr)+int(quantity) > 0x10000 {
+		return nil, mbserver.IllegalDataAddress
+	}
+	// registers between points read as 0, but at least one of the registers must be a point
+	out := make([]uint16, quantity)
+	found := false
+	for i := range out {
+		address := addr + uint16(i)
+		if sp := unit[regType][address]; sp != nil {
+			out[i] = sp.registers[address-sp.address]
+			found = true
+		}
+	}
+	if !found {
+		return nil, mbserver.IllegalDataAddress
+	}
+	return out, nil
+}
+
+func (s *modbusServer) write(unitId uint8, regType smod.RegType, addr uint16, values []uint16) error {
+	s.mutex.Lock()
+	unit, ok := s.units[unitId]
+	if !ok {
+		s.mutex.Unlock()
+		return mbserver.GatewayTargetFailedToRespond
+	}
+	if int(addr)+len(values) > 0x10000 {
+		s.mutex.Unlock()
+		return mbserver.IllegalDataAddress
+	}
+	// every written register must be on a writeable point
+	written := make([]*serverPoint, 0)
+	for i := range values {
+		sp := unit[regType][addr+uint16(i)]
+		if sp == nil || !boolean.IsTrue(sp.pnt.EnableWriteable) {
+			s.mutex.Unlock()
+			return mbserver.IllegalDataAddress
+		}
+		if len(written) == 0 || written[len(written)-1] != sp {
+			written = append(written, sp)
+		}
+	}
+	for i, value := range values {
+		address := addr + uint16(i)
+		sp := unit[regType][address]
+		sp.registers[address-sp.address] = value
+	}
+	pointValues := make([]float64, len(written))
+	for i, sp := range written {
+		pointValues[i] = sp.value()
+	}
+	s.mutex.Unlock()
+
+	for i, sp := range written {
+		if err := s.module.serverPointWrite(sp.pnt, pointValues[i]); err != nil {
+			return mbserver.ServerDeviceFailure
+		}
+	}
+	return nil
+}
+
+// serverPointWrite writes a value from a master to the point on the configured priority array slot.
+func (m *Module) serverPointWrite(pnt *model.Point, value float64) error {
+	m.modbusDebugMsg(fmt.Sprintf("modbus server: write %f to point %s", value, pnt.Name))
+	priority := map[string]*float64{fmt.Sprintf("_%d", m.config.ServerWritePriority): &value}
+	_, err := m.grpcMarshaller.PointWrite(pnt.UUID, &dto.PointWriter{Priority: &priority})
+	if err != nil {
+		m.modbusErrorMsg("modbus server: failed to write point ", pnt.UUID, " err: ", err)
+	}
+	return err
+}
+
+func (s *modbusServer) ReadCoils(unitId uint8, addr, quantity uint16) ([]bool, error) {
+	return s.readBits(unitId, smod.Coil, addr, quantity)
+}
+
+func (s *modbusServer) ReadDiscreteInputs(unitId uint8, addr, quantity uint16) ([]bool, error) {
+	return s.readBits(unitId, smod.DiscreteInput, addr, quantity)
+}
+
+func (s *modbusServer) ReadHoldingRegisters(unitId uint8, addr, quantity uint16) ([]uint16, error) {
+	return s.read(unitId, smod.HoldingRegister, addr, quantity)
+}
+
+func (s *modbusServer) ReadInputRegisters(unitId uint8, addr, quantity uint16) ([]uint16, error) {
+	return s.read(unitId, smod.InputRegister, addr, quantity)
+}
+
+func (s *modbusServer) WriteCoils(unitId uint8, addr uint16, values []bool) error {
+	registers := make([]uint16, len(values))
+	for i, value := range values {
+		if value {
+			registers[i] = 1
+		}
+	}
+	return s.write(unitId, smod.Coil, addr, registers)
+}
+
+func (s *modbusServer) WriteHoldingRegisters(unitId uint8, addr uint16, values []uint16) error {
+	return s.write(unitId, smod.HoldingRegister, addr, values)
+}
+
+func (s *modbusServer) readBits(unitId uint8, regType smod.RegType, addr, quantity uint16) ([]bool, error) {
+	registers, err := s.read(unitId, regType, addr, quantity)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bool, len(registers))
+	for i, register := range registers {
+		out[i] = register != 0
+	}
+	return out, nil
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/boolean"
+	"github.com/NubeIO/lib-utils-go/integer"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+const maxStringRegisters = 123 // most registers of a function code 16 write
+
+// StringWrite is the body of a string point write.
+type StringWrite struct {
+	Value string `json:"value"`
+}
+
+// isStringPoint checks if the point is text. Its value is stored in the point message, as PresentValue is a number.
+func isStringPoint(pnt *model.Point) bool {
+	return nstring.NewString(pnt.DataType).ToSnakeCase() == smod.TypeString
+}
+
+// stringRegisterLength returns the number of registers of a string point, which is its AddressLength.
+func stringRegisterLength(pnt *model.Point) uint16 {
+	length := integer.NonNil(pnt.AddressLength)
+	if length < 1 {
+		return 1
+	} else if length > maxStringRegisters {
+		return maxStringRegisters
+	}
+	return uint16(length)
+}
+
+func validateStringPoint(body *model.Point) error {
+	objectType := convertOldObjectType(nstring.NewString(body.ObjectType).ToSnakeCase())
+	if objectType != string(datatype.ObjTypeHoldingRegister) && objectType != string(datatype.ObjTypeInputRegister) {
+		return errors.New("string points must be holding or input registers")
+	}
+	if length := integer.NonNil(body.AddressLength); length < 1 || length > maxStringRegisters {
+		return fmt.Errorf("string points must have an address length between 1 and %d registers", maxStringRegisters)
+	}
+	if IsWriteable(body.WriteMode) {
+		return errors.New("string points must be read only, they are written with /api/points/:uuid/string")
+	}
+	if boolean.IsTrue(body.IsBitwise) {
+		return errors.New("string points can't be bitwise")
+	}
+	return nil
+}
+
+// readString reads a string point. The response is the text, and the response value is always 0.
+func readString(mbClient *smod.ModbusClient, address uint16, pnt *model.Point, regType smod.RegType) (response interface{}, responseValue float64, err error) {
+	_, response, err = mbClient.ReadString(address, stringRegisterLength(pnt), regType)
+	return
+}
+
+// internalPointStringUpdate stores the text of a string point in its message.
+func (m *Module) internalPointStringUpdate(point *model.Point, value string) error {
+	point.CommonFault.InFault = false
+	point.CommonFault.MessageLevel = dto.MessageLevel.Info
+	point.CommonFault.MessageCode = dto.CommonFaultCode.Ok
+	point.CommonFault.Message = value
+	point.CommonFault.LastOk = time.Now().UTC()
+	err := m.grpcMarshaller.UpdatePointErrors(point.UUID, point)
+	if err != nil {
+		m.modbusErrorMsg("internalPointStringUpdate()", err)
+	}
+	return err
+}
+
+// writePointString writes the text to the registers of a string point, eg: to set a device name. It runs between polls.
+func (m *Module) writePointString(pntUUID string, body *StringWrite) (*model.Point, error) {
+	if body == nil {
+		return nil, errors.New("empty body")
+	}
+	pnt, err := m.grpcMarshaller.GetPoint(pntUUID)
+	if err != nil || pnt == nil {
+		return nil, fmt.Errorf("failed to find point %s", pntUUID)
+	}
+	if !isStringPoint(pnt) {
+		return nil, fmt.Errorf("point %s is not a string point", pnt.Name)
+	}
+	if convertOldObjectType(nstring.NewString(pnt.ObjectType).ToSnakeCase()) != string(datatype.ObjTypeHoldingRegister) {
+		return nil, fmt.Errorf("point %s is not a holding register", pnt.Name)
+	}
+	length := stringRegisterLength(pnt)
+	if len(body.Value) > int(length)*2 {
+		return nil, fmt.Errorf("value is longer than the %d characters of the point", length*2)
+	}
+	dev, err := m.grpcMarshaller.GetDevice(pnt.DeviceUUID)
+	if err != nil || dev == nil {
+		return nil, fmt.Errorf("failed to find device %s", pnt.DeviceUUID)
+	}
+	net, err := m.grpcMarshaller.GetNetwork(dev.NetworkUUID)
+	if err != nil || net == nil {
+		return nil, fmt.Errorf("failed to find network %s", dev.NetworkUUID)
+	}
+	if isServerNetwork(net) {
+		return nil, errors.New("string writes are not supported on tcp_server networks")
+	}
+
+	var writeErr error
+	err = m.runOnNetwork(net, dev, func(mbClient *smod.ModbusClient) {
+		setEncoding(mbClient, pnt.ObjectEncoding)
+		m.modbusPollingMsg(fmt.Sprintf("WRITE-STRING: point: %s, addr: %d, length: %d, value: %s", pnt.Name, integer.NonNil(pnt.AddressID), length, body.Value))
+		_, writeErr = mbClient.WriteString(pointAddress(pnt, mbClient.DeviceZeroMode), length, body.Value)
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		_ = m.internalPointUpdateErr(pnt, err.Error(), dto.MessageLevel.Fail, dto.CommonFaultCode.PointWriteError)
+		return nil, err
+	}
+	_ = m.internalPointStringUpdate(pnt, body.Value)
+	return pnt, nil
+}
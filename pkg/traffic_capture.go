@@ -0,0 +1,382 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/grid-x/modbus"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	defaultTrafficCaptureSize = 1000
+	maxTrafficCaptureSize     = 100000
+
+	framingTCP   = "tcp"   // MBAP header, unit id and PDU
+	framingRTU   = "rtu"   // unit id, PDU and crc
+	framingASCII = "ascii" // ':', hex of the unit id, PDU and lrc, and "\r\n"
+
+	captureOutcomeOK        = "ok"
+	captureOutcomeException = "exception"
+)
+
+// CapturedFrame is a request sent on a network, and its response.
+type CapturedFrame struct {
+	Index         uint64    `json:"index"`
+	Time          time.Time `json:"time"` // when the request was sent
+	UnitId        uint8     `json:"unit_id"`
+	FunctionCode  uint8     `json:"function_code"`
+	Request       hexBytes  `json:"request"`            // request PDU
+	Response      hexBytes  `json:"response,omitempty"` // response PDU, empty if there was no response
+	LatencyMs     float64   `json:"latency_ms"`
+	Outcome       string    `json:"outcome"` // ok, exception, or the class of the error, eg: timeout
+	ExceptionCode uint8     `json:"exception_code,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// TrafficCapture records the requests and responses of a network in a ring buffer, for when a device misbehaves.
+type TrafficCapture struct {
+	NetworkUUID string           `json:"network_uuid"`
+	Enabled     bool             `json:"enabled"`
+	Size        int              `json:"size"`     // frames kept, older frames are overwritten
+	Captured    uint64           `json:"captured"` // frames captured since start, including overwritten ones
+	StartTime   time.Time        `json:"start_time"`
+	StopTime    *time.Time       `json:"stop_time,omitempty"`
+	Frames      []*CapturedFrame `json:"frames,omitempty"` // oldest first
+
+	mutex sync.Mutex
+	next  int // index of Frames to write the next frame to, once the buffer is full
+}
+
+type TrafficCaptureBody struct {
+	Size int `json:"size"`
+}
+
+// TrafficCaptureExport is the file a capture was exported to.
+type TrafficCaptureExport struct {
+	File   string `json:"file"`
+	Frames int    `json:"frames"`
+}
+
+type hexBytes []byte
+
+func (b hexBytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + hex.EncodeToString(b) + `"`), nil
+}
+
+func trafficCaptureKey(networkUUID string) string {
+	return "traffic-capture-" + networkUUID
+}
+
+// transportFraming returns the framing of the requests of the network, as sent by its transporter.
+func transportFraming(transportType string) string {
+	switch transportType {
+	case dto.TransType.IP:
+		return framingTCP
+	case transTypeASCII:
+		return framingASCII
+	}
+	return framingRTU
+}
+
+// captureTransporter sends the requests of a client, and records them in the capture of the network, if any.
+type captureTransporter struct {
+	transporter modbus.Transporter
+	framing     string
+	capture     func() *TrafficCapture // the capture of the network the request is sent on, or nil
+}
+
+func (t *captureTransporter) Send(aduRequest []byte) ([]byte, error) {
+	sendTime := time.Now()
+	aduResponse, err := t.transporter.Send(aduRequest)
+	if capture := t.capture(); capture != nil {
+		capture.record(t.framing, sendTime, time.Since(sendTime), aduRequest, aduResponse, err)
+	}
+	return aduResponse, err
+}
+
+func (c *TrafficCapture) record(framing string, sendTime time.Time, latency time.Duration, aduRequest, aduResponse []byte, err error) {
+	frame := &CapturedFrame{
+		Time:      sendTime,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		Outcome:   captureOutcomeOK,
+	}
+	var pdu []byte
+	frame.UnitId, pdu, _ = decodeADU(framing, aduRequest)
+	frame.Request = append(hexBytes(nil), pdu...)
+	if len(frame.Request) > 0 {
+		frame.FunctionCode = frame.Request[0]
+	}
+	if err != nil {
+		frame.Outcome = string(smod.ClassifyError(err).Class)
+		frame.Error = err.Error()
+	} else {
+		_, pdu, checkOK := decodeADU(framing, aduResponse)
+		frame.Response = append(hexBytes(nil), pdu...)
+		switch {
+		case !checkOK:
+			frame.Outcome = string(smod.ErrorCRC)
+		case len(frame.Response) >= 2 && frame.Response[0]&0x80 != 0:
+			frame.Outcome = captureOutcomeException
+			frame.ExceptionCode = frame.Response[1]
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.Enabled {
+		return
+	}
+	frame.Index = c.Captured
+	c.Captured++
+	if len(c.Frames) < c.Size {
+		c.Frames = append(c.Frames, frame)
+		return
+	}
+	c.Frames[c.next] = frame
+	c.next = (c.next + 1) % c.Size
+}
+
+// decodeADU returns the unit id and PDU of the frame, and if its crc (or lrc) is valid.
+func decodeADU(framing string, adu []byte) (unitId uint8, pdu []byte, checkOK bool) {
+	switch framing {
+	case framingTCP:
+		if len(adu) < 7 {
+			return 0, nil, true
+		}
+		return adu[6], adu[7:], true
+	case framingASCII:
+		if len(adu) < 3 || adu[0] != ':' {
+			return 0, nil, false
+		}
+		decoded, err := hex.DecodeString(string(bytes.TrimRight(adu[1:], "\r\n")))
+		if err != nil || len(decoded) < 2 {
+			return 0, nil, false
+		}
+		var sum byte
+		for _, b := range decoded[:len(decoded)-1] {
+			sum += b
+		}
+		return decoded[0], decoded[1 : len(decoded)-1], -sum == decoded[len(decoded)-1]
+	}
+	if len(adu) < 4 {
+		return 0, nil, false
+	}
+	data := adu[:len(adu)-2]
+	return adu[0], adu[1 : len(adu)-2], crc16(data) == binary.LittleEndian.Uint16(adu[len(adu)-2:])
+}
+
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&0x0001 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// snapshot copies the capture, with its frames oldest first.
+func (c *TrafficCapture) snapshot() *TrafficCapture {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	out := &TrafficCapture{
+		NetworkUUID: c.NetworkUUID,
+		Enabled:     c.Enabled,
+		Size:        c.Size,
+		Captured:    c.Captured,
+		StartTime:   c.StartTime,
+		StopTime:    c.StopTime,
+		Frames:      make([]*CapturedFrame, 0, len(c.Frames)),
+	}
+	out.Frames = append(out.Frames, c.Frames[c.next:]...)
+	out.Frames = append(out.Frames, c.Frames[:c.next]...)
+	return out
+}
+
+// getTrafficCapture returns the capture of the network, or nil if it was never started.
+func (m *Module) getTrafficCapture(networkUUID string) *TrafficCapture {
+	if m.store == nil {
+		return nil
+	}
+	capture, ok := m.store.Get(trafficCaptureKey(networkUUID))
+	if !ok {
+		return nil
+	}
+	return capture.(*TrafficCapture)
+}
+
+// startTrafficCapture starts a new capture of the network, the frames of a previous capture are dropped.
+func (m *Module) startTrafficCapture(networkUUID string, body *TrafficCaptureBody) (*TrafficCapture, error) {
+	network, err := m.grpcMarshaller.GetNetwork(networkUUID)
+	if err != nil || network == nil {
+		return nil, fmt.Errorf("failed to find network %s", networkUUID)
+	}
+	if isServerNetwork(network) {
+		return nil, fmt.Errorf("network %s doesn't poll devices, there is no traffic to capture", network.Name)
+	}
+	size := defaultTrafficCaptureSize
+	if body != nil && body.Size > 0 {
+		size = body.Size
+	}
+	if size > maxTrafficCaptureSize {
+		size = maxTrafficCaptureSize
+	}
+	capture := &TrafficCapture{
+		NetworkUUID: networkUUID,
+		Enabled:     true,
+		Size:        size,
+		StartTime:   time.Now(),
+		Frames:      make([]*CapturedFrame, 0, size),
+	}
+	m.store.Set(trafficCaptureKey(networkUUID), capture, cache.NoExpiration)
+	m.modbusPollingMsg(fmt.Sprintf("TRAFFIC-CAPTURE: started. network: %s, size: %d", network.Name, size))
+	return capture.snapshot(), nil
+}
+
+// stopTrafficCapture stops recording, the captured frames can still be fetched and exported.
+func (m *Module) stopTrafficCapture(networkUUID string) (*TrafficCapture, error) {
+	capture := m.getTrafficCapture(networkUUID)
+	if capture == nil {
+		return nil, fmt.Errorf("failed to find traffic capture of network %s", networkUUID)
+	}
+	capture.mutex.Lock()
+	if capture.Enabled {
+		stopTime := time.Now()
+		capture.Enabled = false
+		capture.StopTime = &stopTime
+	}
+	capture.mutex.Unlock()
+	return capture.snapshot(), nil
+}
+
+func (m *Module) getTrafficCaptureFrames(networkUUID string) (*TrafficCapture, error) {
+	capture := m.getTrafficCapture(networkUUID)
+	if capture == nil {
+		return nil, fmt.Errorf("failed to find traffic capture of network %s", networkUUID)
+	}
+	return capture.snapshot(), nil
+}
+
+func (m *Module) exportTrafficCapturePcap(networkUUID string) ([]byte, error) {
+	capture, err := m.getTrafficCaptureFrames(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	return capture.pcap(), nil
+}
+
+// exportTrafficCaptureFile writes the capture to a pcap file in the traffic capture dir.
+func (m *Module) exportTrafficCaptureFile(networkUUID string) (*TrafficCaptureExport, error) {
+	capture, err := m.getTrafficCaptureFrames(networkUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(m.config.TrafficCaptureDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create traffic capture dir: %s", err)
+	}
+	file := filepath.Join(m.config.TrafficCaptureDir, fmt.Sprintf("%s-%s.pcap", networkUUID, time.Now().Format("20060102-150405")))
+	if err = os.WriteFile(file, capture.pcap(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write traffic capture: %s", err)
+	}
+	return &TrafficCaptureExport{File: file, Frames: len(capture.Frames)}, nil
+}
+
+// pcap file of the capture. The frames of all transports are written as Modbus TCP over IPv4, between a master at
+// 10.0.0.1 and the devices at 10.0.0.2:502, so any Modbus analyser (eg: Wireshark) can decode them. The unit id is in
+// the MBAP header, and the transaction id is the index of the frame.
+func (c *TrafficCapture) pcap() []byte {
+	const (
+		linkTypeRaw = 101 // raw IPv4 packets, no link layer header
+		masterPort  = 50200
+		devicePort  = 502
+	)
+	var master, device = [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, struct {
+		Magic        uint32
+		VersionMajor uint16
+		VersionMinor uint16
+		ThisZone     int32
+		SigFigs      uint32
+		SnapLen      uint32
+		LinkType     uint32
+	}{0xa1b2c3d4, 2, 4, 0, 0, 65535, linkTypeRaw})
+
+	var masterSeq, deviceSeq uint32 = 1, 1
+	var ipId uint16
+	writePacket := func(timestamp time.Time, fromMaster bool, transactionId uint16, unitId uint8, pdu []byte) {
+		mbap := make([]byte, 7, 7+len(pdu))
+		binary.BigEndian.PutUint16(mbap[0:2], transactionId)
+		binary.BigEndian.PutUint16(mbap[4:6], uint16(len(pdu)+1))
+		mbap[6] = unitId
+		payload := append(mbap, pdu...)
+
+		src, dst, srcPort, dstPort, seq, ack := master, device, uint16(masterPort), uint16(devicePort), &masterSeq, deviceSeq
+		if !fromMaster {
+			src, dst, srcPort, dstPort, seq, ack = device, master, devicePort, masterPort, &deviceSeq, masterSeq
+		}
+		packet := make([]byte, 40, 40+len(payload))
+		// ipv4 header
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:4], uint16(40+len(payload)))
+		ipId++
+		binary.BigEndian.PutUint16(packet[4:6], ipId)
+		binary.BigEndian.PutUint16(packet[6:8], 0x4000) // don't fragment
+		packet[8] = 64
+		packet[9] = 6 // tcp
+		copy(packet[12:16], src[:])
+		copy(packet[16:20], dst[:])
+		binary.BigEndian.PutUint16(packet[10:12], ipChecksum(packet[:20]))
+		// tcp header, the checksum is left at 0 as analysers don't validate it by default
+		binary.BigEndian.PutUint16(packet[20:22], srcPort)
+		binary.BigEndian.PutUint16(packet[22:24], dstPort)
+		binary.BigEndian.PutUint32(packet[24:28], *seq)
+		binary.BigEndian.PutUint32(packet[28:32], ack)
+		packet[32] = 5 << 4
+		packet[33] = 0x18 // psh, ack
+		binary.BigEndian.PutUint16(packet[34:36], 65535)
+		packet = append(packet, payload...)
+		*seq += uint32(len(payload))
+
+		_ = binary.Write(buf, binary.LittleEndian, [4]uint32{
+			uint32(timestamp.Unix()), uint32(timestamp.Nanosecond() / 1000), uint32(len(packet)), uint32(len(packet)),
+		})
+		buf.Write(packet)
+	}
+
+	for _, frame := range c.Frames {
+		transactionId := uint16(frame.Index)
+		writePacket(frame.Time, true, transactionId, frame.UnitId, frame.Request)
+		if len(frame.Response) > 0 {
+			latency := time.Duration(frame.LatencyMs * float64(time.Millisecond))
+			writePacket(frame.Time.Add(latency), false, transactionId, frame.UnitId, frame.Response)
+		}
+	}
+	return buf.Bytes()
+}
+
+func ipChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
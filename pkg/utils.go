@@ -28,6 +28,7 @@ type Operation struct {
 	IsHoldingReg bool    `json:"is_holding_register"`
 	WriteValue   float64 `json:"write_value"`
 	Encoding     string  `json:"object_encoding"` // BEB_LEW
+	DataType     string  `json:"data_type"`       // uint16
 	coil         uint16
 	u16          uint16
 	u32          uint32
@@ -61,6 +62,26 @@ func pointAddress(pnt *model.Point, zeroMode bool) uint16 {
 	}
 }
 
+func setEncoding(mbClient *smod.ModbusClient, objectEncoding string) {
+	mbClient.SetEncoding(pointEncoding(objectEncoding))
+}
+
+// pointEncoding returns the byte and word order of the point ObjectEncoding.
+func pointEncoding(objectEncoding string) (smod.Endianness, smod.WordOrder) {
+	switch objectEncoding {
+	case string(datatype.ByteOrderLebBew):
+		return smod.LittleEndian, smod.HighWordFirst
+	case string(datatype.ByteOrderLebLew):
+		return smod.LittleEndian, smod.LowWordFirst
+	case string(datatype.ByteOrderBebLew):
+		return smod.BigEndian, smod.LowWordFirst
+	case string(datatype.ByteOrderBebBew):
+		return smod.BigEndian, smod.HighWordFirst
+	default:
+		return smod.BigEndian, smod.LowWordFirst
+	}
+}
+
 func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, doWrite bool) (response interface{}, responseValue float64, err error) {
 	mbClient.Debug = true
 	objectEncoding := pnt.ObjectEncoding                      // beb_lew
@@ -71,18 +92,7 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 	objectType := nstring.NewString(pnt.ObjectType).ToSnakeCase() // eg: readCoil, read_coil, writeCoil
 	objectType = convertOldObjectType(objectType)
 
-	switch objectEncoding {
-	case string(datatype.ByteOrderLebBew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.HighWordFirst)
-	case string(datatype.ByteOrderLebLew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebLew):
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebBew):
-		mbClient.SetEncoding(smod.BigEndian, smod.HighWordFirst)
-	default:
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	}
+	setEncoding(mbClient, objectEncoding)
 	if length <= 0 { // Make sure length is > 0
 		length = 1
 	}
@@ -92,9 +102,9 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 	}
 
 	if doWrite {
-		m.modbusDebugMsg("modbus-write: ObjectType: %s  Addr: %d WriteValue: %v\n", objectType, address, writeValue)
+		m.modbusDebugMsg(fmt.Sprintf("modbus-write: ObjectType: %s  Addr: %d WriteValue: %v", objectType, address, writeValue))
 	} else {
-		m.modbusDebugMsg("modbus-read: ObjectType: %s  Addr: %d", objectType, address)
+		m.modbusDebugMsg(fmt.Sprintf("modbus-read: ObjectType: %s  Addr: %d", objectType, address))
 	}
 
 	switch objectType {
@@ -113,6 +123,9 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 	// READ HOLDINGS
 	case string(datatype.ObjTypeHoldingRegister):
 		if doWrite {
+			if err = smod.CheckValueRange(dataType, writeValue); err != nil {
+				return nil, 0, err
+			}
 			if dataType == string(datatype.TypeUint16) || dataType == string(datatype.TypeInt16) {
 				return mbClient.WriteSingleRegister(address, uint16(writeValue))
 			} else if dataType == string(datatype.TypeUint32) || dataType == string(datatype.TypeInt32) {
@@ -122,7 +135,9 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 			} else if dataType == string(datatype.TypeFloat32) {
 				return mbClient.WriteFloat32(address, writeValue)
 			} else if dataType == string(datatype.TypeFloat64) {
-				return mbClient.WriteFloat32(address, writeValue)
+				return mbClient.WriteFloat64(address, writeValue)
+			} else if dataType == string(datatype.TypeMod10U32) || smod.RegisterCount(dataType) > 0 {
+				return mbClient.WriteValue(address, dataType, writeValue)
 			}
 		} else {
 			if dataType == string(datatype.TypeUint16) || dataType == string(datatype.TypeInt16) {
@@ -134,7 +149,11 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 			} else if dataType == string(datatype.TypeFloat32) {
 				return mbClient.ReadFloat32(address, smod.HoldingRegister)
 			} else if dataType == string(datatype.TypeFloat64) {
-				return mbClient.ReadFloat32(address, smod.HoldingRegister)
+				return mbClient.ReadFloat64(address, smod.HoldingRegister)
+			} else if dataType == smod.TypeString {
+				return readString(mbClient, address, pnt, smod.HoldingRegister)
+			} else if count := smod.RegisterCount(dataType); count > 0 {
+				return mbClient.ReadHoldingRegisters(address, count, dataType)
 			}
 		}
 
@@ -149,7 +168,11 @@ func (m *Module) networkRequest(mbClient *smod.ModbusClient, pnt *model.Point, d
 		} else if dataType == string(datatype.TypeFloat32) {
 			return mbClient.ReadFloat32(address, smod.InputRegister)
 		} else if dataType == string(datatype.TypeFloat64) {
-			return mbClient.ReadFloat32(address, smod.InputRegister)
+			return mbClient.ReadFloat64(address, smod.InputRegister)
+		} else if dataType == smod.TypeString {
+			return readString(mbClient, address, pnt, smod.InputRegister)
+		} else if count := smod.RegisterCount(dataType); count > 0 {
+			return mbClient.ReadInputRegisters(address, count, dataType)
 		}
 
 	}
@@ -169,18 +192,7 @@ func (m *Module) networkWrite(mbClient *smod.ModbusClient, pnt *model.Point) (re
 	objectType := nstring.NewString(pnt.ObjectType).ToSnakeCase() // eg: readCoil, read_coil, writeCoil
 	objectType = convertOldObjectType(objectType)
 
-	switch objectEncoding {
-	case string(datatype.ByteOrderLebBew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.HighWordFirst)
-	case string(datatype.ByteOrderLebLew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebLew):
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebBew):
-		mbClient.SetEncoding(smod.BigEndian, smod.HighWordFirst)
-	default:
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	}
+	setEncoding(mbClient, objectEncoding)
 
 	writeValue := *pnt.WriteValue
 
@@ -193,6 +205,9 @@ func (m *Module) networkWrite(mbClient *smod.ModbusClient, pnt *model.Point) (re
 
 	// WRITE HOLDINGS
 	case string(datatype.ObjTypeHoldingRegister):
+		if err = smod.CheckValueRange(dataType, writeValue); err != nil {
+			return nil, 0, err
+		}
 		if dataType == string(datatype.TypeUint16) || dataType == string(datatype.TypeInt16) {
 			return mbClient.WriteSingleRegister(address, uint16(writeValue))
 		} else if dataType == string(datatype.TypeUint32) || dataType == string(datatype.TypeInt32) {
@@ -202,7 +217,9 @@ func (m *Module) networkWrite(mbClient *smod.ModbusClient, pnt *model.Point) (re
 		} else if dataType == string(datatype.TypeFloat32) {
 			return mbClient.WriteFloat32(address, writeValue)
 		} else if dataType == string(datatype.TypeFloat64) {
-			return mbClient.WriteFloat32(address, writeValue)
+			return mbClient.WriteFloat64(address, writeValue)
+		} else if dataType == string(datatype.TypeMod10U32) || smod.RegisterCount(dataType) > 0 {
+			return mbClient.WriteValue(address, dataType, writeValue)
 		}
 	}
 
@@ -220,18 +237,7 @@ func (m *Module) networkRead(mbClient *smod.ModbusClient, pnt *model.Point) (res
 	objectType := nstring.NewString(pnt.ObjectType).ToSnakeCase() // eg: readCoil, read_coil, writeCoil
 	objectType = convertOldObjectType(objectType)
 
-	switch objectEncoding {
-	case string(datatype.ByteOrderLebBew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.HighWordFirst)
-	case string(datatype.ByteOrderLebLew):
-		mbClient.SetEncoding(smod.LittleEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebLew):
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	case string(datatype.ByteOrderBebBew):
-		mbClient.SetEncoding(smod.BigEndian, smod.HighWordFirst)
-	default:
-		mbClient.SetEncoding(smod.BigEndian, smod.LowWordFirst)
-	}
+	setEncoding(mbClient, objectEncoding)
 
 	m.modbusDebugMsg(fmt.Sprintf("modbus-read: ObjectType: %s  Addr: %d", objectType, address))
 
@@ -259,6 +265,10 @@ func (m *Module) networkRead(mbClient *smod.ModbusClient, pnt *model.Point) (res
 			return mbClient.ReadFloat32(address, smod.InputRegister)
 		} else if dataType == string(datatype.TypeFloat64) {
 			return mbClient.ReadFloat64(address, smod.InputRegister)
+		} else if dataType == smod.TypeString {
+			return readString(mbClient, address, pnt, smod.InputRegister)
+		} else if count := smod.RegisterCount(dataType); count > 0 {
+			return mbClient.ReadInputRegisters(address, count, dataType)
 		}
 
 	// READ HOLDINGS
@@ -276,6 +286,10 @@ func (m *Module) networkRead(mbClient *smod.ModbusClient, pnt *model.Point) (res
 			return mbClient.ReadFloat32(address, smod.HoldingRegister)
 		} else if dataType == string(datatype.TypeFloat64) {
 			return mbClient.ReadFloat64(address, smod.HoldingRegister)
+		} else if dataType == smod.TypeString {
+			return readString(mbClient, address, pnt, smod.HoldingRegister)
+		} else if count := smod.RegisterCount(dataType); count > 0 {
+			return mbClient.ReadHoldingRegisters(address, count, dataType)
 		}
 
 	}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeIO/lib-utils-go/float"
+	"github.com/NubeIO/lib-utils-go/nstring"
+	"github.com/NubeIO/module-core-modbus/pollqueue"
+	"github.com/NubeIO/module-core-modbus/smod"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+const (
+	metaTagVerifyWrite          = "verify_write"           // point meta tag: "true" to read the value back after each write
+	metaTagVerifyWriteDelay     = "verify_write_delay"     // point meta tag: milliseconds between the write and the read back. Default 500
+	metaTagVerifyWriteTolerance = "verify_write_tolerance" // point meta tag: max difference between the written and read back value. Default 0
+
+	defaultVerifyWriteDelay = 500 * time.Millisecond
+	maxVerifyWriteDelay     = 10 * time.Second
+	writeVerificationExpiry = time.Hour // a read back that is never polled is dropped, eg: the point was deleted
+)
+
+// writeVerification is how a point checks that the device kept the written value, eg: it could clamp it to its range.
+type writeVerification struct {
+	delay     time.Duration
+	tolerance float64
+}
+
+// pendingWriteVerification is a write that is read back by a poll of the point after the verify delay, so the bus
+// isn't held by the point in the meantime.
+type pendingWriteVerification struct {
+	verify     writeVerification
+	writeValue float64 // the write value of the point, the read back is dropped if it changes
+	value      float64 // the value that was written to the device
+	due        time.Time
+}
+
+func writeVerificationKey(pointUUID string) string {
+	return "write-verification-" + pointUUID
+}
+
+// pointMetaTag returns the value of the meta tag of the point.
+func pointMetaTag(pnt *model.Point, key string) (string, bool) {
+	for _, tag := range pnt.MetaTags {
+		if tag != nil && tag.Key == key {
+			return strings.TrimSpace(tag.Value), true
+		}
+	}
+	return "", false
+}
+
+// pointWriteVerification returns the write verification of the point, if it has one.
+func pointWriteVerification(pnt *model.Point) (verify writeVerification, ok bool) {
+	value, _ := pointMetaTag(pnt, metaTagVerifyWrite)
+	if enabled, _ := strconv.ParseBool(value); !enabled {
+		return verify, false
+	}
+	verify.delay = defaultVerifyWriteDelay
+	if value, ok = pointMetaTag(pnt, metaTagVerifyWriteDelay); ok {
+		if delay, err := strconv.Atoi(value); err == nil && delay >= 0 {
+			verify.delay = time.Duration(delay) * time.Millisecond
+		}
+	}
+	if verify.delay > maxVerifyWriteDelay {
+		verify.delay = maxVerifyWriteDelay
+	}
+	if value, ok = pointMetaTag(pnt, metaTagVerifyWriteTolerance); ok {
+		if tolerance, err := strconv.ParseFloat(value, 64); err == nil && tolerance >= 0 {
+			verify.tolerance = tolerance
+		}
+	}
+	return verify, true
+}
+
+// matches checks if the read back value is the written value. float32 points are compared at float32 precision, as the
+// written value is rounded to it.
+func (v writeVerification) matches(pnt *model.Point, written, readBack float64) bool {
+	if nstring.NewString(pnt.DataType).ToSnakeCase() == string(datatype.TypeFloat32) {
+		written = float64(float32(written))
+	}
+	return math.Abs(written-readBack) <= v.tolerance
+}
+
+// scheduleWriteVerification finishes the poll of a point that wrote the value, and polls it again after the verify delay
+// to read the value back. The write isn't done until then, so it is retried if the poll is finished another way.
+func (m *Module) scheduleWriteVerification(netPollMan *pollqueue.NetworkPollManager, pp *pollqueue.PollingPoint, pnt *model.Point, verify writeVerification, value float64, pollStartTime time.Time) {
+	pending := &pendingWriteVerification{
+		verify:     verify,
+		writeValue: float.NonNil(pnt.WriteValue),
+		value:      value,
+		due:        time.Now().Add(verify.delay),
+	}
+	m.store.Set(writeVerificationKey(pnt.UUID), pending, writeVerificationExpiry)
+	netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, true, pollqueue.DELAYED_RETRY)
+	netPollMan.RepollPointAfter(pnt.UUID, verify.delay)
+}
+
+// pollPointWriteVerification reads back the value that the point wrote in its last poll, if it verifies its writes.
+// Returns false if the point has no write to verify, so it is polled as usual.
+func (m *Module) pollPointWriteVerification(netPollMan *pollqueue.NetworkPollManager, mbClient *smod.ModbusClient, dev *model.Device, pp *pollqueue.PollingPoint, pnt *model.Point, pollStartTime time.Time) bool {
+	key := writeVerificationKey(pnt.UUID)
+	item, ok := m.store.Get(key)
+	if !ok {
+		return false
+	}
+	pending := item.(*pendingWriteVerification)
+	if pnt.WriteValue == nil || *pnt.WriteValue != pending.writeValue {
+		// the new value is verified after it is written
+		m.store.Delete(key)
+		return false
+	}
+	if wait := time.Until(pending.due); wait > 0 {
+		// polled before the delay, eg: by a poll now request
+		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, true, pollqueue.DELAYED_RETRY)
+		netPollMan.RepollPointAfter(pnt.UUID, wait)
+		return true
+	}
+	m.store.Delete(key)
+
+	readBackValue, err := m.readBackWrite(mbClient, pnt)
+	reportDevicePoll(netPollMan, dev.UUID, err)
+	if err != nil {
+		retryType := m.pointRequestFailed(pnt, err, dto.CommonFaultCode.PointWriteError)
+		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, retryType)
+		return true
+	}
+	if !pending.verify.matches(pnt, pending.value, readBackValue) {
+		// the device didn't keep the value, eg: it is out of its range, so the write is retried later
+		message := fmt.Sprintf("write verification failed: wrote %v, read back %v", pending.value, readBackValue)
+		m.modbusPollingMsg(fmt.Sprintf("WRITE-VERIFY: %s, point UUID: %s", message, pnt.UUID))
+		_ = m.internalPointUpdateErr(pnt, message, dto.MessageLevel.Fail, dto.CommonFaultCode.PointWriteError)
+		netPollMan.SinglePollFinished(pp, pnt, pollStartTime, false, false, false, pollqueue.DELAYED_RETRY)
+		return true
+	}
+	if !float.ComparePtrValues(pnt.OriginalValue, &pending.value) {
+		pnt, _ = m.internalPointUpdate(pnt, pending.value)
+	}
+	netPollMan.SinglePollFinished(pp, pnt, pollStartTime, true, false, false, pollqueue.NORMAL_RETRY)
+	return true
+}
+
+// readBackWrite reads the point value back, only the bit field of bitwise points.
+func (m *Module) readBackWrite(mbClient *smod.ModbusClient, pnt *model.Point) (float64, error) {
+	_, value, err := m.networkRead(mbClient, pnt)
+	if err != nil {
+		return 0, fmt.Errorf("write verification read failed: %w", err)
+	}
+	if field, ok := pointBitField(pnt); ok {
+		return field.read(value)
+	}
+	return value, nil
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+func TestPointWriteVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		ok        bool
+		delay     time.Duration
+		tolerance float64
+	}{
+		{"no tags", nil, false, 0, 0},
+		{"disabled", map[string]string{metaTagVerifyWrite: "false", metaTagVerifyWriteDelay: "100"}, false, 0, 0},
+		{"defaults", map[string]string{metaTagVerifyWrite: "true"}, true, defaultVerifyWriteDelay, 0},
+		{"delay and tolerance", map[string]string{metaTagVerifyWrite: "1", metaTagVerifyWriteDelay: " 250 ", metaTagVerifyWriteTolerance: "0.5"}, true, 250 * time.Millisecond, 0.5},
+		{"no delay", map[string]string{metaTagVerifyWrite: "true", metaTagVerifyWriteDelay: "0"}, true, 0, 0},
+		{"max delay", map[string]string{metaTagVerifyWrite: "true", metaTagVerifyWriteDelay: "60000"}, true, maxVerifyWriteDelay, 0},
+		{"invalid", map[string]string{metaTagVerifyWrite: "true", metaTagVerifyWriteDelay: "-1", metaTagVerifyWriteTolerance: "-2"}, true, defaultVerifyWriteDelay, 0},
+		{"not a number", map[string]string{metaTagVerifyWrite: "true", metaTagVerifyWriteDelay: "1s", metaTagVerifyWriteTolerance: "x"}, true, defaultVerifyWriteDelay, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pnt := &model.Point{}
+			for key, value := range tt.tags {
+				pnt.MetaTags = append(pnt.MetaTags, &model.PointMetaTag{Key: key, Value: value})
+			}
+			verify, ok := pointWriteVerification(pnt)
+			if ok != tt.ok || verify.delay != tt.delay || verify.tolerance != tt.tolerance {
+				t.Errorf("pointWriteVerification() = %+v, %t, want delay %s, tolerance %v, %t", verify, ok, tt.delay, tt.tolerance, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWriteVerificationMatches(t *testing.T) {
+	tests := []struct {
+		dataType  datatype.DataType
+		tolerance float64
+		written   float64
+		readBack  float64
+		want      bool
+	}{
+		{datatype.TypeUint16, 0, 42, 42, true},
+		{datatype.TypeUint16, 0, 42, 43, false},
+		{datatype.TypeUint16, 1, 42, 43, true},
+		{datatype.TypeUint16, 1, 42, 40, false},
+		{datatype.TypeInt16, 0.5, -10, -10.5, true},
+		// float32 points read back the written value rounded to float32
+		{datatype.TypeFloat32, 0, 0.1, float64(float32(0.1)), true},
+		{datatype.TypeFloat64, 0, 0.1, float64(float32(0.1)), false},
+	}
+	for _, tt := range tests {
+		verify := writeVerification{tolerance: tt.tolerance}
+		pnt := &model.Point{DataType: string(tt.dataType)}
+		if got := verify.matches(pnt, tt.written, tt.readBack); got != tt.want {
+			t.Errorf("matches() of %s %v read back as %v with tolerance %v = %t, want %t", tt.dataType, tt.written, tt.readBack, tt.tolerance, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package pollqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+)
+
+type DeviceHealthState string
+
+const (
+	DEVICE_ONLINE   DeviceHealthState = "online"   // last request got a response
+	DEVICE_DEGRADED DeviceHealthState = "degraded" // some requests in a row failed, still polled as normal
+	DEVICE_OFFLINE  DeviceHealthState = "offline"  // too many requests in a row failed, only probed on a backoff schedule
+
+	DeviceDegradedFailures = 1 // failures in a row before a device is degraded
+	DeviceOfflineFailures  = 3 // failures in a row before a device is offline
+
+	minDeviceProbeInterval = 10 * time.Second
+	maxDeviceProbeInterval = 10 * time.Minute
+)
+
+// DeviceHealth is the communication health of a device, driven by the results of the requests sent to it.
+type DeviceHealth struct {
+	State               DeviceHealthState `json:"state"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	LastError           string            `json:"last_error,omitempty"`
+	LastChange          time.Time         `json:"last_change"`
+	NextProbe           *time.Time        `json:"next_probe,omitempty"`
+
+	probeInterval time.Duration
+}
+
+// DevicePollAllowed checks if a point of the device can be polled now. Offline devices only get a single probe poll each
+// probe interval, the points drawn in between should be finished with DELAYED_RETRY without sending them.
+func (pm *NetworkPollManager) DevicePollAllowed(deviceUUID string) bool {
+	pm.healthMutex.Lock()
+	defer pm.healthMutex.Unlock()
+	health, ok := pm.deviceHealth[deviceUUID]
+	if !ok || health.State != DEVICE_OFFLINE {
+		return true
+	}
+	now := time.Now()
+	if now.Before(*health.NextProbe) {
+		return false
+	}
+	// the next probe is scheduled now, so a probe that doesn't report back doesn't stop the probing
+	nextProbe := now.Add(health.probeInterval)
+	health.NextProbe = &nextProbe
+	pm.pollQueuePollingMsg(fmt.Sprintf("DevicePollAllowed(): probing offline device %s", deviceUUID))
+	return true
+}
+
+// IsDeviceOffline checks if the device has stopped responding.
+func (pm *NetworkPollManager) IsDeviceOffline(deviceUUID string) bool {
+	pm.healthMutex.Lock()
+	defer pm.healthMutex.Unlock()
+	health, ok := pm.deviceHealth[deviceUUID]
+	return ok && health.State == DEVICE_OFFLINE
+}
+
+// DevicePollSucceeded is called when the device responded to a request, including with an exception response.
+func (pm *NetworkPollManager) DevicePollSucceeded(deviceUUID string) {
+	pm.healthMutex.Lock()
+	health, ok := pm.deviceHealth[deviceUUID]
+	if !ok {
+		pm.deviceHealth[deviceUUID] = &DeviceHealth{State: DEVICE_ONLINE, LastChange: time.Now()}
+		pm.healthMutex.Unlock()
+		return
+	}
+	previous := health.State
+	health.ConsecutiveFailures = 0
+	health.LastError = ""
+	health.NextProbe = nil
+	health.probeInterval = 0
+	if previous == DEVICE_ONLINE {
+		pm.healthMutex.Unlock()
+		return
+	}
+	health.State = DEVICE_ONLINE
+	health.LastChange = time.Now()
+	pm.healthMutex.Unlock()
+	pm.deviceHealthChanged(deviceUUID, previous, DEVICE_ONLINE, "")
+}
+
+// DevicePollFailed is called when a request to the device got no valid response.
+func (pm *NetworkPollManager) DevicePollFailed(deviceUUID string, err error) {
+	pm.healthMutex.Lock()
+	health, ok := pm.deviceHealth[deviceUUID]
+	if !ok {
+		health = &DeviceHealth{State: DEVICE_ONLINE}
+		pm.deviceHealth[deviceUUID] = health
+	}
+	previous := health.State
+	health.ConsecutiveFailures++
+	if err != nil {
+		health.LastError = err.Error()
+	}
+	lastError := health.LastError
+	switch {
+	case previous == DEVICE_OFFLINE: // failed probe
+		health.probeInterval *= 2
+		if health.probeInterval > maxDeviceProbeInterval {
+			health.probeInterval = maxDeviceProbeInterval
+		}
+		nextProbe := time.Now().Add(health.probeInterval)
+		health.NextProbe = &nextProbe
+	case health.ConsecutiveFailures >= DeviceOfflineFailures:
+		health.State = DEVICE_OFFLINE
+		health.probeInterval = minDeviceProbeInterval
+		nextProbe := time.Now().Add(health.probeInterval)
+		health.NextProbe = &nextProbe
+	case health.ConsecutiveFailures >= DeviceDegradedFailures:
+		health.State = DEVICE_DEGRADED
+	}
+	if health.State == previous {
+		pm.healthMutex.Unlock()
+		return
+	}
+	health.LastChange = time.Now()
+	pm.healthMutex.Unlock()
+	pm.deviceHealthChanged(deviceUUID, previous, health.State, lastError)
+}
+
+// GetDeviceHealth returns a copy of the health of the device, or nil if it hasn't been polled yet.
+func (pm *NetworkPollManager) GetDeviceHealth(deviceUUID string) *DeviceHealth {
+	pm.healthMutex.Lock()
+	defer pm.healthMutex.Unlock()
+	health, ok := pm.deviceHealth[deviceUUID]
+	if !ok {
+		return nil
+	}
+	healthCopy := *health
+	if health.NextProbe != nil {
+		nextProbe := *health.NextProbe
+		healthCopy.NextProbe = &nextProbe
+	}
+	return &healthCopy
+}
+
+// ResetDeviceHealth forgets the health of the device, eg: when it is updated, so that it is polled as normal again.
+func (pm *NetworkPollManager) ResetDeviceHealth(deviceUUID string) {
+	pm.healthMutex.Lock()
+	defer pm.healthMutex.Unlock()
+	delete(pm.deviceHealth, deviceUUID)
+}
+
+// deviceHealthChanged updates the CommonFault of the device to the new health state.
+func (pm *NetworkPollManager) deviceHealthChanged(deviceUUID string, previous, state DeviceHealthState, lastError string) {
+	pm.pollQueueDebugMsg(fmt.Sprintf("deviceHealthChanged(): device %s changed from %s to %s", deviceUUID, previous, state))
+	dev, err := pm.Marshaller.GetDevice(deviceUUID)
+	if err != nil || dev == nil {
+		pm.pollQueueErrorMsg("deviceHealthChanged(): cannot find device ", deviceUUID)
+		return
+	}
+	switch state {
+	case DEVICE_ONLINE:
+		dev.CommonFault.InFault = false
+		dev.CommonFault.MessageLevel = dto.MessageLevel.Info
+		dev.CommonFault.MessageCode = dto.CommonFaultCode.Ok
+		dev.CommonFault.Message = ""
+		dev.CommonFault.LastOk = time.Now().UTC()
+	case DEVICE_DEGRADED:
+		dev.CommonFault.InFault = true
+		dev.CommonFault.MessageLevel = dto.MessageLevel.Warning
+		dev.CommonFault.MessageCode = dto.CommonFaultCode.DeviceError
+		dev.CommonFault.Message = fmt.Sprintf("modbus: device degraded: %s", lastError)
+		dev.CommonFault.LastFail = time.Now().UTC()
+	case DEVICE_OFFLINE:
+		dev.CommonFault.InFault = true
+		dev.CommonFault.MessageLevel = dto.MessageLevel.Fail
+		dev.CommonFault.MessageCode = dto.CommonFaultCode.DeviceError
+		dev.CommonFault.Message = fmt.Sprintf("modbus: device offline: %s", lastError)
+		dev.CommonFault.LastFail = time.Now().UTC()
+	}
+	if err = pm.Marshaller.UpdateDeviceErrors(dev.UUID, dev); err != nil {
+		pm.pollQueueErrorMsg("deviceHealthChanged(): failed to update device errors ", err)
+	}
+}
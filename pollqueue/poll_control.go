@@ -0,0 +1,128 @@
+package pollqueue
+
+import (
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+)
+
+// PauseNetwork stops the polling of the network without disabling it. Its points stay in the queue, and it stays paused
+// until ResumeNetwork, even if its port becomes unavailable and available again.
+func (pm *NetworkPollManager) PauseNetwork() {
+	pm.pauseMutex.Lock()
+	pm.networkPaused = true
+	pm.pauseMutex.Unlock()
+	pm.PausePolling()
+}
+
+// ResumeNetwork restarts the polling of a paused network, unless its port is unavailable.
+func (pm *NetworkPollManager) ResumeNetwork() {
+	pm.pauseMutex.Lock()
+	pm.networkPaused = false
+	pm.pauseMutex.Unlock()
+	if pm.PortUnavailableTimeout == nil {
+		pm.UnpausePolling()
+	}
+}
+
+func (pm *NetworkPollManager) NetworkPaused() bool {
+	pm.pauseMutex.Lock()
+	defer pm.pauseMutex.Unlock()
+	return pm.networkPaused
+}
+
+// PauseDevice stops the polling of the device without disabling it. Its points are delayed to their next repoll each
+// time they are drawn from the queue, so their poll required flags are kept for when the device is resumed.
+func (pm *NetworkPollManager) PauseDevice(deviceUUID string) {
+	pm.pauseMutex.Lock()
+	defer pm.pauseMutex.Unlock()
+	pm.pausedDevices[deviceUUID] = true
+}
+
+// ResumeDevice restarts the polling of a paused device. Its points are polled at their next repoll.
+func (pm *NetworkPollManager) ResumeDevice(deviceUUID string) {
+	pm.pauseMutex.Lock()
+	defer pm.pauseMutex.Unlock()
+	delete(pm.pausedDevices, deviceUUID)
+}
+
+func (pm *NetworkPollManager) DevicePaused(deviceUUID string) bool {
+	pm.pauseMutex.Lock()
+	defer pm.pauseMutex.Unlock()
+	return pm.pausedDevices[deviceUUID]
+}
+
+// PausedDeviceUUIDs returns the UUIDs of the paused devices of the network.
+func (pm *NetworkPollManager) PausedDeviceUUIDs() []string {
+	pm.pauseMutex.Lock()
+	defer pm.pauseMutex.Unlock()
+	deviceUUIDs := make([]string, 0, len(pm.pausedDevices))
+	for deviceUUID := range pm.pausedDevices {
+		deviceUUIDs = append(deviceUUIDs, deviceUUID)
+	}
+	return deviceUUIDs
+}
+
+// PollPointNow moves the polling point to the front of the queue with ASAP priority. A point that is out for polling is
+// re-added with ASAP priority when its poll completes. Returns false if the point isn't in the queue, eg: it is disabled.
+// The poll required flags of the point must be set before, as the poll does what its write mode requires.
+func (pm *NetworkPollManager) PollPointNow(pointUUID string) bool {
+	nq := pm.PollQueue
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	if _, ok := nq.QueueUnloader.CurrentPollPoints[pointUUID]; ok && !nq.QueueUnloader.RemoveCurrentPoints[pointUUID] {
+		nq.PointsUpdatedWhilePolling[pointUUID] = true
+		return true
+	}
+	if _, ok := nq.QueueUnloader.BlockPollPoints[pointUUID]; ok && !nq.QueueUnloader.RemoveBlockPoints[pointUUID] {
+		nq.PointsUpdatedWhilePolling[pointUUID] = true
+		return true
+	}
+	if nq.QueueUnloader.NextPollPoint != nil && nq.QueueUnloader.NextPollPoint.FFPointUUID == pointUUID {
+		nq.QueueUnloader.NextPollPoint.PollPriority = datatype.PriorityASAP
+		return true
+	}
+	if nq.PriorityQueue.UpdatePollingPointByPointUUID(pointUUID, datatype.PriorityASAP) {
+		return true
+	}
+	pp := nq.StandbyPollingPoints.RemovePollingPointByPointUUID(pointUUID)
+	if pp == nil {
+		return false
+	}
+	pp.resetPollingPointTimers()
+	pp.PollPriority = datatype.PriorityASAP
+	pp.LockupAlertTimer = pm.MakeLockupTimerFunc(pp.PollPriority)
+	pp.QueueEntryTime = time.Now().Unix()
+	return nq.PriorityQueue.AddPollingPoint(pp)
+}
+
+// RepollPointAfter reschedules the repoll of a point in the standby queue, so it is polled again after the delay instead
+// of its poll rate. Returns false if the point isn't in the standby queue, eg: it was updated while it was polled.
+func (pm *NetworkPollManager) RepollPointAfter(pointUUID string, delay time.Duration) bool {
+	nq := pm.PollQueue
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	pp, _ := nq.StandbyPollingPoints.GetPollingPointIndexByPointUUID(pointUUID)
+	if pp == nil {
+		return false
+	}
+	pp.resetPollingPointTimers()
+	pp.RepollTime = time.Now().Add(delay)
+	pp.RepollTimer = time.AfterFunc(delay, pm.MakePollingPointRepollCallback(pp))
+	return true
+}
+
+// FlushPollingQueue empties the queue of the network and rebuilds it from its enabled devices and points. Points that
+// are out for polling are dropped when their polls complete. If the network can't be read, the queue stays empty and
+// the error is returned. Polling is restarted either way like with StartPolling, so points that are added later are
+// polled, except while the port is unavailable, as it is restarted when the port is re-opened.
+func (pm *NetworkPollManager) FlushPollingQueue() error {
+	pm.pollQueueDebugMsg("FlushPollingQueue()")
+	pm.SetAllDevicePollRateDurations()
+	err := pm.RebuildPollingQueue()
+	if pm.PortUnavailableTimeout == nil {
+		pm.Enable = !pm.NetworkPaused()
+	}
+	pm.StartQueueCheckerAndStats()
+	return err
+}
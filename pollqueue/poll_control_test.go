@@ -0,0 +1,46 @@
+package pollqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+func TestPollStandbyPointNow(t *testing.T) {
+	pm := NewPollManager(&Config{EnablePolling: true, LogLevel: "ERROR"}, nil, "net", "net", "modbus")
+	pm.DeviceDurations = map[string][]time.Duration{"dev": {time.Hour, time.Hour, time.Hour}}
+	pm.PollQueue.Start()
+
+	pp := NewPollingPoint("pnt", "dev", "net")
+	pm.AddToStandbyQueueWithRePoll(pp, &model.Point{UUID: "pnt", PollRate: datatype.RateFast})
+	// the repoll timer of the point fires while it is polled now
+	repoll := pm.MakePollingPointRepollCallback(pp)
+
+	if !pm.PollPointNow("pnt") {
+		t.Fatal("PollPointNow() = false, want true")
+	}
+	if pp.RepollTimer != nil || !pp.RepollTime.IsZero() {
+		t.Error("PollPointNow() didn't stop the repoll timer of the point")
+	}
+	if pp.PollPriority != datatype.PriorityASAP || pp.LockupAlertTimer == nil {
+		t.Errorf("PollPointNow() queued the point with priority %s, lockup timer %v", pp.PollPriority, pp.LockupAlertTimer)
+	}
+	nq := pm.PollQueue
+	if nq.StandbyPollingPoints.Len() != 0 || nq.PriorityQueue.Len() != 1 {
+		t.Fatalf("queue has %d standby and %d queued points, want 0 and 1", nq.StandbyPollingPoints.Len(), nq.PriorityQueue.Len())
+	}
+
+	nq.GetNextPollingPoint()
+	if got := nq.GetNextPollingPoint(); got != pp {
+		t.Fatalf("GetNextPollingPoint() = %v, want the point", got)
+	}
+	repoll()
+	if nq.PriorityQueue.Len() != 0 || nq.QueueUnloader.NextPollPoint != nil {
+		t.Errorf("point is queued again while it is out for polling")
+	}
+	if pp.LockupAlertTimer != nil {
+		t.Errorf("point has a lockup timer while it is out for polling")
+	}
+}
@@ -2,6 +2,7 @@ package pollqueue
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/NubeIO/lib-module-go/nmodule"
@@ -32,6 +33,13 @@ type NetworkPollManager struct {
 	QueueCheckerTimer         *time.Ticker
 	QueueCheckerCancelChannel chan bool
 	DeviceDurations           map[string][]time.Duration
+	BusLock                   sync.Mutex // held while a request is on the network, so that other requests run between polls
+	statsMutex                sync.Mutex // guards Statistics and PollCounter, which are updated by every poll worker
+	deviceHealth              map[string]*DeviceHealth
+	healthMutex               sync.Mutex
+	networkPaused             bool            // paused from the api, stays paused when the port becomes available again
+	pausedDevices             map[string]bool // UUIDs of devices paused from the api
+	pauseMutex                sync.Mutex      // guards networkPaused and pausedDevices
 
 	// References
 	FFNetworkUUID string
@@ -46,14 +54,29 @@ type NetworkPollManager struct {
 	LowPriorityMaxCycleTime    time.Duration // threshold setting for triggering a lockup alert for Low priority.
 
 	// Stats
-	Statistics  PollStatistics
-	PollCounter int
+	Statistics      PollStatistics
+	PollCounter     int
+	pollExecuteTime Histogram
+	deviceMetrics   map[string]*DeviceMetrics
+	devicePollStats map[string]*PollStats
+	pointPollStats  map[string]*PointPollStats
+}
+
+// IncrementPollCounter counts a poll of the network, and returns the new count.
+func (pm *NetworkPollManager) IncrementPollCounter() int {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	pm.PollCounter++
+	if pm.PollCounter > 100000 {
+		pm.PollCounter = 100
+	}
+	return pm.PollCounter
 }
 
 func (pm *NetworkPollManager) StartPolling() {
 	pm.SetAllDevicePollRateDurations()
 	pm.RebuildPollingQueue()
-	pm.Enable = true
+	pm.Enable = !pm.NetworkPaused()
 	pm.PollQueue.Start()
 	pm.StartQueueCheckerAndStats()
 	pm.StartPollingStatistics()
@@ -74,7 +97,7 @@ func (pm *NetworkPollManager) PausePolling() {
 
 func (pm *NetworkPollManager) UnpausePolling() {
 	pm.pollQueueDebugMsg("UnpausePolling()")
-	pm.Enable = true
+	pm.Enable = !pm.NetworkPaused()
 	pm.PortUnavailableTimeout = nil
 }
 
@@ -99,6 +122,12 @@ func NewPollManager(conf *Config, marshaller nmodule.Marshaller, ffNetworkUUID,
 	pm.Enable = false
 	pm.Config = conf
 	pm.PollQueue = NewNetworkPriorityPollQueue(conf)
+	pm.deviceHealth = make(map[string]*DeviceHealth)
+	pm.pausedDevices = make(map[string]bool)
+	pm.pollExecuteTime = newHistogram(PollExecuteTimeBuckets)
+	pm.deviceMetrics = make(map[string]*DeviceMetrics)
+	pm.devicePollStats = make(map[string]*PollStats)
+	pm.pointPollStats = make(map[string]*PointPollStats)
 	pm.Marshaller = marshaller
 	pm.FFNetworkUUID = ffNetworkUUID
 	pm.NetworkName = ffNetworkName
@@ -111,7 +140,11 @@ func NewPollManager(conf *Config, marshaller nmodule.Marshaller, ffNetworkUUID,
 }
 
 func (pm *NetworkPollManager) SetAllDevicePollRateDurations() {
-	net, _ := pm.Marshaller.GetNetwork(pm.FFNetworkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true}})
+	net, err := pm.Marshaller.GetNetwork(pm.FFNetworkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true}})
+	if err != nil || net == nil {
+		pm.pollQueueErrorMsg("SetAllDevicePollRateDurations(): cannot find network ", pm.FFNetworkUUID)
+		return
+	}
 	pm.DeviceDurations = make(map[string][]time.Duration, len(net.Devices))
 	for _, dev := range net.Devices {
 		pm.SetDevicePollRateDurations(dev)
@@ -214,14 +247,17 @@ func (pm *NetworkPollManager) StartQueueCheckerAndStats() {
 		pm.QueueCheckerCancelChannel <- true
 	}
 
-	pm.QueueCheckerTimer = time.NewTicker(5 * time.Minute)
-	pm.QueueCheckerCancelChannel = make(chan bool)
+	// the goroutine keeps its own ticker and channel, as the fields are cleared when it is stopped
+	ticker := time.NewTicker(5 * time.Minute)
+	cancel := make(chan bool)
+	pm.QueueCheckerTimer = ticker
+	pm.QueueCheckerCancelChannel = cancel
 	go func() {
 		for {
 			select {
-			case <-pm.QueueCheckerCancelChannel:
+			case <-cancel:
 				return
-			case <-pm.QueueCheckerTimer.C:
+			case <-ticker.C:
 				pm.PollQueueErrorChecking()
 				pm.PrintPollQueueStatistics()
 			}
@@ -237,16 +273,27 @@ func (pm *NetworkPollManager) StopQueueCheckerAndStats() {
 }
 
 func (pm *NetworkPollManager) PortUnavailable() {
+	pm.statsMutex.Lock()
 	pm.Statistics.PortUnavailableStartTime = time.Now().Unix()
+	pm.statsMutex.Unlock()
 	pm.PausePolling()
 }
 
 func (pm *NetworkPollManager) PortAvailable() {
+	pm.portUnavailableEnded()
 	pm.PartialPollStatsUpdate()
 	pm.PrintPollQueueStatistics()
 	pm.UnpausePolling()
 }
 
+// PortRetryStopped ends the unavailability of the port without restarting polling, eg: when the network is disabled
+// while its port is unavailable. Polling is restarted from a rebuilt queue when the network is enabled again.
+func (pm *NetworkPollManager) PortRetryStopped() {
+	pm.portUnavailableEnded()
+	pm.StopPolling()
+	pm.PortUnavailableTimeout = nil
+}
+
 func PollOnStartCheck(pnt *model.Point) bool {
 	return pnt.PollOnStartup == nil || boolean.IsTrue(pnt.PollOnStartup)
 }
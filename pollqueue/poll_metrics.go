@@ -0,0 +1,141 @@
+package pollqueue
+
+import (
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+)
+
+type RequestResult string
+
+const (
+	REQUEST_OK        RequestResult = "ok"
+	REQUEST_EXCEPTION RequestResult = "exception" // the device responded with an exception response
+	REQUEST_TIMEOUT   RequestResult = "timeout"
+	REQUEST_CRC_ERROR RequestResult = "crc-error"
+	REQUEST_FAILED    RequestResult = "failed" // any other error, eg: the connection failed
+)
+
+// PollExecuteTimeBuckets are the upper bounds (in seconds) of the buckets of the poll execute time histograms.
+var PollExecuteTimeBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in buckets. Counts are per bucket (not cumulative), the last count is for observations
+// above the last bucket.
+type Histogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{Buckets: buckets, Counts: make([]uint64, len(buckets)+1)}
+}
+
+func (h *Histogram) observe(value float64) {
+	i := 0
+	for i < len(h.Buckets) && value > h.Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += value
+}
+
+func (h *Histogram) copy() Histogram {
+	histogramCopy := *h
+	histogramCopy.Counts = append([]uint64(nil), h.Counts...)
+	return histogramCopy
+}
+
+// DeviceMetrics are the counters of the polls and requests of a device, since polling started.
+type DeviceMetrics struct {
+	DeviceUUID      string
+	PollCounts      map[datatype.PollPriority]int64
+	PollExecuteTime Histogram
+	Requests        int64 // requests sent to the device, a block read or write is a single request
+	Exceptions      int64
+	Timeouts        int64
+	CRCErrors       int64
+	FailedRequests  int64 // requests that failed with any other error
+}
+
+// PollMetrics are the statistics of a network, with the histograms and counters of its devices.
+type PollMetrics struct {
+	NetworkUUID     string
+	NetworkName     string
+	Enable          bool
+	Statistics      PollStatistics
+	PollExecuteTime Histogram
+	Devices         []*DeviceMetrics
+}
+
+func (pm *NetworkPollManager) getDeviceMetrics(deviceUUID string) *DeviceMetrics {
+	metrics, ok := pm.deviceMetrics[deviceUUID]
+	if !ok {
+		metrics = &DeviceMetrics{
+			DeviceUUID:      deviceUUID,
+			PollCounts:      make(map[datatype.PollPriority]int64),
+			PollExecuteTime: newHistogram(PollExecuteTimeBuckets),
+		}
+		pm.deviceMetrics[deviceUUID] = metrics
+	}
+	return metrics
+}
+
+// pollMetricsUpdate counts the completed poll of the point, in the histograms of the network and device.
+func (pm *NetworkPollManager) pollMetricsUpdate(pp *PollingPoint, pollTimeSecs float64) {
+	pm.pollExecuteTime.observe(pollTimeSecs)
+	deviceMetrics := pm.getDeviceMetrics(pp.FFDeviceUUID)
+	deviceMetrics.PollExecuteTime.observe(pollTimeSecs)
+	deviceMetrics.PollCounts[pp.PollPriority]++
+}
+
+// CountDeviceRequest counts a request sent to the device, by its result.
+func (pm *NetworkPollManager) CountDeviceRequest(deviceUUID string, result RequestResult) {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	metrics := pm.getDeviceMetrics(deviceUUID)
+	metrics.Requests++
+	switch result {
+	case REQUEST_OK:
+	case REQUEST_EXCEPTION:
+		metrics.Exceptions++
+	case REQUEST_TIMEOUT:
+		metrics.Timeouts++
+	case REQUEST_CRC_ERROR:
+		metrics.CRCErrors++
+	default:
+		metrics.FailedRequests++
+	}
+}
+
+// ForgetDeviceMetrics drops the counters of the device, eg: when it is deleted.
+func (pm *NetworkPollManager) ForgetDeviceMetrics(deviceUUID string) {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	delete(pm.deviceMetrics, deviceUUID)
+}
+
+// GetPollMetrics returns a copy of the statistics and metrics of the network.
+func (pm *NetworkPollManager) GetPollMetrics() *PollMetrics {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	metrics := &PollMetrics{
+		NetworkUUID:     pm.FFNetworkUUID,
+		NetworkName:     pm.NetworkName,
+		Enable:          pm.Enable,
+		Statistics:      pm.Statistics,
+		PollExecuteTime: pm.pollExecuteTime.copy(),
+		Devices:         make([]*DeviceMetrics, 0, len(pm.deviceMetrics)),
+	}
+	metrics.Statistics.PortUnavailableTime = pm.portUnavailableSecs()
+	for _, deviceMetrics := range pm.deviceMetrics {
+		deviceCopy := *deviceMetrics
+		deviceCopy.PollCounts = make(map[datatype.PollPriority]int64, len(deviceMetrics.PollCounts))
+		for priority, count := range deviceMetrics.PollCounts {
+			deviceCopy.PollCounts[priority] = count
+		}
+		deviceCopy.PollExecuteTime = deviceMetrics.PollExecuteTime.copy()
+		metrics.Devices = append(metrics.Devices, &deviceCopy)
+	}
+	return metrics
+}
@@ -13,6 +13,7 @@ type PollingPoint struct {
 	RepollTimer      *time.Timer
 	QueueEntryTime   int64
 	LockupAlertTimer *time.Timer
+	RepollTime       time.Time // when the RepollTimer fires, zero if it isn't running
 }
 
 func (pp *PollingPoint) resetPollingPointTimers() {
@@ -20,6 +21,7 @@ func (pp *PollingPoint) resetPollingPointTimers() {
 		pp.RepollTimer.Stop()
 		pp.RepollTimer = nil
 	}
+	pp.RepollTime = time.Time{}
 	if pp.LockupAlertTimer != nil {
 		pp.LockupAlertTimer.Stop()
 		pp.LockupAlertTimer = nil
@@ -27,11 +29,11 @@ func (pp *PollingPoint) resetPollingPointTimers() {
 }
 
 func NewPollingPoint(ffPointUUID, ffDeviceUUID, ffNetworkUUID string) *PollingPoint {
-	pp := &PollingPoint{datatype.PriorityNormal, ffPointUUID, ffDeviceUUID, ffNetworkUUID, nil, 0, nil}
+	pp := &PollingPoint{datatype.PriorityNormal, ffPointUUID, ffDeviceUUID, ffNetworkUUID, nil, 0, nil, time.Time{}}
 	return pp
 }
 
 func NewPollingPointWithPriority(ffPointUUID, ffDeviceUUID, ffNetworkUUID string, priority datatype.PollPriority) *PollingPoint {
-	pp := &PollingPoint{priority, ffPointUUID, ffDeviceUUID, ffNetworkUUID, nil, 0, nil}
+	pp := &PollingPoint{priority, ffPointUUID, ffDeviceUUID, ffNetworkUUID, nil, 0, nil, time.Time{}}
 	return pp
 }
@@ -2,6 +2,7 @@ package pollqueue
 
 import (
 	"container/heap"
+	"sync"
 	"time"
 
 	"github.com/NubeIO/lib-utils-go/nstring"
@@ -15,12 +16,15 @@ type NetworkPriorityPollQueue struct {
 	StandbyPollingPoints      *StandbyPollQueue  // contains polling points that are NOT in the active polling queue, it is mostly a reference so that we can periodically find out if any points have been dropped from polling.
 	PointsUpdatedWhilePolling map[string]bool    // UUIDs of points that have been updated while they were out for polling.  bool is true if the point needs to be written ASAP
 	QueueUnloader             QueueUnloader
+	mutex                     sync.Mutex // guards the QueueUnloader and PointsUpdatedWhilePolling, as points can be out for polling on several workers
 }
 
 type QueueUnloader struct {
-	NextPollPoint    *PollingPoint
-	CurrentPollPoint *PollingPoint // TODO: turn this back into a list later
-	RemoveCurrent    bool
+	NextPollPoint       *PollingPoint
+	CurrentPollPoints   map[string]*PollingPoint // polling points that are out for polling, by point UUID. Only one per device.
+	RemoveCurrentPoints map[string]bool          // UUIDs of CurrentPollPoints that have been removed while they were out for polling.
+	BlockPollPoints     map[string]*PollingPoint // polling points drawn to be read in the same request as one of the CurrentPollPoints.
+	RemoveBlockPoints   map[string]bool          // UUIDs of BlockPollPoints that have been removed while they were out for polling.
 }
 
 func NewNetworkPriorityPollQueue(config *Config) *NetworkPriorityPollQueue {
@@ -52,10 +56,15 @@ func (nq *NetworkPriorityPollQueue) AddToStandbyQueue(pp *PollingPoint) bool {
 
 func (nq *NetworkPriorityPollQueue) RemovePollingPointByPointUUID(pointUUID string) *PollingPoint {
 	nq.pollQueueDebugMsg("RemovePollingPointByPointUUID(): ", pointUUID)
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
 	var pp *PollingPoint = nil
-	if nq.QueueUnloader.CurrentPollPoint != nil && nq.QueueUnloader.CurrentPollPoint.FFPointUUID == pointUUID {
-		pp = nq.QueueUnloader.CurrentPollPoint // don't set to nil as the complete notification func needs to check
-		nq.QueueUnloader.RemoveCurrent = true
+	if currentPP, ok := nq.QueueUnloader.CurrentPollPoints[pointUUID]; ok {
+		pp = currentPP // don't remove as the complete notification func needs to check
+		nq.QueueUnloader.RemoveCurrentPoints[pointUUID] = true
+	} else if blockPP, ok := nq.QueueUnloader.BlockPollPoints[pointUUID]; ok {
+		pp = blockPP
+		nq.QueueUnloader.RemoveBlockPoints[pointUUID] = true
 	} else if nq.QueueUnloader.NextPollPoint != nil && nq.QueueUnloader.NextPollPoint.FFPointUUID == pointUUID {
 		pp = nq.QueueUnloader.NextPollPoint
 		nq.QueueUnloader.NextPollPoint = nil
@@ -90,12 +99,17 @@ func (nq *NetworkPriorityPollQueue) UpdatePollingPointByPointUUID(pointUUID stri
 
 func (nq *NetworkPriorityPollQueue) GetPollingPointByPointUUID(pointUUID string) *PollingPoint {
 	nq.pollQueueDebugMsg("NetworkPriorityPollQueue GetPollingPointByPointUUID(): ", pointUUID)
-	if nq.QueueUnloader.CurrentPollPoint != nil && nq.QueueUnloader.CurrentPollPoint.FFPointUUID == pointUUID {
-		return nq.QueueUnloader.CurrentPollPoint
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	if pp, ok := nq.QueueUnloader.CurrentPollPoints[pointUUID]; ok {
+		return pp
 	}
 	if nq.QueueUnloader.NextPollPoint != nil && nq.QueueUnloader.NextPollPoint.FFPointUUID == pointUUID {
 		return nq.QueueUnloader.NextPollPoint
 	}
+	if pp, ok := nq.QueueUnloader.BlockPollPoints[pointUUID]; ok {
+		return pp
+	}
 	pp, index := nq.PriorityQueue.GetPollingPointIndexByPointUUID(pointUUID)
 	if index != -1 {
 		return pp
@@ -109,15 +123,134 @@ func (nq *NetworkPriorityPollQueue) GetPollingPointByPointUUID(pointUUID string)
 }
 
 func (nq *NetworkPriorityPollQueue) GetNextPollingPoint() *PollingPoint {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
 	pp := nq.QueueUnloader.NextPollPoint
-	nq.QueueUnloader.CurrentPollPoint = pp
+	if pp != nil {
+		nq.QueueUnloader.CurrentPollPoints[pp.FFPointUUID] = pp
+	}
 	nq.QueueUnloader.NextPollPoint = nil
 	nq.setNextPollPoint()
 	return pp
 }
 
+// GetNextIdleDevicePollingPoint draws the highest priority polling point of a device that has no points out for polling,
+// so that each device is only polled by one worker at a time.
+func (nq *NetworkPriorityPollQueue) GetNextIdleDevicePollingPoint() *PollingPoint {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	busyDevices := make(map[string]bool)
+	for _, pp := range nq.QueueUnloader.CurrentPollPoints {
+		busyDevices[pp.FFDeviceUUID] = true
+	}
+	for _, pp := range nq.QueueUnloader.BlockPollPoints {
+		busyDevices[pp.FFDeviceUUID] = true
+	}
+
+	pp := nq.QueueUnloader.NextPollPoint
+	if pp != nil && !busyDevices[pp.FFDeviceUUID] {
+		nq.QueueUnloader.NextPollPoint = nil
+		nq.setNextPollPoint()
+	} else {
+		pp = nq.PriorityQueue.GetNextPollingPointExcludingDevices(busyDevices)
+		if pp == nil {
+			return nil
+		}
+		pp.resetPollingPointTimers()
+	}
+	nq.QueueUnloader.CurrentPollPoints[pp.FFPointUUID] = pp
+	return pp
+}
+
+// OutForPollingCount returns the number of polling points that are out for polling, including the points of block reads.
+func (nq *NetworkPriorityPollQueue) OutForPollingCount() int {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	return len(nq.QueueUnloader.CurrentPollPoints) + len(nq.QueueUnloader.BlockPollPoints)
+}
+
+// hasNextPollPoint checks if a polling point has been drawn from the PriorityQueue, ready for the next poll.
+func (nq *NetworkPriorityPollQueue) hasNextPollPoint() bool {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	return nq.QueueUnloader.NextPollPoint != nil
+}
+
+// takePointUpdatedWhilePolling returns and clears the PointsUpdatedWhilePolling flag of the point.
+func (nq *NetworkPriorityPollQueue) takePointUpdatedWhilePolling(pointUUID string) (asap bool, ok bool) {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	asap, ok = nq.PointsUpdatedWhilePolling[pointUUID]
+	if ok {
+		delete(nq.PointsUpdatedWhilePolling, pointUUID)
+	}
+	return asap, ok
+}
+
+// pollFinished clears the polling point from the points that are out for polling.
+func (nq *NetworkPriorityPollQueue) pollFinished(pp *PollingPoint) {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	if nq.QueueUnloader.CurrentPollPoints[pp.FFPointUUID] == pp {
+		delete(nq.QueueUnloader.CurrentPollPoints, pp.FFPointUUID)
+	}
+}
+
+// GetQueuedPollingPointsByDeviceUUID returns the polling points of a device that are waiting to be polled (NextPollPoint and PriorityQueue).
+func (nq *NetworkPriorityPollQueue) GetQueuedPollingPointsByDeviceUUID(deviceUUID string) []*PollingPoint {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	pps := nq.PriorityQueue.GetPollingPointsByDeviceUUID(deviceUUID)
+	if nq.QueueUnloader.NextPollPoint != nil && nq.QueueUnloader.NextPollPoint.FFDeviceUUID == deviceUUID {
+		pps = append(pps, nq.QueueUnloader.NextPollPoint)
+	}
+	return pps
+}
+
+// DrawBlockPollingPoint takes a waiting polling point out of the queue so that it can be read in the same request as one of the CurrentPollPoints.
+func (nq *NetworkPriorityPollQueue) DrawBlockPollingPoint(pointUUID string) *PollingPoint {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	var pp *PollingPoint = nil
+	if nq.QueueUnloader.NextPollPoint != nil && nq.QueueUnloader.NextPollPoint.FFPointUUID == pointUUID {
+		pp = nq.QueueUnloader.NextPollPoint
+		nq.QueueUnloader.NextPollPoint = nil
+		nq.setNextPollPoint()
+	} else {
+		pp = nq.PriorityQueue.RemovePollingPointByPointUUID(pointUUID)
+	}
+	if pp == nil {
+		return nil
+	}
+	pp.resetPollingPointTimers()
+	nq.QueueUnloader.BlockPollPoints[pointUUID] = pp
+	return pp
+}
+
+// isRemovedWhilePolling checks if the polling point was removed from polling while it was out for polling, and clears the removal flag.
+func (nq *NetworkPriorityPollQueue) isRemovedWhilePolling(pp *PollingPoint) bool {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	if _, ok := nq.QueueUnloader.BlockPollPoints[pp.FFPointUUID]; ok {
+		delete(nq.QueueUnloader.BlockPollPoints, pp.FFPointUUID)
+		if nq.QueueUnloader.RemoveBlockPoints[pp.FFPointUUID] {
+			delete(nq.QueueUnloader.RemoveBlockPoints, pp.FFPointUUID)
+			return true
+		}
+		return false
+	}
+	if nq.QueueUnloader.RemoveCurrentPoints[pp.FFPointUUID] {
+		delete(nq.QueueUnloader.RemoveCurrentPoints, pp.FFPointUUID)
+		delete(nq.QueueUnloader.CurrentPollPoints, pp.FFPointUUID)
+		return true
+	}
+	return false
+}
+
 func (nq *NetworkPriorityPollQueue) Start() {
-	nq.QueueUnloader = QueueUnloader{nil, nil, false}
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	nq.QueueUnloader = QueueUnloader{nil, make(map[string]*PollingPoint), make(map[string]bool), make(map[string]*PollingPoint), make(map[string]bool)}
 	nq.setNextPollPoint()
 }
 
@@ -128,13 +261,19 @@ func (nq *NetworkPriorityPollQueue) Stop() {
 func (nq *NetworkPriorityPollQueue) EmptyQueue() {
 	nq.PriorityQueue.EmptyQueue()
 	nq.StandbyPollingPoints.EmptyQueue()
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
 	if nq.QueueUnloader.NextPollPoint != nil {
 		nq.QueueUnloader.NextPollPoint.resetPollingPointTimers()
 		nq.QueueUnloader.NextPollPoint = nil
 	}
-	if nq.QueueUnloader.CurrentPollPoint != nil {
-		nq.QueueUnloader.CurrentPollPoint.resetPollingPointTimers()
-		nq.QueueUnloader.CurrentPollPoint = nil
+	for pointUUID, pp := range nq.QueueUnloader.CurrentPollPoints {
+		pp.resetPollingPointTimers()
+		nq.QueueUnloader.RemoveCurrentPoints[pointUUID] = true
+	}
+	for pointUUID, pp := range nq.QueueUnloader.BlockPollPoints {
+		pp.resetPollingPointTimers()
+		nq.QueueUnloader.RemoveBlockPoints[pointUUID] = true
 	}
 }
 
@@ -0,0 +1,224 @@
+package pollqueue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+// PollStats are the results of the polls of a device or point, since polling started or the stats were reset.
+type PollStats struct {
+	SuccessCount            int64      `json:"success_count"`
+	FailureCount            int64      `json:"failure_count"`
+	LastSuccess             *time.Time `json:"last_success,omitempty"`
+	LastFailure             *time.Time `json:"last_failure,omitempty"`
+	MinResponseTimeSecs     float64    `json:"min_response_time_secs"` // time to execute a successful poll
+	AverageResponseTimeSecs float64    `json:"average_response_time_secs"`
+	MaxResponseTimeSecs     float64    `json:"max_response_time_secs"`
+	TotalPollTimeSecs       float64    `json:"total_poll_time_secs"` // time spent on the bus, including failed polls
+}
+
+// PointPollStats are the poll stats of a point, with the interval its polls actually succeed at.
+type PointPollStats struct {
+	PointUUID  string `json:"point_uuid"`
+	PointName  string `json:"point_name,omitempty"`
+	DeviceUUID string `json:"device_uuid"`
+	PollStats
+	PollRate                   datatype.PollRate `json:"poll_rate"`
+	ConfiguredPollIntervalSecs float64           `json:"configured_poll_interval_secs"` // from the poll rate of the device
+	AveragePollIntervalSecs    float64           `json:"average_poll_interval_secs"`    // between successful polls, 0 until polled twice
+
+	pollIntervalCount int64
+}
+
+// PollRateStats compares the interval the points of a device are configured to poll at, with the one they achieve.
+type PollRateStats struct {
+	PollRate                   datatype.PollRate `json:"poll_rate"`
+	Points                     int               `json:"points"`
+	ConfiguredPollIntervalSecs float64           `json:"configured_poll_interval_secs"`
+	AveragePollIntervalSecs    float64           `json:"average_poll_interval_secs"`
+}
+
+// DevicePollStats are the poll stats of a device, the sum of the stats of its points.
+type DevicePollStats struct {
+	DeviceUUID string `json:"device_uuid"`
+	DeviceName string `json:"device_name,omitempty"`
+	PollStats
+	PollRates []*PollRateStats  `json:"poll_rates"`
+	Points    []*PointPollStats `json:"points,omitempty"`
+}
+
+func (s *PollStats) update(success bool, pollTimeSecs float64, now time.Time) {
+	s.TotalPollTimeSecs += pollTimeSecs
+	if !success {
+		s.FailureCount++
+		s.LastFailure = &now
+		return
+	}
+	if s.SuccessCount == 0 || pollTimeSecs < s.MinResponseTimeSecs {
+		s.MinResponseTimeSecs = pollTimeSecs
+	}
+	if pollTimeSecs > s.MaxResponseTimeSecs {
+		s.MaxResponseTimeSecs = pollTimeSecs
+	}
+	s.AverageResponseTimeSecs = ((s.AverageResponseTimeSecs * float64(s.SuccessCount)) + pollTimeSecs) / (float64(s.SuccessCount) + 1)
+	s.SuccessCount++
+	s.LastSuccess = &now
+}
+
+func (s *PollStats) copy() PollStats {
+	statsCopy := *s
+	if s.LastSuccess != nil {
+		lastSuccess := *s.LastSuccess
+		statsCopy.LastSuccess = &lastSuccess
+	}
+	if s.LastFailure != nil {
+		lastFailure := *s.LastFailure
+		statsCopy.LastFailure = &lastFailure
+	}
+	return statsCopy
+}
+
+// pollStatsUpdate counts a poll of the point that was sent to the device. Polls that were skipped, eg: as the device
+// is offline or paused, are finished with pollingWasNotRequired and aren't counted.
+func (pm *NetworkPollManager) pollStatsUpdate(pp *PollingPoint, point *model.Point, success bool, pollTimeSecs float64) {
+	var configuredInterval time.Duration
+	if _, ok := pm.DeviceDurations[pp.FFDeviceUUID]; ok {
+		configuredInterval = pm.GetPollRateDuration(point.PollRate, pp.FFDeviceUUID)
+	}
+
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	now := time.Now()
+	deviceStats, ok := pm.devicePollStats[pp.FFDeviceUUID]
+	if !ok {
+		deviceStats = &PollStats{}
+		pm.devicePollStats[pp.FFDeviceUUID] = deviceStats
+	}
+	deviceStats.update(success, pollTimeSecs, now)
+
+	pointStats, ok := pm.pointPollStats[pp.FFPointUUID]
+	if !ok {
+		pointStats = &PointPollStats{PointUUID: pp.FFPointUUID}
+		pm.pointPollStats[pp.FFPointUUID] = pointStats
+	}
+	pointStats.DeviceUUID = pp.FFDeviceUUID
+	pointStats.PollRate = point.PollRate
+	pointStats.ConfiguredPollIntervalSecs = configuredInterval.Seconds()
+	if success && pointStats.LastSuccess != nil {
+		interval := now.Sub(*pointStats.LastSuccess).Seconds()
+		pointStats.AveragePollIntervalSecs = ((pointStats.AveragePollIntervalSecs * float64(pointStats.pollIntervalCount)) + interval) / (float64(pointStats.pollIntervalCount) + 1)
+		pointStats.pollIntervalCount++
+	}
+	pointStats.update(success, pollTimeSecs, now)
+}
+
+// GetPointPollStats returns a copy of the poll stats of the point, or nil if it hasn't been polled.
+func (pm *NetworkPollManager) GetPointPollStats(pointUUID string) *PointPollStats {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	pointStats, ok := pm.pointPollStats[pointUUID]
+	if !ok {
+		return nil
+	}
+	return pointStats.copy()
+}
+
+func (s *PointPollStats) copy() *PointPollStats {
+	statsCopy := *s
+	statsCopy.PollStats = s.PollStats.copy()
+	return &statsCopy
+}
+
+// GetDevicePollStats returns a copy of the poll stats of the device and its points, or nil if it hasn't been polled.
+func (pm *NetworkPollManager) GetDevicePollStats(deviceUUID string) *DevicePollStats {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	return pm.devicePollStatsCopy(deviceUUID)
+}
+
+// GetAllDevicePollStats returns the poll stats of all polled devices of the network, without the stats of their points.
+func (pm *NetworkPollManager) GetAllDevicePollStats() []*DevicePollStats {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	allStats := make([]*DevicePollStats, 0, len(pm.devicePollStats))
+	for deviceUUID := range pm.devicePollStats {
+		deviceStats := pm.devicePollStatsCopy(deviceUUID)
+		deviceStats.Points = nil
+		allStats = append(allStats, deviceStats)
+	}
+	sort.Slice(allStats, func(i, j int) bool {
+		return allStats[i].TotalPollTimeSecs > allStats[j].TotalPollTimeSecs
+	})
+	return allStats
+}
+
+func (pm *NetworkPollManager) devicePollStatsCopy(deviceUUID string) *DevicePollStats {
+	stats, ok := pm.devicePollStats[deviceUUID]
+	if !ok {
+		return nil
+	}
+	deviceStats := &DevicePollStats{
+		DeviceUUID: deviceUUID,
+		PollStats:  stats.copy(),
+		PollRates:  make([]*PollRateStats, 0),
+		Points:     make([]*PointPollStats, 0),
+	}
+	byRate := make(map[datatype.PollRate]*PollRateStats)
+	intervalCounts := make(map[datatype.PollRate]int64)
+	for _, pointStats := range pm.pointPollStats {
+		if pointStats.DeviceUUID != deviceUUID {
+			continue
+		}
+		deviceStats.Points = append(deviceStats.Points, pointStats.copy())
+		rateStats, ok := byRate[pointStats.PollRate]
+		if !ok {
+			rateStats = &PollRateStats{PollRate: pointStats.PollRate}
+			byRate[pointStats.PollRate] = rateStats
+			deviceStats.PollRates = append(deviceStats.PollRates, rateStats)
+		}
+		rateStats.Points++
+		rateStats.ConfiguredPollIntervalSecs = pointStats.ConfiguredPollIntervalSecs
+		count := intervalCounts[pointStats.PollRate] + pointStats.pollIntervalCount
+		if count > 0 {
+			rateStats.AveragePollIntervalSecs = ((rateStats.AveragePollIntervalSecs * float64(intervalCounts[pointStats.PollRate])) + (pointStats.AveragePollIntervalSecs * float64(pointStats.pollIntervalCount))) / float64(count)
+		}
+		intervalCounts[pointStats.PollRate] = count
+	}
+	sort.Slice(deviceStats.PollRates, func(i, j int) bool {
+		return deviceStats.PollRates[i].ConfiguredPollIntervalSecs < deviceStats.PollRates[j].ConfiguredPollIntervalSecs
+	})
+	sort.Slice(deviceStats.Points, func(i, j int) bool {
+		return deviceStats.Points[i].TotalPollTimeSecs > deviceStats.Points[j].TotalPollTimeSecs
+	})
+	return deviceStats
+}
+
+// ResetPointPollStats clears the poll stats of the point. The stats of its device still include its polls.
+func (pm *NetworkPollManager) ResetPointPollStats(pointUUID string) {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	delete(pm.pointPollStats, pointUUID)
+}
+
+// ResetDevicePollStats clears the poll stats of the device and its points, eg: when it is deleted.
+func (pm *NetworkPollManager) ResetDevicePollStats(deviceUUID string) {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	delete(pm.devicePollStats, deviceUUID)
+	for pointUUID, pointStats := range pm.pointPollStats {
+		if pointStats.DeviceUUID == deviceUUID {
+			delete(pm.pointPollStats, pointUUID)
+		}
+	}
+}
+
+// ResetAllPollStats clears the poll stats of all devices and points of the network.
+func (pm *NetworkPollManager) ResetAllPollStats() {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	pm.devicePollStats = make(map[string]*PollStats)
+	pm.pointPollStats = make(map[string]*PointPollStats)
+}
@@ -46,6 +46,8 @@ type PollStatistics struct {
 
 func (pm *NetworkPollManager) GetPollingQueueStatistics() *dto.PollQueueStatistics {
 	pm.pollQueueDebugMsg("GetPollingQueueStatistics()")
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
 	stats := dto.PollQueueStatistics{}
 	stats.Enable = pm.Enable
 
@@ -90,12 +92,39 @@ func (pm *NetworkPollManager) GetPollingQueueStatistics() *dto.PollQueueStatisti
 	stats.BusyTime = fmt.Sprintf("%.1f%%", pm.Statistics.BusyTime)
 	EnabledTime, _ := time.ParseDuration(fmt.Sprintf("%fs", pm.Statistics.EnabledTime))
 	stats.EnabledTime = EnabledTime.String()
-	PortUnavailableTime, _ := time.ParseDuration(fmt.Sprintf("%fs", pm.Statistics.PortUnavailableTime))
+	PortUnavailableTime, _ := time.ParseDuration(fmt.Sprintf("%fs", pm.portUnavailableSecs()))
 	stats.PortUnavailableTime = PortUnavailableTime.String()
 
 	return &stats
 }
 
+// portUnavailableStatsUpdate adds the time since the last update to PortUnavailableTime, while the port is unavailable.
+// Caller must hold the statsMutex.
+func (pm *NetworkPollManager) portUnavailableStatsUpdate() {
+	if pm.Statistics.PortUnavailableStartTime != 0 {
+		now := time.Now().Unix() // whole seconds, so that frequent updates don't add up rounding errors
+		pm.Statistics.PortUnavailableTime += float64(now - pm.Statistics.PortUnavailableStartTime)
+		pm.Statistics.PortUnavailableStartTime = now
+	}
+}
+
+// portUnavailableSecs returns PortUnavailableTime with the time since its last update, without updating it, so that
+// reading the statistics doesn't change them. Caller must hold the statsMutex.
+func (pm *NetworkPollManager) portUnavailableSecs() float64 {
+	if pm.Statistics.PortUnavailableStartTime == 0 {
+		return pm.Statistics.PortUnavailableTime
+	}
+	return pm.Statistics.PortUnavailableTime + float64(time.Now().Unix()-pm.Statistics.PortUnavailableStartTime)
+}
+
+// portUnavailableEnded adds the last of the downtime of the port to PortUnavailableTime, and stops counting it.
+func (pm *NetworkPollManager) portUnavailableEnded() {
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
+	pm.portUnavailableStatsUpdate()
+	pm.Statistics.PortUnavailableStartTime = 0
+}
+
 func (pm *NetworkPollManager) StartPollingStatistics() {
 	pm.pollQueueDebugMsg("StartPollingStatistics()")
 	pm.Statistics.PollingStartTimeUnix = time.Now().Unix()
@@ -125,6 +154,8 @@ func (pm *NetworkPollManager) StartPollingStatistics() {
 
 func (pm *NetworkPollManager) PollCompleteStatsUpdate(pp *PollingPoint, pollTimeSecs float64) {
 	pm.pollQueueDebugMsg("PollCompleteStatsUpdate()")
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
 
 	if pm.Statistics.MaxPollExecuteTimeSecs == 0 || pollTimeSecs > pm.Statistics.MaxPollExecuteTimeSecs {
 		pm.Statistics.MaxPollExecuteTimeSecs = pollTimeSecs
@@ -134,18 +165,16 @@ func (pm *NetworkPollManager) PollCompleteStatsUpdate(pp *PollingPoint, pollTime
 	}
 	pm.Statistics.AveragePollExecuteTimeSecs = ((pm.Statistics.AveragePollExecuteTimeSecs * float64(pm.Statistics.TotalPollCount)) + pollTimeSecs) / (float64(pm.Statistics.TotalPollCount) + 1)
 	pm.Statistics.TotalPollCount++
+	pm.pollMetricsUpdate(pp, pollTimeSecs)
 	pm.Statistics.EnabledTime = time.Since(time.Unix(pm.Statistics.PollingStartTimeUnix, 0)).Seconds()
 	pm.Statistics.BusyTime = math.Round((((pm.Statistics.AveragePollExecuteTimeSecs*float64(pm.Statistics.TotalPollCount))/pm.Statistics.EnabledTime)*100)*1000) / 1000 // percentage rounded to 3 decimal places
 
 	pm.Statistics.TotalPollQueueLength = int64(pm.PollQueue.PriorityQueue.Len())
-	if pm.PollQueue.QueueUnloader.NextPollPoint != nil {
+	if pm.PollQueue.hasNextPollPoint() {
 		pm.Statistics.TotalPollQueueLength++
 	}
 	pm.Statistics.TotalStandbyPointsLength = int64(pm.PollQueue.StandbyPollingPoints.Len())
-	pm.Statistics.TotalPointsOutForPolling = 0
-	if pm.PollQueue.QueueUnloader.CurrentPollPoint != nil {
-		pm.Statistics.TotalPointsOutForPolling++
-	}
+	pm.Statistics.TotalPointsOutForPolling = int64(pm.PollQueue.OutForPollingCount())
 
 	pm.Statistics.ASAPPriorityPollQueueLength = 0
 	pm.Statistics.HighPriorityPollQueueLength = 0
@@ -210,22 +239,18 @@ func (pm *NetworkPollManager) PollCompleteStatsUpdate(pp *PollingPoint, pollTime
 
 func (pm *NetworkPollManager) PartialPollStatsUpdate() {
 	pm.pollQueueDebugMsg("PartialPollStatsUpdate()")
+	pm.statsMutex.Lock()
+	defer pm.statsMutex.Unlock()
 	pm.Statistics.TotalPollQueueLength = int64(pm.PollQueue.PriorityQueue.Len())
-	if pm.PollQueue.QueueUnloader.NextPollPoint != nil {
+	if pm.PollQueue.hasNextPollPoint() {
 		pm.Statistics.TotalPollQueueLength++
 	}
 	pm.Statistics.TotalStandbyPointsLength = int64(pm.PollQueue.StandbyPollingPoints.Len())
-	pm.Statistics.TotalPointsOutForPolling = 0
-	if pm.PollQueue.QueueUnloader.CurrentPollPoint != nil {
-		pm.Statistics.TotalPointsOutForPolling++
-	}
+	pm.Statistics.TotalPointsOutForPolling = int64(pm.PollQueue.OutForPollingCount())
 
 	pm.Statistics.EnabledTime = time.Since(time.Unix(pm.Statistics.PollingStartTimeUnix, 0)).Seconds()
 
-	if pm.PortUnavailableTimeout != nil {
-		pm.Statistics.PortUnavailableTime += time.Since(time.Unix(pm.Statistics.PortUnavailableStartTime, 0)).Seconds()
-		pm.Statistics.PortUnavailableStartTime = time.Now().Unix()
-	}
+	pm.portUnavailableStatsUpdate()
 
 	pm.Statistics.ASAPPriorityPollQueueLength = 0
 	pm.Statistics.HighPriorityPollQueueLength = 0
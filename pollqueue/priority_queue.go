@@ -147,6 +147,18 @@ func (q *PriorityPollQueue) RemovePollingPointByNetworkUUID(networkUUID string)
 	return true
 }
 
+func (q *PriorityPollQueue) GetPollingPointsByDeviceUUID(deviceUUID string) []*PollingPoint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pps := make([]*PollingPoint, 0)
+	for _, pp := range q.priorityQueue {
+		if pp.FFDeviceUUID == deviceUUID {
+			pps = append(pps, pp)
+		}
+	}
+	return pps
+}
+
 func (q *PriorityPollQueue) AddPollingPoint(pp *PollingPoint) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -188,3 +200,22 @@ func (q *PriorityPollQueue) GetNextPollingPoint() (*PollingPoint, error) {
 	}
 	return nil, errors.New("PriorityPollQueue is not enabled")
 }
+
+// GetNextPollingPointExcludingDevices pops the highest priority polling point that isn't on one of the excluded devices.
+func (q *PriorityPollQueue) GetNextPollingPointExcludingDevices(excludedDevices map[string]bool) *PollingPoint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	next := -1
+	for index, pp := range q.priorityQueue {
+		if excludedDevices[pp.FFDeviceUUID] {
+			continue
+		}
+		if next == -1 || q.Less(index, next) {
+			next = index
+		}
+	}
+	if next == -1 {
+		return nil
+	}
+	return heap.Remove(q, next).(*PollingPoint)
+}
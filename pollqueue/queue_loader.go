@@ -25,10 +25,11 @@ func (pm *NetworkPollManager) RebuildPollingQueue() error {
 	pm.pollQueueDebugMsg("RebuildPollingQueue()")
 	pm.StopPolling()
 	net, err := pm.Marshaller.GetNetwork(pm.FFNetworkUUID, &nmodule.Opts{Args: &nargs.Args{WithDevices: true, WithPoints: true}})
-	if err != nil || net.Devices == nil || len(net.Devices) == 0 {
-		pm.pollQueueDebugMsg("RebuildPollingQueue() couldn't find any devices for the network %s", pm.FFNetworkUUID)
-		return errors.New(fmt.Sprintf("NetworkPollManager.RebuildPollingQueue: couldn't find any devices for the network %s", pm.FFNetworkUUID))
+	if err != nil || net == nil {
+		pm.pollQueueDebugMsg("RebuildPollingQueue() couldn't find the network %s", pm.FFNetworkUUID)
+		return errors.New(fmt.Sprintf("NetworkPollManager.RebuildPollingQueue: couldn't find the network %s", pm.FFNetworkUUID))
 	}
+	// a network without devices has an empty queue, its points are added as they are created
 	devs := net.Devices
 	for _, dev := range devs {
 		if boolean.IsFalse(dev.Enable) {
@@ -58,13 +59,17 @@ func (pm *NetworkPollManager) PollingPointCompleteNotification(pp *PollingPoint,
 	if !pointUpdate {
 		// This will update the relevant PollManager statistics
 		pm.PollCompleteStatsUpdate(pp, pollTimeSecs)
+		if !pollingWasNotRequired && point != nil {
+			pm.pollStatsUpdate(pp, point, writeSuccess || readSuccess, pollTimeSecs)
+		}
 	}
 
 	pp.resetPollingPointTimers()
+	// the device stays busy until the point is re-added, so no other worker polls it in the meantime
+	defer pm.PollQueue.pollFinished(pp)
 
 	// point was deleted while it was out for polling
-	if point == nil || (pm.PollQueue.QueueUnloader.RemoveCurrent && pm.PollQueue.QueueUnloader.CurrentPollPoint.FFPointUUID == pp.FFPointUUID) {
-		pm.PollQueue.QueueUnloader.RemoveCurrent = false
+	if pm.PollQueue.isRemovedWhilePolling(pp) || point == nil {
 		return
 	}
 
@@ -74,9 +79,8 @@ func (pm *NetworkPollManager) PollingPointCompleteNotification(pp *PollingPoint,
 	}
 
 	// instantly re-add if it was updated while polling
-	val, ok := pm.PollQueue.PointsUpdatedWhilePolling[point.UUID]
+	val, ok := pm.PollQueue.takePointUpdatedWhilePolling(point.UUID)
 	if ok {
-		delete(pm.PollQueue.PointsUpdatedWhilePolling, point.UUID)
 		if val == true { // point needs an ASAP write
 			pp.PollPriority = datatype.PriorityASAP
 			pm.AddToPriorityQueue(pp)
@@ -238,8 +242,6 @@ func (pm *NetworkPollManager) PollingPointCompleteNotification(pp *PollingPoint,
 		pm.pollQueueErrorMsg(fmt.Sprintf("Modbus PollingPointCompleteNotification(): polling point could not be added to StandbyPollingPoints slice.  (%s)", pp.FFPointUUID))
 	}
 
-	pm.PollQueue.QueueUnloader.CurrentPollPoint = nil
-
 	if fixNilPollReq || *point.ReadPollRequired != origReadPollReq || *point.WritePollRequired != origWritePollReq {
 		point, _ = pm.Marshaller.UpdatePoint(point.UUID, point)
 	}
@@ -253,6 +255,7 @@ func (pm *NetworkPollManager) AddToPriorityQueue(pp *PollingPoint) {
 
 func (pm *NetworkPollManager) AddToStandbyQueueWithRePoll(pp *PollingPoint, point *model.Point) bool {
 	duration := pm.GetPollRateDuration(point.PollRate, pp.FFDeviceUUID)
+	pp.RepollTime = time.Now().Add(duration)
 	pp.RepollTimer = time.AfterFunc(duration, pm.MakePollingPointRepollCallback(pp))
 	return pm.PollQueue.AddToStandbyQueue(pp)
 }
@@ -262,7 +265,9 @@ func (pm *NetworkPollManager) MakePollingPointRepollCallback(pp *PollingPoint) f
 		pp.RepollTimer = nil
 		ppOld := pm.PollQueue.RemoveFromStandbyQueue(pp)
 		if ppOld == nil {
-			pm.pollQueueErrorMsg(fmt.Sprintf("Modbus MakePollingPointRepollCallback(): polling point could not be found in StandbyPollingPoints.  (%s)", pp.FFPointUUID))
+			// the point left the standby queue after the timer fired, eg: it was polled now, so it is queued already
+			pm.pollQueueDebugMsg(fmt.Sprintf("Modbus MakePollingPointRepollCallback(): polling point could not be found in StandbyPollingPoints.  (%s)", pp.FFPointUUID))
+			return
 		}
 		pm.AddToPriorityQueue(pp)
 	}
@@ -0,0 +1,109 @@
+package pollqueue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+)
+
+// QueuedPoint is a polling point in a snapshot of the poll queue.
+type QueuedPoint struct {
+	PointUUID      string                `json:"point_uuid"`
+	PointName      string                `json:"point_name,omitempty"`
+	DeviceUUID     string                `json:"device_uuid"`
+	DeviceName     string                `json:"device_name,omitempty"`
+	PollPriority   datatype.PollPriority `json:"poll_priority"`
+	QueueEntryTime *time.Time            `json:"queue_entry_time,omitempty"` // when it was last added to the priority queue
+	RepollInSecs   *float64              `json:"repoll_in_secs,omitempty"`   // time until its repoll timer fires, standby points only
+}
+
+// PollQueueSnapshot lists the points of each part of the poll queue of a network, at the time it was taken.
+type PollQueueSnapshot struct {
+	NetworkUUID   string         `json:"network_uuid"`
+	NetworkName   string         `json:"network_name"`
+	Enable        bool           `json:"enable"`
+	Time          time.Time      `json:"time"`
+	CurrentPoints []*QueuedPoint `json:"current_points"` // out for polling
+	BlockPoints   []*QueuedPoint `json:"block_points"`   // read in the same request as one of the current points
+	NextPoint     *QueuedPoint   `json:"next_point"`
+	PriorityQueue []*QueuedPoint `json:"priority_queue"` // in poll order
+	StandbyPoints []*QueuedPoint `json:"standby_points"` // the next to repoll first
+}
+
+func newQueuedPoint(pp *PollingPoint) *QueuedPoint {
+	queued := &QueuedPoint{
+		PointUUID:    pp.FFPointUUID,
+		DeviceUUID:   pp.FFDeviceUUID,
+		PollPriority: pp.PollPriority,
+	}
+	if pp.QueueEntryTime != 0 {
+		entryTime := time.Unix(pp.QueueEntryTime, 0)
+		queued.QueueEntryTime = &entryTime
+	}
+	return queued
+}
+
+// GetPollQueueSnapshot returns the points that are out for polling, next to be polled, in the priority queue, and in
+// standby waiting for their repoll timers.
+func (pm *NetworkPollManager) GetPollQueueSnapshot() *PollQueueSnapshot {
+	nq := pm.PollQueue
+	snapshot := &PollQueueSnapshot{
+		NetworkUUID:   pm.FFNetworkUUID,
+		NetworkName:   pm.NetworkName,
+		Enable:        pm.Enable,
+		Time:          time.Now(),
+		CurrentPoints: make([]*QueuedPoint, 0),
+		BlockPoints:   make([]*QueuedPoint, 0),
+		PriorityQueue: make([]*QueuedPoint, 0),
+		StandbyPoints: make([]*QueuedPoint, 0),
+	}
+
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+	for _, pp := range nq.QueueUnloader.CurrentPollPoints {
+		snapshot.CurrentPoints = append(snapshot.CurrentPoints, newQueuedPoint(pp))
+	}
+	for _, pp := range nq.QueueUnloader.BlockPollPoints {
+		snapshot.BlockPoints = append(snapshot.BlockPoints, newQueuedPoint(pp))
+	}
+	if nq.QueueUnloader.NextPollPoint != nil {
+		snapshot.NextPoint = newQueuedPoint(nq.QueueUnloader.NextPollPoint)
+	}
+	sort.Slice(snapshot.CurrentPoints, func(i, j int) bool {
+		return snapshot.CurrentPoints[i].DeviceUUID < snapshot.CurrentPoints[j].DeviceUUID
+	})
+	sort.Slice(snapshot.BlockPoints, func(i, j int) bool {
+		return snapshot.BlockPoints[i].DeviceUUID < snapshot.BlockPoints[j].DeviceUUID
+	})
+
+	nq.PriorityQueue.mu.Lock()
+	ordered := &PriorityPollQueue{priorityQueue: append([]*PollingPoint(nil), nq.PriorityQueue.priorityQueue...)}
+	sort.Stable(ordered)
+	for _, pp := range ordered.priorityQueue {
+		snapshot.PriorityQueue = append(snapshot.PriorityQueue, newQueuedPoint(pp))
+	}
+	nq.PriorityQueue.mu.Unlock()
+
+	nq.StandbyPollingPoints.mu.Lock()
+	for _, pp := range nq.StandbyPollingPoints.queue {
+		queued := newQueuedPoint(pp)
+		if !pp.RepollTime.IsZero() {
+			repollIn := pp.RepollTime.Sub(snapshot.Time).Seconds()
+			if repollIn < 0 {
+				repollIn = 0
+			}
+			queued.RepollInSecs = &repollIn
+		}
+		snapshot.StandbyPoints = append(snapshot.StandbyPoints, queued)
+	}
+	nq.StandbyPollingPoints.mu.Unlock()
+	sort.SliceStable(snapshot.StandbyPoints, func(i, j int) bool {
+		iRepoll, jRepoll := snapshot.StandbyPoints[i].RepollInSecs, snapshot.StandbyPoints[j].RepollInSecs
+		if iRepoll == nil || jRepoll == nil {
+			return jRepoll == nil && iRepoll != nil // points without a repoll timer last
+		}
+		return *iRepoll < *jRepoll
+	})
+	return snapshot
+}
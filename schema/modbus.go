@@ -3,8 +3,8 @@ package schema
 type DataType struct {
 	Type     string   `json:"type" default:"string"`
 	Title    string   `json:"title" default:"Data Type"`
-	Options  []string `json:"enum" default:"[\"digital\",\"uint16\",\"int16\",\"uint32\",\"int32\",\"uint64\",\"int64\",\"float32\",\"float64\",\"mod10-u32\"]"`
-	EnumName []string `json:"enumNames" default:"[\"digital\",\"uint16\",\"int16\",\"uint32\",\"int32\",\"uint64\",\"int64\",\"float32\",\"float64\",\"mod10-u32\"]"`
+	Options  []string `json:"enum" default:"[\"digital\",\"uint16\",\"int16\",\"uint32\",\"int32\",\"uint64\",\"int64\",\"float32\",\"float64\",\"mod10-u32\",\"mod10-i32\",\"mod10-u48\",\"mod10-u64\",\"int48\",\"uint48\",\"bcd16\",\"bcd32\",\"string\"]"`
+	EnumName []string `json:"enumNames" default:"[\"digital\",\"uint16\",\"int16\",\"uint32\",\"int32\",\"uint64\",\"int64\",\"float32\",\"float64\",\"mod10-u32\",\"mod10-i32\",\"mod10-u48\",\"mod10-u64\",\"int48\",\"uint48\",\"bcd16\",\"bcd32\",\"string\"]"`
 	Default  string   `json:"default" default:"uint16"`
 	ReadOnly bool     `json:"readOnly" default:"false"`
 }
@@ -27,6 +27,15 @@ type ObjectTypeModbus struct {
 	ReadOnly bool     `json:"readOnly" default:"false"`
 }
 
+type TransportTypeModbus struct {
+	Type     string   `json:"type" default:"string"`
+	Title    string   `json:"title" default:"Network Transport Type"`
+	Options  []string `json:"enum" default:"[\"serial\",\"ip\",\"LoRa\",\"rtu_over_tcp\",\"ascii\",\"tcp_server\"]"`
+	EnumName []string `json:"enumNames" default:"[\"serial\",\"ip\",\"LoRa\",\"rtu over tcp\",\"ascii (serial)\",\"tcp server (slave)\"]"`
+	Default  string   `json:"default" default:"serial"`
+	ReadOnly bool     `json:"readOnly" default:"false"`
+}
+
 type SerialPortModbus struct {
 	Type     string   `json:"type" default:"string"`
 	Title    string   `json:"title" default:"Serial Port"`
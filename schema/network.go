@@ -10,19 +10,24 @@ type NetworkSchema struct {
 	Description    schema.Description    `json:"description"`
 	Enable         schema.Enable         `json:"enable"`
 	PluginName     schema.PluginName     `json:"plugin_name"`
-	TransportType  schema.TransportType  `json:"transport_type"`
+	TransportType  TransportTypeModbus   `json:"transport_type"`
 	SerialPort     SerialPortModbus      `json:"serial_port"`
 	SerialBaudRate schema.SerialBaudRate `json:"serial_baud_rate"`
 	SerialParity   schema.SerialParity   `json:"serial_parity"`
 	SerialDataBits schema.SerialDataBits `json:"serial_data_bits"`
 	SerialStopBits schema.SerialStopBits `json:"serial_stop_bits"`
 	SerialTimeout  schema.SerialTimeout  `json:"serial_timeout"`
+	Ip             schema.Ip             `json:"ip"`
+	Port           schema.Port           `json:"port"`
 	MaxPollRate    schema.MaxPollRate    `json:"max_poll_rate"`
 	HistoryEnable  schema.HistoryEnable  `json:"history_enable"`
 }
 
 func GetNetworkSchema() *NetworkSchema {
 	m := &NetworkSchema{}
+	m.Ip.Title = "Server Listen Address"
+	m.Port.Title = "Server Port"
+	m.Port.Default = 502
 	schema.Set(m)
 	return m
 }
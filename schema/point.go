@@ -17,10 +17,19 @@ type PointSchema struct {
 		Title       string `json:"title" default:"Register"`
 		Default     int    `json:"default" default:"1"`
 		Minimum     int    `json:"minimum" default:"1"`
-		Maximim     int    `json:"maximum" default:"9999"`
+		Maximum     int    `json:"maximum" default:"9999"`
 		ReadOnly    bool   `json:"readOnly" default:"false"`
 		Description string `json:"description" default:"Decimal format: 1-9999"`
 	} `json:"address_id"`
+	AddressLength struct {
+		Type        string `json:"type" default:"number"`
+		Title       string `json:"title" default:"Register Length"`
+		Default     int    `json:"default" default:"1"`
+		Minimum     int    `json:"minimum" default:"1"`
+		Maximum     int    `json:"maximum" default:"123"`
+		ReadOnly    bool   `json:"readOnly" default:"false"`
+		Description string `json:"description" default:"Number of registers of string points"`
+	} `json:"address_length"`
 	DataType       DataType         `json:"data_type"`
 	WriteMode      schema.WriteMode `json:"write_mode"`
 	ObjectEncoding ObjectEncoding   `json:"object_encoding"`
@@ -0,0 +1,229 @@
+package simulator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NubeIO/module-core-modbus/mbserver"
+)
+
+// Device is a simulated Modbus slave with a programmable register map. Only the addresses that are set exist, reading
+// or writing any other address returns an illegal data address exception.
+type Device struct {
+	UnitId uint8
+
+	mutex            sync.Mutex
+	coils            map[uint16]bool
+	discreteInputs   map[uint16]bool
+	holdingRegisters map[uint16]uint16
+	inputRegisters   map[uint16]uint16
+
+	delay      time.Duration               // before each response
+	exceptions map[byte]mbserver.Exception // by function code, 0 for all function codes
+	drops      int                         // requests left to drop without a response
+	requests   int
+}
+
+// NewDevice returns a device with an empty register map.
+func NewDevice(unitId uint8) *Device {
+	return &Device{
+		UnitId:           unitId,
+		coils:            make(map[uint16]bool),
+		discreteInputs:   make(map[uint16]bool),
+		holdingRegisters: make(map[uint16]uint16),
+		inputRegisters:   make(map[uint16]uint16),
+		exceptions:       make(map[byte]mbserver.Exception),
+	}
+}
+
+// SetCoils sets the coils from addr (0 based, as sent on the bus).
+func (d *Device) SetCoils(addr uint16, values ...bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, value := range values {
+		d.coils[addr+uint16(i)] = value
+	}
+}
+
+// SetDiscreteInputs sets the discrete inputs from addr.
+func (d *Device) SetDiscreteInputs(addr uint16, values ...bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, value := range values {
+		d.discreteInputs[addr+uint16(i)] = value
+	}
+}
+
+// SetHoldingRegisters sets the holding registers from addr.
+func (d *Device) SetHoldingRegisters(addr uint16, values ...uint16) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, value := range values {
+		d.holdingRegisters[addr+uint16(i)] = value
+	}
+}
+
+// SetInputRegisters sets the input registers from addr.
+func (d *Device) SetInputRegisters(addr uint16, values ...uint16) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, value := range values {
+		d.inputRegisters[addr+uint16(i)] = value
+	}
+}
+
+// Coil returns the value of the coil, eg: to check a write of the master.
+func (d *Device) Coil(addr uint16) (value bool, ok bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	value, ok = d.coils[addr]
+	return
+}
+
+// HoldingRegister returns the value of the holding register.
+func (d *Device) HoldingRegister(addr uint16) (value uint16, ok bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	value, ok = d.holdingRegisters[addr]
+	return
+}
+
+// SetDelay delays each response, eg: longer than the master timeout to simulate a slow device.
+func (d *Device) SetDelay(delay time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.delay = delay
+}
+
+// SetException answers the requests of the function code with the exception, or all requests if functionCode is 0.
+func (d *Device) SetException(functionCode byte, exception mbserver.Exception) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.exceptions[functionCode] = exception
+}
+
+// ClearExceptions answers all requests normally again.
+func (d *Device) ClearExceptions() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.exceptions = make(map[byte]mbserver.Exception)
+}
+
+// DropRequests doesn't answer the next count requests, as if they were lost on the bus.
+func (d *Device) DropRequests(count int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.drops = count
+}
+
+// Requests returns the number of requests the device received, including the dropped ones.
+func (d *Device) Requests() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.requests
+}
+
+// handle answers the request PDU, returns false if the request is dropped.
+func (d *Device) handle(pdu []byte) (response []byte, ok bool) {
+	d.mutex.Lock()
+	d.requests++
+	delay := d.delay
+	drop := d.drops > 0
+	if drop {
+		d.drops--
+	}
+	var functionCode byte
+	if len(pdu) > 0 {
+		functionCode = pdu[0]
+	}
+	exception, isException := d.exceptions[functionCode]
+	if !isException {
+		exception, isException = d.exceptions[0]
+	}
+	d.mutex.Unlock()
+
+	if drop {
+		return nil, false
+	}
+	time.Sleep(delay)
+	if isException {
+		return []byte{functionCode | 0x80, byte(exception)}, true
+	}
+	return mbserver.HandlePDU(d, d.UnitId, pdu), true
+}
+
+func (d *Device) ReadCoils(_ uint8, addr, quantity uint16) ([]bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return readBits(d.coils, addr, quantity)
+}
+
+func (d *Device) ReadDiscreteInputs(_ uint8, addr, quantity uint16) ([]bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return readBits(d.discreteInputs, addr, quantity)
+}
+
+func (d *Device) ReadHoldingRegisters(_ uint8, addr, quantity uint16) ([]uint16, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return readRegisters(d.holdingRegisters, addr, quantity)
+}
+
+func (d *Device) ReadInputRegisters(_ uint8, addr, quantity uint16) ([]uint16, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return readRegisters(d.inputRegisters, addr, quantity)
+}
+
+func (d *Device) WriteCoils(_ uint8, addr uint16, values []bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i := range values {
+		if _, ok := d.coils[addr+uint16(i)]; !ok {
+			return mbserver.IllegalDataAddress
+		}
+	}
+	for i, value := range values {
+		d.coils[addr+uint16(i)] = value
+	}
+	return nil
+}
+
+func (d *Device) WriteHoldingRegisters(_ uint8, addr uint16, values []uint16) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i := range values {
+		if _, ok := d.holdingRegisters[addr+uint16(i)]; !ok {
+			return mbserver.IllegalDataAddress
+		}
+	}
+	for i, value := range values {
+		d.holdingRegisters[addr+uint16(i)] = value
+	}
+	return nil
+}
+
+func readBits(bits map[uint16]bool, addr, quantity uint16) ([]bool, error) {
+	values := make([]bool, quantity)
+	for i := range values {
+		value, ok := bits[addr+uint16(i)]
+		if !ok {
+			return nil, mbserver.IllegalDataAddress
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func readRegisters(registers map[uint16]uint16, addr, quantity uint16) ([]uint16, error) {
+	values := make([]uint16, quantity)
+	for i := range values {
+		value, ok := registers[addr+uint16(i)]
+		if !ok {
+			return nil, mbserver.IllegalDataAddress
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,439 @@
+package simulator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NubeIO/lib-module-go/nmodule"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/dto"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/model"
+)
+
+// Marshaller is an in memory Rubix OS for the module, with the networks, devices and points it polls. It implements
+// the methods of nmodule.Marshaller that the module uses, calling any other method panics.
+type Marshaller struct {
+	nmodule.Marshaller
+
+	mutex    sync.Mutex
+	plugin   *model.Plugin
+	networks map[string]*model.Network
+	devices  map[string]*model.Device
+	points   map[string]*model.Point
+	nextUUID int
+}
+
+// NewMarshaller returns a marshaller with the plugin of the module, and no networks.
+func NewMarshaller(pluginName string) *Marshaller {
+	return &Marshaller{
+		plugin:   &model.Plugin{UUID: "plg_simulator", Name: pluginName},
+		networks: make(map[string]*model.Network),
+		devices:  make(map[string]*model.Device),
+		points:   make(map[string]*model.Point),
+	}
+}
+
+func (ms *Marshaller) newUUID(prefix string) string {
+	ms.nextUUID++
+	return fmt.Sprintf("%s_%d", prefix, ms.nextUUID)
+}
+
+func withArgs(opts []*nmodule.Opts, has func(*nmodule.Opts) bool) bool {
+	for _, opt := range opts {
+		if opt != nil && opt.Args != nil && has(opt) {
+			return true
+		}
+	}
+	return false
+}
+
+func withDevices(opt *nmodule.Opts) bool { return opt.Args.WithDevices }
+func withPoints(opt *nmodule.Opts) bool  { return opt.Args.WithPoints }
+
+// copies are returned, so the module only changes the stored objects through the marshaller, like with Rubix OS
+
+func copyNetwork(network *model.Network) *model.Network {
+	networkCopy := *network
+	networkCopy.Devices = nil
+	return &networkCopy
+}
+
+func copyDevice(device *model.Device) *model.Device {
+	deviceCopy := *device
+	deviceCopy.Points = nil
+	deviceCopy.MetaTags = append([]*model.DeviceMetaTag(nil), device.MetaTags...)
+	return &deviceCopy
+}
+
+func copyPoint(point *model.Point) *model.Point {
+	pointCopy := *point
+	pointCopy.MetaTags = append([]*model.PointMetaTag(nil), point.MetaTags...)
+	if point.Priority != nil {
+		priority := *point.Priority
+		pointCopy.Priority = &priority
+	}
+	return &pointCopy
+}
+
+func (ms *Marshaller) GetPlugin(uuid string, _ ...*nmodule.Opts) (*model.Plugin, error) {
+	if uuid != ms.plugin.UUID {
+		return nil, fmt.Errorf("plugin %s not found", uuid)
+	}
+	plugin := *ms.plugin
+	return &plugin, nil
+}
+
+func (ms *Marshaller) GetPluginByName(name string, _ ...*nmodule.Opts) (*model.Plugin, error) {
+	if name != ms.plugin.Name {
+		return nil, fmt.Errorf("plugin %s not found", name)
+	}
+	plugin := *ms.plugin
+	return &plugin, nil
+}
+
+func (ms *Marshaller) GetNetworksByPlugin(pluginUUID string, opts ...*nmodule.Opts) ([]*model.Network, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	networks := make([]*model.Network, 0)
+	for _, network := range ms.networks {
+		if network.PluginUUID == pluginUUID {
+			networks = append(networks, ms.network(network, opts))
+		}
+	}
+	return networks, nil
+}
+
+func (ms *Marshaller) GetNetwork(uuid string, opts ...*nmodule.Opts) (*model.Network, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	network, ok := ms.networks[uuid]
+	if !ok {
+		return nil, fmt.Errorf("network %s not found", uuid)
+	}
+	return ms.network(network, opts), nil
+}
+
+func (ms *Marshaller) network(network *model.Network, opts []*nmodule.Opts) *model.Network {
+	networkCopy := copyNetwork(network)
+	if withArgs(opts, withDevices) {
+		networkCopy.Devices = make([]*model.Device, 0)
+		for _, device := range ms.devices {
+			if device.NetworkUUID == network.UUID {
+				networkCopy.Devices = append(networkCopy.Devices, ms.device(device, opts))
+			}
+		}
+	}
+	return networkCopy
+}
+
+func (ms *Marshaller) GetDevice(uuid string, opts ...*nmodule.Opts) (*model.Device, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	device, ok := ms.devices[uuid]
+	if !ok {
+		return nil, fmt.Errorf("device %s not found", uuid)
+	}
+	return ms.device(device, opts), nil
+}
+
+func (ms *Marshaller) device(device *model.Device, opts []*nmodule.Opts) *model.Device {
+	deviceCopy := copyDevice(device)
+	if withArgs(opts, withPoints) {
+		deviceCopy.Points = make([]*model.Point, 0)
+		for _, point := range ms.points {
+			if point.DeviceUUID == device.UUID {
+				deviceCopy.Points = append(deviceCopy.Points, copyPoint(point))
+			}
+		}
+	}
+	return deviceCopy
+}
+
+func (ms *Marshaller) GetPoint(uuid string, _ ...*nmodule.Opts) (*model.Point, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	point, ok := ms.points[uuid]
+	if !ok {
+		return nil, fmt.Errorf("point %s not found", uuid)
+	}
+	return copyPoint(point), nil
+}
+
+func (ms *Marshaller) CreateNetwork(body *model.Network) (*model.Network, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	network := copyNetwork(body)
+	network.UUID = ms.newUUID("net")
+	if network.PluginUUID == "" {
+		network.PluginUUID = ms.plugin.UUID
+	}
+	ms.networks[network.UUID] = network
+	return copyNetwork(network), nil
+}
+
+func (ms *Marshaller) CreateDevice(body *model.Device) (*model.Device, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if _, ok := ms.networks[body.NetworkUUID]; !ok {
+		return nil, fmt.Errorf("network %s not found", body.NetworkUUID)
+	}
+	device := copyDevice(body)
+	device.UUID = ms.newUUID("dev")
+	ms.devices[device.UUID] = device
+	return copyDevice(device), nil
+}
+
+func (ms *Marshaller) CreatePoint(body *model.Point) (*model.Point, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if _, ok := ms.devices[body.DeviceUUID]; !ok {
+		return nil, fmt.Errorf("device %s not found", body.DeviceUUID)
+	}
+	point := copyPoint(body)
+	point.UUID = ms.newUUID("pnt")
+	if point.Priority == nil {
+		point.Priority = &model.Priority{}
+	}
+	ms.points[point.UUID] = point
+	return copyPoint(point), nil
+}
+
+func (ms *Marshaller) UpdateNetwork(uuid string, body *model.Network) (*model.Network, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if _, ok := ms.networks[uuid]; !ok {
+		return nil, fmt.Errorf("network %s not found", uuid)
+	}
+	network := copyNetwork(body)
+	network.UUID = uuid
+	ms.networks[uuid] = network
+	return copyNetwork(network), nil
+}
+
+func (ms *Marshaller) UpdateDevice(uuid string, body *model.Device) (*model.Device, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	existing, ok := ms.devices[uuid]
+	if !ok {
+		return nil, fmt.Errorf("device %s not found", uuid)
+	}
+	device := copyDevice(body)
+	device.UUID = uuid
+	if body.MetaTags == nil {
+		device.MetaTags = existing.MetaTags
+	}
+	ms.devices[uuid] = device
+	return copyDevice(device), nil
+}
+
+func (ms *Marshaller) UpdatePoint(uuid string, body *model.Point) (*model.Point, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	existing, ok := ms.points[uuid]
+	if !ok {
+		return nil, fmt.Errorf("point %s not found", uuid)
+	}
+	point := copyPoint(body)
+	point.UUID = uuid
+	if body.Priority == nil {
+		point.Priority = existing.Priority
+	}
+	if body.MetaTags == nil {
+		point.MetaTags = existing.MetaTags
+	}
+	ms.points[uuid] = point
+	return copyPoint(point), nil
+}
+
+func (ms *Marshaller) UpdateNetworkErrors(uuid string, body *model.Network) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	network, ok := ms.networks[uuid]
+	if !ok {
+		return fmt.Errorf("network %s not found", uuid)
+	}
+	network.CommonFault = body.CommonFault
+	return nil
+}
+
+func (ms *Marshaller) UpdateDeviceErrors(uuid string, body *model.Device) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	device, ok := ms.devices[uuid]
+	if !ok {
+		return fmt.Errorf("device %s not found", uuid)
+	}
+	device.CommonFault = body.CommonFault
+	return nil
+}
+
+func (ms *Marshaller) UpdatePointErrors(uuid string, body *model.Point) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	point, ok := ms.points[uuid]
+	if !ok {
+		return fmt.Errorf("point %s not found", uuid)
+	}
+	point.CommonFault = body.CommonFault
+	return nil
+}
+
+func (ms *Marshaller) UpdateNetworkDescendantsErrors(uuid, message, messageLevel, messageCode string, withPoints bool) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	for _, device := range ms.devices {
+		if device.NetworkUUID == uuid {
+			setFault(&device.CommonFault, message, messageLevel, messageCode)
+			if withPoints {
+				ms.setPointFaults(device.UUID, message, messageLevel, messageCode)
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *Marshaller) UpdateDeviceDescendantsErrors(uuid, message, messageLevel, messageCode string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.setPointFaults(uuid, message, messageLevel, messageCode)
+	return nil
+}
+
+func (ms *Marshaller) ClearNetworkDescendantsErrors(uuid string, withPoints bool) error {
+	return ms.UpdateNetworkDescendantsErrors(uuid, "", dto.MessageLevel.Info, dto.CommonFaultCode.Ok, withPoints)
+}
+
+func (ms *Marshaller) ClearDeviceDescendantsErrors(uuid string) error {
+	return ms.UpdateDeviceDescendantsErrors(uuid, "", dto.MessageLevel.Info, dto.CommonFaultCode.Ok)
+}
+
+func (ms *Marshaller) setPointFaults(deviceUUID, message, messageLevel, messageCode string) {
+	for _, point := range ms.points {
+		if point.DeviceUUID == deviceUUID {
+			setFault(&point.CommonFault, message, messageLevel, messageCode)
+		}
+	}
+}
+
+func setFault(fault *model.CommonFault, message, messageLevel, messageCode string) {
+	fault.InFault = messageCode != dto.CommonFaultCode.Ok
+	fault.Message = message
+	fault.MessageLevel = messageLevel
+	fault.MessageCode = messageCode
+}
+
+func (ms *Marshaller) DeleteNetwork(uuid string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.networks, uuid)
+	for deviceUUID, device := range ms.devices {
+		if device.NetworkUUID == uuid {
+			ms.deleteDevice(deviceUUID)
+		}
+	}
+	return nil
+}
+
+func (ms *Marshaller) DeleteDevice(uuid string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.deleteDevice(uuid)
+	return nil
+}
+
+func (ms *Marshaller) deleteDevice(uuid string) {
+	delete(ms.devices, uuid)
+	for pointUUID, point := range ms.points {
+		if point.DeviceUUID == uuid {
+			delete(ms.points, pointUUID)
+		}
+	}
+}
+
+func (ms *Marshaller) DeletePoint(uuid string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.points, uuid)
+	return nil
+}
+
+// PointWrite sets the priority array of the point, and its value, like Rubix OS does without scaling or decimals. The
+// highest priority becomes the write value, and the original value becomes the present value.
+func (ms *Marshaller) PointWrite(uuid string, pointWriter *dto.PointWriter) (*dto.PointWriteResponse, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	point, ok := ms.points[uuid]
+	if !ok {
+		return nil, fmt.Errorf("point %s not found", uuid)
+	}
+	response := &dto.PointWriteResponse{}
+	if pointWriter.Priority != nil {
+		for key, value := range *pointWriter.Priority {
+			slot := prioritySlot(point.Priority, key)
+			if slot == nil {
+				return nil, fmt.Errorf("invalid priority %s", key)
+			}
+			*slot = copyValue(value)
+		}
+		writeValue, currentPriority := highestPriority(point.Priority)
+		response.IsWriteValueChange = !equalValues(point.WriteValue, writeValue)
+		point.WriteValue = writeValue
+		point.CurrentPriority = currentPriority
+		if !pointWriter.IgnorePresentValueUpdate && writeValue != nil {
+			point.PresentValue = copyValue(writeValue)
+		}
+	}
+	if pointWriter.OriginalValue != nil {
+		point.OriginalValue = copyValue(pointWriter.OriginalValue)
+		point.PresentValue = copyValue(pointWriter.OriginalValue)
+	}
+	point.CommonFault.InFault = pointWriter.Fault
+	point.CommonFault.Message = pointWriter.Message
+	response.Point = *copyPoint(point)
+	return response, nil
+}
+
+// prioritySlot returns the slot of the priority array for a key like "_16".
+func prioritySlot(priority *model.Priority, key string) **float64 {
+	slot, err := strconv.Atoi(strings.TrimPrefix(key, "_"))
+	if err != nil {
+		return nil
+	}
+	slots := prioritySlots(priority)
+	if slot < 1 || slot > len(slots) {
+		return nil
+	}
+	return slots[slot-1]
+}
+
+func prioritySlots(priority *model.Priority) []**float64 {
+	return []**float64{&priority.P1, &priority.P2, &priority.P3, &priority.P4, &priority.P5, &priority.P6, &priority.P7,
+		&priority.P8, &priority.P9, &priority.P10, &priority.P11, &priority.P12, &priority.P13, &priority.P14,
+		&priority.P15, &priority.P16}
+}
+
+func highestPriority(priority *model.Priority) (*float64, *int) {
+	for i, slot := range prioritySlots(priority) {
+		if *slot != nil {
+			current := i + 1
+			return copyValue(*slot), &current
+		}
+	}
+	return nil, nil
+}
+
+func copyValue(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	valueCopy := *value
+	return &valueCopy
+}
+
+func equalValues(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
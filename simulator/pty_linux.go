@@ -0,0 +1,69 @@
+//go:build linux
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY opens a pseudo terminal in raw mode, and returns its master and slave sides and the path of the slave side.
+func openPTY() (master *os.File, slave *os.File, path string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var unlock int32
+	if err = ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		_ = master.Close()
+		return nil, nil, "", err
+	}
+	var number uint32
+	if err = ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		_ = master.Close()
+		return nil, nil, "", err
+	}
+	path = fmt.Sprintf("/dev/pts/%d", number)
+	slave, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, nil, "", err
+	}
+	// raw mode, so the frames aren't changed by the line discipline, eg: a 0x0D byte to 0x0A
+	var termios syscall.Termios
+	if err = ioctl(slave, syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err == nil {
+		termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+		termios.Oflag &^= syscall.OPOST
+		termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+		termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+		termios.Cflag |= syscall.CS8
+		err = ioctl(slave, syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+	}
+	if err != nil {
+		_ = slave.Close()
+		_ = master.Close()
+		return nil, nil, "", err
+	}
+	return master, slave, path, nil
+}
+
+// ioctl runs the request on the file. The file isn't set to blocking mode like with its Fd, so a read of the master side
+// still returns when it is closed.
+func ioctl(file *os.File, request, arg uintptr) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
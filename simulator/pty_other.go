@@ -0,0 +1,12 @@
+//go:build !linux
+
+package simulator
+
+import (
+	"errors"
+	"os"
+)
+
+func openPTY() (master *os.File, slave *os.File, path string, err error) {
+	return nil, nil, "", errors.New("simulator: rtu ports are only supported on linux")
+}
@@ -0,0 +1,127 @@
+package simulator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+const rtuMaxFrameLength = 256
+
+// ListenRTU opens a pseudo terminal that answers Modbus RTU requests, and returns the path of its serial port, eg:
+// /dev/pts/3, for the serial port of a network. Requests to a missing unit id, with a bad crc or that are dropped get
+// no response, like on a real bus.
+func (s *Simulator) ListenRTU() (serialPort string, err error) {
+	master, slave, serialPort, err := openPTY()
+	if err != nil {
+		return "", err
+	}
+	port := &rtuPort{master: master, slave: slave}
+	if err = s.track(port); err != nil {
+		return "", err
+	}
+	s.wg.Add(1)
+	go s.serveRTU(port)
+	return serialPort, nil
+}
+
+// rtuPort is the master side of the pseudo terminal. The slave side is kept open, so reads don't fail while the
+// serial port of the network is closed.
+type rtuPort struct {
+	master *os.File
+	slave  *os.File
+}
+
+func (p *rtuPort) Close() error {
+	_ = p.slave.Close()
+	return p.master.Close()
+}
+
+func (s *Simulator) serveRTU(port *rtuPort) {
+	defer func() {
+		s.untrack(port)
+		_ = port.Close()
+		s.wg.Done()
+	}()
+
+	reader := bufio.NewReaderSize(port.master, rtuMaxFrameLength)
+	for {
+		frame, err := readRTURequest(reader)
+		if err != nil {
+			return
+		}
+		if frame == nil || crc16(frame[:len(frame)-2]) != binary.LittleEndian.Uint16(frame[len(frame)-2:]) {
+			// unknown length or corrupted frame, so wait for the bus to go quiet and drop whatever was received
+			time.Sleep(20 * time.Millisecond)
+			_, _ = reader.Discard(reader.Buffered())
+			continue
+		}
+		unitId := frame[0]
+		response, ok := s.handle(unitId, frame[1:len(frame)-2])
+		if !ok {
+			continue
+		}
+		adu := append([]byte{unitId}, response...)
+		crc := crc16(adu)
+		adu = append(adu, byte(crc), byte(crc>>8))
+		if _, err = port.master.Write(adu); err != nil {
+			return
+		}
+	}
+}
+
+// readRTURequest reads a request frame, from its unit id to its crc. The length of the frame comes from its function
+// code, as RTU frames have no length. Returns a nil frame for function codes of unknown length.
+func readRTURequest(reader *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 2, rtuMaxFrameLength)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	var header int // bytes after the function code, up to the byte count of the request data
+	switch frame[1] {
+	case 0x01, 0x02, 0x03, 0x04, 0x05, 0x06:
+		header = 4
+	case 0x0F, 0x10:
+		header = 5
+	case 0x16:
+		header = 6
+	case 0x17:
+		header = 9
+	default:
+		return nil, nil
+	}
+	frame = frame[:2+header]
+	if _, err := io.ReadFull(reader, frame[2:]); err != nil {
+		return nil, err
+	}
+	data := 0
+	if frame[1] == 0x0F || frame[1] == 0x10 || frame[1] == 0x17 {
+		data = int(frame[len(frame)-1])
+	}
+	if len(frame)+data+2 > rtuMaxFrameLength {
+		return nil, nil
+	}
+	frame = frame[:len(frame)+data+2]
+	if _, err := io.ReadFull(reader, frame[2+header:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// crc16 is the Modbus RTU crc of the frame, sent low byte first.
+func crc16(frame []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range frame {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&0x0001 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
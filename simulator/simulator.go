@@ -0,0 +1,86 @@
+// Package simulator runs simulated Modbus devices and a simulated Rubix OS in process, so the polling of the module can
+// be run end to end with go test, without hardware or a live Rubix OS.
+package simulator
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Simulator answers the requests of the devices on its Modbus TCP listeners and RTU ports.
+type Simulator struct {
+	mutex   sync.Mutex
+	devices map[uint8]*Device
+	closers map[io.Closer]bool // open listeners, connections and ports
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func New() *Simulator {
+	return &Simulator{devices: make(map[uint8]*Device), closers: make(map[io.Closer]bool)}
+}
+
+// AddDevice adds a device with the unit id, or returns the existing one.
+func (s *Simulator) AddDevice(unitId uint8) *Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if device, ok := s.devices[unitId]; ok {
+		return device
+	}
+	device := NewDevice(unitId)
+	s.devices[unitId] = device
+	return device
+}
+
+// Device returns the device with the unit id, or nil.
+func (s *Simulator) Device(unitId uint8) *Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.devices[unitId]
+}
+
+// handle answers the request PDU of the unit id. Returns false if there is no answer, eg: there is no device with
+// the unit id on the bus.
+func (s *Simulator) handle(unitId uint8, pdu []byte) ([]byte, bool) {
+	device := s.Device(unitId)
+	if device == nil {
+		return nil, false
+	}
+	return device.handle(pdu)
+}
+
+// track keeps the listener, connection or port, to close it with the simulator.
+func (s *Simulator) track(closer io.Closer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		_ = closer.Close()
+		return errors.New("simulator: closed")
+	}
+	s.closers[closer] = true
+	return nil
+}
+
+func (s *Simulator) untrack(closer io.Closer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.closers, closer)
+}
+
+// Close stops all listeners and ports, and waits for their connections to finish.
+func (s *Simulator) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	closers := s.closers
+	s.closers = make(map[io.Closer]bool)
+	s.mutex.Unlock()
+	var err error
+	for closer := range closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,83 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/NubeIO/module-core-modbus/mbserver"
+)
+
+const mbapHeaderLength = 7
+
+// ListenTCP starts a Modbus TCP listener on address, eg: "127.0.0.1:0" for a free port. Returns the host and port it
+// listens on, for the host and port of the devices. Requests to a missing unit id get a gateway target exception,
+// like from a gateway.
+func (s *Simulator) ListenTCP(address string) (host string, port int, err error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", 0, err
+	}
+	if err = s.track(listener); err != nil {
+		return "", 0, err
+	}
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	s.wg.Add(1)
+	go s.acceptTCP(listener)
+	return tcpAddr.IP.String(), tcpAddr.Port, nil
+}
+
+func (s *Simulator) acceptTCP(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if s.track(conn) != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.serveTCP(conn)
+	}
+}
+
+func (s *Simulator) serveTCP(conn net.Conn) {
+	defer func() {
+		s.untrack(conn)
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+
+	header := make([]byte, mbapHeaderLength)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:6])
+		if binary.BigEndian.Uint16(header[2:4]) != 0 || length < 2 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		unitId := header[6]
+		response, ok := s.handle(unitId, pdu)
+		if !ok && s.Device(unitId) == nil {
+			response = []byte{pdu[0] | 0x80, byte(mbserver.GatewayTargetFailedToRespond)}
+		} else if !ok {
+			continue // dropped
+		}
+
+		adu := make([]byte, mbapHeaderLength+len(response))
+		copy(adu[0:4], header[0:4])
+		binary.BigEndian.PutUint16(adu[4:6], uint16(len(response)+1))
+		adu[6] = unitId
+		copy(adu[mbapHeaderLength:], response)
+		if _, err := conn.Write(adu); err != nil {
+			return
+		}
+	}
+}
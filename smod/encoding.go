@@ -3,10 +3,84 @@ package smod
 import (
 	"encoding/binary"
 	"fmt"
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
 	log "github.com/sirupsen/logrus"
 	"math"
+	"strings"
 )
 
+// data types of this module, in addition to datatype.DataType
+const (
+	TypeString   = "string"    // ASCII text, two characters to a register. It isn't a number, so it isn't in the PresentValue
+	TypeMod10I32 = "mod10-i32" // INT32M10: two signed registers, R1*10,000 + R2
+	TypeMod10U48 = "mod10-u48" // UINT48M10: three registers, R1*10,000^2 + R2*10,000 + R3
+	TypeMod10U64 = "mod10-u64" // UINT64M10: four registers, R1*10,000^3 + R2*10,000^2 + R3*10,000 + R4
+	TypeInt48    = "int48"     // signed 48-bit integer in three registers
+	TypeUint48   = "uint48"    // unsigned 48-bit integer in three registers
+	TypeBCD16    = "bcd16"     // packed BCD, 4 digits in one register
+	TypeBCD32    = "bcd32"     // packed BCD, 8 digits in two registers
+)
+
+// registerCounts is the number of registers of the numeric data types of this module.
+var registerCounts = map[string]uint16{
+	TypeMod10I32: 2,
+	TypeMod10U48: 3,
+	TypeMod10U64: 4,
+	TypeInt48:    3,
+	TypeUint48:   3,
+	TypeBCD16:    1,
+	TypeBCD32:    2,
+}
+
+// RegisterCount returns the number of registers of a numeric data type of this module, or 0 if it isn't one.
+func RegisterCount(dataType string) uint16 {
+	return registerCounts[dataType]
+}
+
+// valueRange is the range of values that a data type can encode, larger values would be truncated or wrap.
+type valueRange struct {
+	min float64
+	max float64
+}
+
+// valueRanges are the ranges of the integer data types, the others are in the range of uint16.
+var valueRanges = map[string]valueRange{
+	string(datatype.TypeInt16):    {math.MinInt16, math.MaxInt16},
+	string(datatype.TypeInt32):    {math.MinInt32, math.MaxInt32},
+	string(datatype.TypeUint32):   {0, math.MaxUint32},
+	string(datatype.TypeInt64):    {math.MinInt64, math.Nextafter(1<<63, 0)},
+	string(datatype.TypeUint64):   {0, math.Nextafter(1<<64, 0)},
+	string(datatype.TypeMod10U32): {0, 99999999},
+	TypeMod10I32:                  {-99999999, 99999999},
+	TypeMod10U48:                  {0, 999999999999},
+	TypeMod10U64:                  {0, math.Nextafter(1e16, 0)},
+	TypeInt48:                     {-(1 << 47), 1<<47 - 1},
+	TypeUint48:                    {0, 1<<48 - 1},
+	TypeBCD16:                     {0, 9999},
+	TypeBCD32:                     {0, 99999999},
+}
+
+// CheckValueRange checks that the value can be encoded as dataType, or returns an ErrorValueRange error. The fraction
+// of integer types is dropped, so only the integer part has to be in range.
+func CheckValueRange(dataType string, in float64) error {
+	inRange := true
+	switch dataType {
+	case string(datatype.TypeFloat64):
+	case string(datatype.TypeFloat32):
+		inRange = math.IsInf(in, 0) || math.IsNaN(in) || math.Abs(in) <= math.MaxFloat32
+	default:
+		r, ok := valueRanges[dataType]
+		if !ok {
+			r = valueRange{0, math.MaxUint16}
+		}
+		inRange = !math.IsNaN(in) && math.Trunc(in) >= r.min && math.Trunc(in) <= r.max
+	}
+	if !inRange {
+		return &RequestError{Class: ErrorValueRange, Err: fmt.Errorf("modbus: value %v is out of the range of data type %s", in, dataType)}
+	}
+	return nil
+}
+
 func uint16ToBytes(endianness Endianness, in uint16) (out []byte) {
 	out = make([]byte, 2)
 	switch endianness {
@@ -271,6 +345,170 @@ func bytesToMod10_u32(endianness Endianness, wordOrder WordOrder, in []byte) (ou
 	return
 }
 
+func mod10_u32ToBytes(endianness Endianness, wordOrder WordOrder, in float64) (out []byte) {
+	high := uint16(uint32(in) / 10000)
+	low := uint16(uint32(in) % 10000)
+	if wordOrder == HighWordFirst {
+		out = append(uint16ToBytes(endianness, high), uint16ToBytes(endianness, low)...)
+	} else {
+		out = append(uint16ToBytes(endianness, low), uint16ToBytes(endianness, high)...)
+	}
+	return
+}
+
+// bytesToRegisters decodes count registers, ordered from the most significant one. With LowWordFirst the least
+// significant register comes first.
+func bytesToRegisters(endianness Endianness, wordOrder WordOrder, in []byte, count int) (out []uint16, ok bool) {
+	if len(in) < count*2 {
+		log.Errorf("MODBUS CONVERSION ERROR bytesToRegisters(): length of input []byte is less than %d", count*2)
+		return nil, false
+	}
+	out = make([]uint16, count)
+	for i := 0; i < count; i++ {
+		out[i] = bytesToUint16(endianness, in[i*2:i*2+2])
+	}
+	if wordOrder == LowWordFirst {
+		for i, j := 0, count-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out, true
+}
+
+// registersToBytes encodes the registers, which are ordered from the most significant one.
+func registersToBytes(endianness Endianness, wordOrder WordOrder, in []uint16) (out []byte) {
+	for i := range in {
+		register := in[i]
+		if wordOrder == LowWordFirst {
+			register = in[len(in)-1-i]
+		}
+		out = append(out, uint16ToBytes(endianness, register)...)
+	}
+	return
+}
+
+// bytesToMod10 decodes count registers that each hold 4 decimal digits (0 to 9999). Signed registers are int16s, which
+// all have the sign of the value.
+func bytesToMod10(endianness Endianness, wordOrder WordOrder, in []byte, count int, signed bool) (out float64) {
+	registers, ok := bytesToRegisters(endianness, wordOrder, in, count)
+	if !ok {
+		return 0
+	}
+	for _, register := range registers {
+		if signed {
+			out = out*10000 + float64(int16(register))
+		} else {
+			out = out*10000 + float64(register)
+		}
+	}
+	return
+}
+
+func mod10ToBytes(endianness Endianness, wordOrder WordOrder, in float64, count int, signed bool) (out []byte) {
+	registers := make([]uint16, count)
+	if signed {
+		value := int64(in)
+		for i := count - 1; i >= 0; i-- {
+			registers[i] = uint16(int16(value % 10000))
+			value /= 10000
+		}
+	} else {
+		value := uint64(in)
+		for i := count - 1; i >= 0; i-- {
+			registers[i] = uint16(value % 10000)
+			value /= 10000
+		}
+	}
+	return registersToBytes(endianness, wordOrder, registers)
+}
+
+func bytesToUint48(endianness Endianness, wordOrder WordOrder, in []byte) (out uint64) {
+	registers, ok := bytesToRegisters(endianness, wordOrder, in, 3)
+	if !ok {
+		return 0
+	}
+	return uint64(registers[0])<<32 | uint64(registers[1])<<16 | uint64(registers[2])
+}
+
+func bytesToInt48(endianness Endianness, wordOrder WordOrder, in []byte) (out int64) {
+	u48 := bytesToUint48(endianness, wordOrder, in)
+	if u48&(1<<47) != 0 { // extend the sign bit
+		u48 |= 0xffff << 48
+	}
+	return int64(u48)
+}
+
+func uint48ToBytes(endianness Endianness, wordOrder WordOrder, in uint64) (out []byte) {
+	return registersToBytes(endianness, wordOrder, []uint16{uint16(in >> 32), uint16(in >> 16), uint16(in)})
+}
+
+// bytesToBCD decodes count registers of packed BCD, 4 bits to a decimal digit.
+func bytesToBCD(endianness Endianness, wordOrder WordOrder, in []byte, count int) (out float64) {
+	registers, ok := bytesToRegisters(endianness, wordOrder, in, count)
+	if !ok {
+		return 0
+	}
+	for _, register := range registers {
+		for shift := 12; shift >= 0; shift -= 4 {
+			digit := (register >> uint(shift)) & 0x0f
+			if digit > 9 {
+				log.Errorf("MODBUS CONVERSION ERROR bytesToBCD(): invalid BCD digit %X", digit)
+				return 0
+			}
+			out = out*10 + float64(digit)
+		}
+	}
+	return
+}
+
+func bcdToBytes(endianness Endianness, wordOrder WordOrder, in float64, count int) (out []byte) {
+	registers := make([]uint16, count)
+	value := uint64(0)
+	if in > 0 {
+		value = uint64(in)
+	}
+	for i := count - 1; i >= 0; i-- {
+		for shift := 0; shift < 16; shift += 4 {
+			registers[i] |= uint16(value%10) << uint(shift)
+			value /= 10
+		}
+	}
+	return registersToBytes(endianness, wordOrder, registers)
+}
+
+// bytesToString decodes the characters of the registers, up to the first null. Only the endianness applies, as the
+// registers of a string are always in address order.
+func bytesToString(endianness Endianness, in []byte) (out string) {
+	chars := make([]byte, 0, len(in))
+	for i := 0; i+1 < len(in); i += 2 {
+		if endianness == LittleEndian {
+			chars = append(chars, in[i+1], in[i])
+		} else {
+			chars = append(chars, in[i], in[i+1])
+		}
+	}
+	if end := strings.IndexByte(string(chars), 0); end >= 0 {
+		chars = chars[:end]
+	}
+	out = strings.TrimRight(string(chars), " ")
+	return
+}
+
+// stringToBytes encodes the characters into quantity registers, padded with nulls.
+func stringToBytes(endianness Endianness, in string, quantity uint16) (out []byte) {
+	chars := make([]byte, int(quantity)*2)
+	copy(chars, in)
+	out = make([]byte, len(chars))
+	for i := 0; i < len(chars); i += 2 {
+		if endianness == LittleEndian {
+			out[i], out[i+1] = chars[i+1], chars[i]
+		} else {
+			out[i], out[i+1] = chars[i], chars[i+1]
+		}
+	}
+	return
+}
+
 func encodeBools(in []bool) (out []byte) {
 	var byteCount uint
 	var i uint
@@ -296,3 +534,85 @@ func decodeBools(quantity uint16, in []byte) (out []bool) {
 	}
 	return
 }
+
+// DecodeValue Decodes a single value of dataType from its register bytes.
+func DecodeValue(endianness Endianness, wordOrder WordOrder, dataType string, in []byte) (out float64) {
+	switch dataType {
+	case string(datatype.TypeInt16):
+		out = float64(bytesToInt16s(endianness, in)[0])
+	case string(datatype.TypeInt32):
+		out = float64(bytesToInt32s(endianness, wordOrder, in)[0])
+	case string(datatype.TypeUint32):
+		out = float64(bytesToUint32s(endianness, wordOrder, in)[0])
+	case string(datatype.TypeInt64):
+		out = float64(bytesToInt64s(endianness, wordOrder, in)[0])
+	case string(datatype.TypeUint64):
+		out = float64(bytesToUint64s(endianness, wordOrder, in)[0])
+	case string(datatype.TypeMod10U32):
+		out = bytesToMod10_u32(endianness, wordOrder, in)[0]
+	case string(datatype.TypeFloat32):
+		out = float64(bytesToFloat32s(endianness, wordOrder, in)[0])
+	case string(datatype.TypeFloat64):
+		out = bytesToFloat64s(endianness, wordOrder, in)[0]
+	case TypeMod10I32:
+		out = bytesToMod10(endianness, wordOrder, in, 2, true)
+	case TypeMod10U48:
+		out = bytesToMod10(endianness, wordOrder, in, 3, false)
+	case TypeMod10U64:
+		out = bytesToMod10(endianness, wordOrder, in, 4, false)
+	case TypeInt48:
+		out = float64(bytesToInt48(endianness, wordOrder, in))
+	case TypeUint48:
+		out = float64(bytesToUint48(endianness, wordOrder, in))
+	case TypeBCD16:
+		out = bytesToBCD(endianness, wordOrder, in, 1)
+	case TypeBCD32:
+		out = bytesToBCD(endianness, wordOrder, in, 2)
+	default:
+		out = float64(bytesToUint16s(endianness, in)[0])
+	}
+	return
+}
+
+// EncodeValue Encodes a single value as the register bytes of dataType. Returns an error if the value is out of the
+// range of dataType.
+func EncodeValue(endianness Endianness, wordOrder WordOrder, dataType string, in float64) (out []byte, err error) {
+	if err = CheckValueRange(dataType, in); err != nil {
+		return nil, err
+	}
+	switch dataType {
+	case string(datatype.TypeInt16):
+		out = uint16ToBytes(endianness, uint16(int16(in)))
+	case string(datatype.TypeInt32):
+		out = uint32ToBytes(endianness, wordOrder, uint32(int32(in)))
+	case string(datatype.TypeUint32):
+		out = uint32ToBytes(endianness, wordOrder, uint32(in))
+	case string(datatype.TypeInt64):
+		out = uint64ToBytes(endianness, wordOrder, uint64(int64(in)))
+	case string(datatype.TypeUint64):
+		out = uint64ToBytes(endianness, wordOrder, uint64(in))
+	case string(datatype.TypeMod10U32):
+		out = mod10_u32ToBytes(endianness, wordOrder, in)
+	case string(datatype.TypeFloat32):
+		out = float32ToBytes(endianness, wordOrder, float32(in))
+	case string(datatype.TypeFloat64):
+		out = float64ToBytes(endianness, wordOrder, in)
+	case TypeMod10I32:
+		out = mod10ToBytes(endianness, wordOrder, in, 2, true)
+	case TypeMod10U48:
+		out = mod10ToBytes(endianness, wordOrder, in, 3, false)
+	case TypeMod10U64:
+		out = mod10ToBytes(endianness, wordOrder, in, 4, false)
+	case TypeInt48:
+		out = uint48ToBytes(endianness, wordOrder, uint64(int64(in)))
+	case TypeUint48:
+		out = uint48ToBytes(endianness, wordOrder, uint64(in))
+	case TypeBCD16:
+		out = bcdToBytes(endianness, wordOrder, in, 1)
+	case TypeBCD32:
+		out = bcdToBytes(endianness, wordOrder, in, 2)
+	default:
+		out = uint16ToBytes(endianness, uint16(in))
+	}
+	return out, nil
+}
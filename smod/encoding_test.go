@@ -0,0 +1,158 @@
+package smod
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+)
+
+// encodingExamples are values with the registers that register maps document for them, as hex of the bytes on the bus.
+var encodingExamples = []struct {
+	name       string
+	dataType   string
+	endianness Endianness
+	wordOrder  WordOrder
+	value      float64
+	bus        string
+}{
+	{"uint16", string(datatype.TypeUint16), BigEndian, HighWordFirst, 65535, "ffff"},
+	{"int16 negative", string(datatype.TypeInt16), BigEndian, HighWordFirst, -1, "ffff"},
+	{"int16 little endian", string(datatype.TypeInt16), LittleEndian, HighWordFirst, 258, "0201"},
+	{"int32 negative", string(datatype.TypeInt32), BigEndian, HighWordFirst, -2, "fffffffe"},
+	{"uint32 low word first", string(datatype.TypeUint32), BigEndian, LowWordFirst, 0x12345678, "56781234"},
+	{"int64 negative", string(datatype.TypeInt64), BigEndian, HighWordFirst, -1234567890123, "fffffee08e04fb35"},
+	{"uint64", string(datatype.TypeUint64), BigEndian, HighWordFirst, 1234567890123, "0000011f71fb04cb"},
+
+	// IEEE 754 123.456 is 0x42F6E979, which is sent in any of the four byte orders
+	{"float32 ABCD", string(datatype.TypeFloat32), BigEndian, HighWordFirst, float64(float32(123.456)), "42f6e979"},
+	{"float32 CDAB", string(datatype.TypeFloat32), BigEndian, LowWordFirst, float64(float32(123.456)), "e97942f6"},
+	{"float32 BADC", string(datatype.TypeFloat32), LittleEndian, HighWordFirst, float64(float32(123.456)), "f64279e9"},
+	{"float32 DCBA", string(datatype.TypeFloat32), LittleEndian, LowWordFirst, float64(float32(123.456)), "79e9f642"},
+	{"float64", string(datatype.TypeFloat64), BigEndian, HighWordFirst, 1, "3ff0000000000000"},
+	{"float64 low word first", string(datatype.TypeFloat64), BigEndian, LowWordFirst, -2.5, "000000000000c004"},
+
+	// power meter register maps: INT32M10, UINT48M10 and UINT64M10 hold 4 decimal digits in each register
+	{"mod10-u32", string(datatype.TypeMod10U32), BigEndian, HighWordFirst, 12345678, "04d2162e"},
+	{"mod10-u32 low word first", string(datatype.TypeMod10U32), BigEndian, LowWordFirst, 12345678, "162e04d2"},
+	{"INT32M10", TypeMod10I32, BigEndian, HighWordFirst, 12345678, "04d2162e"},
+	{"INT32M10 negative", TypeMod10I32, BigEndian, HighWordFirst, -12345678, "fb2ee9d2"},
+	{"UINT48M10", TypeMod10U48, BigEndian, HighWordFirst, 1234567890, "000c0d801ed2"},
+	{"UINT48M10 low word first", TypeMod10U48, BigEndian, LowWordFirst, 1234567890, "1ed20d80000c"},
+	{"UINT64M10", TypeMod10U64, BigEndian, HighWordFirst, 123456789012, "000004d2162e2334"},
+
+	{"int48 negative", TypeInt48, BigEndian, HighWordFirst, -2, "fffffffffffe"},
+	{"uint48", TypeUint48, BigEndian, HighWordFirst, 0x123456789abc, "123456789abc"},
+	{"uint48 low word first", TypeUint48, BigEndian, LowWordFirst, 0x123456789abc, "9abc56781234"},
+	{"uint48 little endian", TypeUint48, LittleEndian, HighWordFirst, 0x123456789abc, "34127856bc9a"},
+
+	// packed BCD, eg: the energy counters of older meters
+	{"bcd16", TypeBCD16, BigEndian, HighWordFirst, 1234, "1234"},
+	{"bcd32", TypeBCD32, BigEndian, HighWordFirst, 12345678, "12345678"},
+	{"bcd32 low word first", TypeBCD32, BigEndian, LowWordFirst, 12345678, "56781234"},
+}
+
+func TestEncodeValue(t *testing.T) {
+	for _, tt := range encodingExamples {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := EncodeValue(tt.endianness, tt.wordOrder, tt.dataType, tt.value)
+			if err != nil {
+				t.Fatalf("EncodeValue() error = %v", err)
+			}
+			if got := hex.EncodeToString(out); got != tt.bus {
+				t.Errorf("EncodeValue() = %s, want %s", got, tt.bus)
+			}
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	for _, tt := range encodingExamples {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tt.bus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DecodeValue(tt.endianness, tt.wordOrder, tt.dataType, in); got != tt.value {
+				t.Errorf("DecodeValue() = %v, want %v", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestEncodeValueOutOfRange(t *testing.T) {
+	tests := []struct {
+		dataType string
+		value    float64
+	}{
+		{string(datatype.TypeUint16), -1},
+		{string(datatype.TypeUint16), 65536},
+		{string(datatype.TypeInt16), 32768},
+		{string(datatype.TypeInt16), -32769},
+		{string(datatype.TypeInt32), math.MaxInt32 + 1},
+		{string(datatype.TypeInt32), math.NaN()},
+		{string(datatype.TypeUint32), -1},
+		{string(datatype.TypeUint32), math.MaxUint32 + 1},
+		{string(datatype.TypeInt64), 1 << 63},
+		{string(datatype.TypeUint64), -1},
+		{string(datatype.TypeUint64), 1 << 64},
+		{string(datatype.TypeFloat32), 1e39},
+		{string(datatype.TypeMod10U32), 100000000},
+		{string(datatype.TypeMod10U32), -1},
+		{TypeMod10I32, 1.25e10},
+		{TypeMod10I32, -1.25e10},
+		{TypeMod10U48, 1e12},
+		{TypeMod10U48, -1},
+		{TypeMod10U64, 1e16},
+		{TypeInt48, 1 << 47},
+		{TypeInt48, -(1 << 47) - 1},
+		{TypeUint48, -1},
+		{TypeUint48, 1 << 48},
+		{TypeBCD16, 12345},
+		{TypeBCD16, 99999999},
+		{TypeBCD16, -1},
+		{TypeBCD32, 100000000},
+		{TypeBCD32, -5},
+	}
+	for _, tt := range tests {
+		out, err := EncodeValue(BigEndian, HighWordFirst, tt.dataType, tt.value)
+		var requestErr *RequestError
+		if !errors.As(err, &requestErr) || requestErr.Class != ErrorValueRange {
+			t.Errorf("EncodeValue(%s, %v) = %x, %v, want a %s error", tt.dataType, tt.value, out, err, ErrorValueRange)
+		}
+	}
+}
+
+func TestEncodeValueRangeLimits(t *testing.T) {
+	tests := []struct {
+		dataType string
+		value    float64
+	}{
+		{string(datatype.TypeUint16), 65535},
+		{string(datatype.TypeInt16), -32768},
+		{string(datatype.TypeInt16), 32767.9}, // the fraction is dropped
+		{string(datatype.TypeInt32), math.MinInt32},
+		{string(datatype.TypeUint32), math.MaxUint32},
+		{string(datatype.TypeInt64), math.MinInt64},
+		{string(datatype.TypeFloat32), math.Inf(-1)},
+		{string(datatype.TypeFloat64), math.MaxFloat64},
+		{TypeMod10I32, -99999999},
+		{TypeMod10U48, 999999999999},
+		{TypeInt48, -(1 << 47)},
+		{TypeUint48, 1<<48 - 1},
+		{TypeBCD16, 9999},
+		{TypeBCD32, 99999999},
+	}
+	for _, tt := range tests {
+		out, err := EncodeValue(BigEndian, HighWordFirst, tt.dataType, tt.value)
+		if err != nil {
+			t.Errorf("EncodeValue(%s, %v) error = %v", tt.dataType, tt.value, err)
+			continue
+		}
+		if got, want := DecodeValue(BigEndian, HighWordFirst, tt.dataType, out), math.Trunc(tt.value); got != want {
+			t.Errorf("DecodeValue(EncodeValue(%s, %v)) = %v, want %v", tt.dataType, tt.value, got, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package smod
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/grid-x/modbus"
+)
+
+// ErrorClass is the kind of failure of a request.
+type ErrorClass string
+
+const (
+	ErrorIllegalFunction  ErrorClass = "illegal-function"  // exception 1: the device doesn't support the function code
+	ErrorIllegalAddress   ErrorClass = "illegal-address"   // exception 2: the device doesn't have the register
+	ErrorIllegalValue     ErrorClass = "illegal-value"     // exception 3: the quantity or written value isn't allowed
+	ErrorDeviceFailure    ErrorClass = "device-failure"    // exception 4 and 8: the device failed to handle the request
+	ErrorDeviceBusy       ErrorClass = "device-busy"       // exception 5 and 6: the device is busy with a long request
+	ErrorGatewayPath      ErrorClass = "gateway-path"      // exception 10: the gateway has no path to the device
+	ErrorGatewayTarget    ErrorClass = "gateway-target"    // exception 11: the device behind the gateway didn't respond
+	ErrorUnknownException ErrorClass = "unknown-exception" // any other exception code
+	ErrorTimeout          ErrorClass = "timeout"           // no response
+	ErrorCRC              ErrorClass = "crc"               // the response was corrupted on the bus
+	ErrorInvalidResponse  ErrorClass = "invalid-response"  // the response doesn't match the request, eg: another unit id
+	ErrorConnection       ErrorClass = "connection"        // the socket failed
+	ErrorPortUnavailable  ErrorClass = "port-unavailable"  // the serial port can't be opened or failed, eg: its usb adapter was reset
+	ErrorValueRange       ErrorClass = "value-range"       // the written value doesn't fit in the data type, so it wasn't sent
+	ErrorUnclassified     ErrorClass = "unclassified"      // anything else
+)
+
+// RequestError is a failed request, with its error class.
+type RequestError struct {
+	Class         ErrorClass
+	ExceptionCode byte // set for exception responses
+	Err           error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// IsException checks if the device responded with an exception response. A gateway that answers for a device that
+// didn't respond isn't a response of the device.
+func (e *RequestError) IsException() bool {
+	return e.ExceptionCode != 0 && e.Class != ErrorGatewayPath && e.Class != ErrorGatewayTarget
+}
+
+// ClassifyError returns the class of the error of a request, or nil if err is nil.
+func ClassifyError(err error) *RequestError {
+	if err == nil {
+		return nil
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr
+	}
+	requestErr = &RequestError{Class: ErrorUnclassified, Err: err}
+	var exception *modbus.Error
+	if errors.As(err, &exception) {
+		requestErr.ExceptionCode = exception.ExceptionCode
+		requestErr.Class = exceptionClass(exception.ExceptionCode)
+		return requestErr
+	}
+	var netErr net.Error
+	message := strings.ToLower(err.Error())
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(message, "timeout"), strings.Contains(message, "deadline"):
+		requestErr.Class = ErrorTimeout
+	case strings.Contains(message, "crc"):
+		requestErr.Class = ErrorCRC
+	case strings.HasPrefix(message, "modbus: response"):
+		requestErr.Class = ErrorInvalidResponse
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		requestErr.Class = ErrorConnection
+	case isPortError(err), strings.Contains(message, "could not open"):
+		requestErr.Class = ErrorPortUnavailable
+	}
+	return requestErr
+}
+
+// isPortError checks if the error is from a serial port that is gone or broken, rather than from the bus.
+func isPortError(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ENXIO) || errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.EBADF)
+}
+
+func exceptionClass(exceptionCode byte) ErrorClass {
+	switch exceptionCode {
+	case modbus.ExceptionCodeIllegalFunction:
+		return ErrorIllegalFunction
+	case modbus.ExceptionCodeIllegalDataAddress:
+		return ErrorIllegalAddress
+	case modbus.ExceptionCodeIllegalDataValue:
+		return ErrorIllegalValue
+	case modbus.ExceptionCodeServerDeviceFailure, modbus.ExceptionCodeMemoryParityError:
+		return ErrorDeviceFailure
+	case modbus.ExceptionCodeAcknowledge, modbus.ExceptionCodeServerDeviceBusy:
+		return ErrorDeviceBusy
+	case modbus.ExceptionCodeGatewayPathUnavailable:
+		return ErrorGatewayPath
+	case modbus.ExceptionCodeGatewayTargetDeviceFailedToRespond:
+		return ErrorGatewayTarget
+	}
+	return ErrorUnknownException
+}
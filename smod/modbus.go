@@ -1,6 +1,7 @@
 package smod
 
 import (
+	"fmt"
 	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
 	"github.com/grid-x/modbus"
 	log "github.com/sirupsen/logrus"
@@ -15,6 +16,8 @@ type Error string
 const (
 	HoldingRegister RegType = 0
 	InputRegister   RegType = 1
+	Coil            RegType = 2
+	DiscreteInput   RegType = 3
 
 	// BigEndian endianness of 16-bit registers
 	BigEndian    Endianness = 1
@@ -26,15 +29,17 @@ const (
 )
 
 type ModbusClient struct {
-	Client           modbus.Client
-	RTUClientHandler *modbus.RTUClientHandler
-	TCPClientHandler *modbus.TCPClientHandler
-	Endianness       Endianness
-	WordOrder        WordOrder
-	RegType          RegType
-	DeviceZeroMode   bool
-	Debug            bool
-	PortUnavailable  bool
+	Client                  modbus.Client
+	RTUClientHandler        *modbus.RTUClientHandler
+	TCPClientHandler        *modbus.TCPClientHandler
+	RTUOverTCPClientHandler *modbus.RTUOverTCPClientHandler
+	ASCIIClientHandler      *modbus.ASCIIClientHandler
+	Endianness              Endianness
+	WordOrder               WordOrder
+	RegType                 RegType
+	DeviceZeroMode          bool
+	Debug                   bool
+	PortUnavailable         bool
 }
 
 // SetEncoding Sets the encoding (endianness and word ordering) of subsequent requests.
@@ -114,6 +119,8 @@ func (mc *ModbusClient) ReadInputRegisters(addr uint16, quantity uint16, dataTyp
 		// decode payload bytes as uint16s, then do R2*10,000 + R1
 		decode := bytesToMod10_u32(mc.Endianness, mc.WordOrder, raw)
 		out = decode[0]
+	case TypeMod10I32, TypeMod10U48, TypeMod10U64, TypeInt48, TypeUint48, TypeBCD16, TypeBCD32:
+		out = DecodeValue(mc.Endianness, mc.WordOrder, dataType, raw)
 	default:
 		// Decode payload bytes as uint16s
 		decode := bytesToUint16s(mc.Endianness, raw)
@@ -173,6 +180,8 @@ func (mc *ModbusClient) ReadHoldingRegisters(addr uint16, quantity uint16, dataT
 		// decode payload bytes as uint16s, then do R2*10,000 + R1
 		decode := bytesToMod10_u32(mc.Endianness, mc.WordOrder, raw)
 		out = decode[0]
+	case TypeMod10I32, TypeMod10U48, TypeMod10U64, TypeInt48, TypeUint48, TypeBCD16, TypeBCD32:
+		out = DecodeValue(mc.Endianness, mc.WordOrder, dataType, raw)
 	default:
 		// Decode payload bytes as uint16s
 		decode := bytesToUint16s(mc.Endianness, raw)
@@ -183,6 +192,48 @@ func (mc *ModbusClient) ReadHoldingRegisters(addr uint16, quantity uint16, dataT
 	return
 }
 
+// ReadBlock Reads a block of raw register or bit values (function code 01, 02, 03 or 04 depending on regType).
+func (mc *ModbusClient) ReadBlock(addr uint16, quantity uint16, regType RegType) (raw []byte, err error) {
+	switch regType {
+	case Coil:
+		raw, err = mc.Client.ReadCoils(addr, quantity)
+	case DiscreteInput:
+		raw, err = mc.Client.ReadDiscreteInputs(addr, quantity)
+	case InputRegister:
+		raw, err = mc.Client.ReadInputRegisters(addr, quantity)
+	default:
+		raw, err = mc.Client.ReadHoldingRegisters(addr, quantity)
+	}
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to ReadBlock  addr:%d  quantity:%d error: %v]\n", addr, quantity, err)
+	}
+	return
+}
+
+// DecodeBlockBit Decodes the bit at offset from the raw response of a coil or discrete input block read.
+func (mc *ModbusClient) DecodeBlockBit(raw []byte, offset uint16) (out float64, err error) {
+	if int(offset/8) >= len(raw) {
+		err = fmt.Errorf("modbus: bit offset %d is outside of the %d byte block response", offset, len(raw))
+		return
+	}
+	if decodeBools(offset+1, raw)[offset] {
+		out = 1
+	}
+	return
+}
+
+// DecodeBlockRegisters Decodes a value of dataType from the registers at offset of the raw response of a register block read.
+func (mc *ModbusClient) DecodeBlockRegisters(raw []byte, offset uint16, quantity uint16, dataType string) (out float64, err error) {
+	start := int(offset) * 2
+	end := start + int(quantity)*2
+	if end > len(raw) {
+		err = fmt.Errorf("modbus: registers %d to %d are outside of the %d register block response", offset, int(offset)+int(quantity)-1, len(raw)/2)
+		return
+	}
+	out = DecodeValue(mc.Endianness, mc.WordOrder, dataType, raw[start:end])
+	return
+}
+
 // ReadFloat32s Reads multiple 32-bit float registers.
 func (mc *ModbusClient) ReadFloat32s(addr uint16, quantity uint16, regType RegType) (raw []float32, err error) {
 	var mbPayload []byte
@@ -217,19 +268,19 @@ func (mc *ModbusClient) ReadFloat32(addr uint16, regType RegType) (raw []float32
 // ReadFloat64s Reads multiple 64-bit float registers.
 func (mc *ModbusClient) ReadFloat64s(addr uint16, quantity uint16, regType RegType) (raw []float64, err error) {
 	var mbPayload []byte
-	// Read 2 * quantity uint16 registers, as bytes
+	// Read 4 * quantity uint16 registers, as bytes
 	if regType == HoldingRegister {
-		mbPayload, err = mc.Client.ReadHoldingRegisters(addr, quantity*2)
+		mbPayload, err = mc.Client.ReadHoldingRegisters(addr, quantity*4)
 		if err != nil {
 			return
 		}
 	} else {
-		mbPayload, err = mc.Client.ReadInputRegisters(addr, quantity*2)
+		mbPayload, err = mc.Client.ReadInputRegisters(addr, quantity*4)
 		if err != nil {
 			return
 		}
 	}
-	// Decode payload bytes as float32s
+	// Decode payload bytes as float64s
 	raw = bytesToFloat64s(mc.Endianness, mc.WordOrder, mbPayload)
 	return
 }
@@ -256,6 +307,132 @@ func (mc *ModbusClient) WriteFloat32(addr uint16, value float64) (raw []byte, ou
 	return
 }
 
+// WriteFloat64 Writes a single 64-bit float register (function code 16, 4 registers).
+func (mc *ModbusClient) WriteFloat64(addr uint16, value float64) (raw []byte, out float64, err error) {
+	raw, err = mc.Client.WriteMultipleRegisters(addr, 4, float64ToBytes(mc.Endianness, mc.WordOrder, value))
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteFloat64: %v]", err)
+		return
+	}
+	out = value
+	return
+}
+
+// ReadString Reads an ASCII string of quantity registers.
+func (mc *ModbusClient) ReadString(addr uint16, quantity uint16, regType RegType) (raw []byte, out string, err error) {
+	if regType == HoldingRegister {
+		raw, err = mc.Client.ReadHoldingRegisters(addr, quantity)
+	} else {
+		raw, err = mc.Client.ReadInputRegisters(addr, quantity)
+	}
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to ReadString  addr:%d  quantity:%d error: %v]\n", addr, quantity, err)
+		return
+	}
+	out = bytesToString(mc.Endianness, raw)
+	return
+}
+
+// WriteString Writes an ASCII string to quantity registers (function code 16), padded with nulls.
+func (mc *ModbusClient) WriteString(addr uint16, quantity uint16, value string) (raw []byte, err error) {
+	if len(value) > int(quantity)*2 {
+		err = fmt.Errorf("modbus: string of %d characters doesn't fit in %d registers", len(value), quantity)
+		return
+	}
+	raw, err = mc.Client.WriteMultipleRegisters(addr, quantity, stringToBytes(mc.Endianness, value, quantity))
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteString: %v]", err)
+	}
+	return
+}
+
+// WriteValue Writes a value of a data type that spans one or more registers (function code 16).
+func (mc *ModbusClient) WriteValue(addr uint16, dataType string, value float64) (raw []byte, out float64, err error) {
+	payload, err := EncodeValue(mc.Endianness, mc.WordOrder, dataType, value)
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteValue %s: %v]", dataType, err)
+		return
+	}
+	raw, err = mc.Client.WriteMultipleRegisters(addr, uint16(len(payload)/2), payload)
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteValue %s: %v]", dataType, err)
+		return
+	}
+	out = DecodeValue(mc.Endianness, mc.WordOrder, dataType, payload)
+	return
+}
+
+// MaskWriteRegister Sets the bits of a holding register that are cleared in andMask to the bits of orMask, and keeps the
+// other bits, in one request (function code 22).
+func (mc *ModbusClient) MaskWriteRegister(addr uint16, andMask uint16, orMask uint16) (raw []byte, err error) {
+	raw, err = mc.Client.MaskWriteRegister(addr, andMask, orMask)
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to MaskWriteRegister: %v]", err)
+	}
+	return
+}
+
+// ReadWriteMultipleRegisters Writes value to the holding registers at writeAddr, then reads readQuantity registers at
+// readAddr, in one request (function code 23).
+func (mc *ModbusClient) ReadWriteMultipleRegisters(readAddr uint16, readQuantity uint16, writeAddr uint16, value []byte) (raw []byte, err error) {
+	raw, err = mc.Client.ReadWriteMultipleRegisters(readAddr, readQuantity, writeAddr, uint16(len(value)/2), value)
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to ReadWriteMultipleRegisters  readAddr:%d  writeAddr:%d error: %v]", readAddr, writeAddr, err)
+	}
+	return
+}
+
+// WriteReadRegister Writes one holding register and reads it back in the same request (function code 23), out is the
+// read back value decoded as dataType.
+func (mc *ModbusClient) WriteReadRegister(addr uint16, value uint16, dataType string) (raw []byte, out float64, err error) {
+	raw, err = mc.ReadWriteMultipleRegisters(addr, 1, addr, uint16ToBytes(BigEndian, value))
+	if err != nil {
+		return
+	}
+	if len(raw) != 2 {
+		err = fmt.Errorf("modbus: read back %d bytes of register %d, expected 2", len(raw), addr)
+		return
+	}
+	out = DecodeValue(mc.Endianness, mc.WordOrder, dataType, raw)
+	return
+}
+
+// WriteCoils Writes consecutive coils from addr in one request (function code 15).
+func (mc *ModbusClient) WriteCoils(addr uint16, values []bool) (raw []byte, err error) {
+	raw, err = mc.Client.WriteMultipleCoils(addr, uint16(len(values)), encodeBools(values))
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteCoils  addr:%d  quantity:%d error: %v]", addr, len(values), err)
+	}
+	return
+}
+
+// WriteRegisters Writes the register bytes to consecutive holding registers from addr in one request (function code 16).
+func (mc *ModbusClient) WriteRegisters(addr uint16, value []byte) (raw []byte, err error) {
+	raw, err = mc.Client.WriteMultipleRegisters(addr, uint16(len(value)/2), value)
+	if err != nil {
+		log.Errorf("Modbus Polling: [failed to WriteRegisters  addr:%d  quantity:%d error: %v]", addr, len(value)/2, err)
+	}
+	return
+}
+
+// EncodeRegisters Encodes a value of dataType to the register bytes that the single point writes send. 16-bit values are
+// sent as is, like WriteSingleRegister does. Returns an error if the value is out of the range of dataType.
+func (mc *ModbusClient) EncodeRegisters(dataType string, value float64) ([]byte, error) {
+	switch dataType {
+	case string(datatype.TypeInt16):
+		if err := CheckValueRange(dataType, value); err != nil {
+			return nil, err
+		}
+		return uint16ToBytes(BigEndian, uint16(int16(value))), nil
+	case string(datatype.TypeUint16):
+		if err := CheckValueRange(dataType, value); err != nil {
+			return nil, err
+		}
+		return uint16ToBytes(BigEndian, uint16(value)), nil
+	}
+	return EncodeValue(mc.Endianness, mc.WordOrder, dataType, value)
+}
+
 // WriteSingleRegister write one register
 func (mc *ModbusClient) WriteSingleRegister(addr uint16, value uint16) (raw []byte, out float64, err error) {
 	raw, err = mc.Client.WriteSingleRegister(addr, value)
@@ -0,0 +1,153 @@
+package smod
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/NubeIO/nubeio-rubix-lib-models-go/datatype"
+	"github.com/grid-x/modbus"
+)
+
+// registerClient is a modbus.Client of a device with a holding register map, which holds the bytes of each register
+// as they are sent on the bus.
+type registerClient struct {
+	modbus.Client
+	registers map[uint16][]byte
+}
+
+func newRegisterClient() *registerClient {
+	return &registerClient{registers: make(map[uint16][]byte)}
+}
+
+func (c *registerClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	out := make([]byte, 0, quantity*2)
+	for i := uint16(0); i < quantity; i++ {
+		register, ok := c.registers[address+i]
+		if !ok {
+			register = []byte{0, 0}
+		}
+		out = append(out, register...)
+	}
+	return out, nil
+}
+
+func (c *registerClient) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
+	return c.ReadHoldingRegisters(address, quantity)
+}
+
+func (c *registerClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	for i := uint16(0); i < quantity; i++ {
+		c.registers[address+i] = []byte{value[i*2], value[i*2+1]}
+	}
+	return []byte{byte(quantity >> 8), byte(quantity)}, nil
+}
+
+// objectEncodings are the byte and word orders of the ObjectEncoding options of points.
+var objectEncodings = []struct {
+	objectEncoding datatype.ByteOrder
+	endianness     Endianness
+	wordOrder      WordOrder
+}{
+	{datatype.ByteOrderBebBew, BigEndian, HighWordFirst},
+	{datatype.ByteOrderBebLew, BigEndian, LowWordFirst},
+	{datatype.ByteOrderLebBew, LittleEndian, HighWordFirst},
+	{datatype.ByteOrderLebLew, LittleEndian, LowWordFirst},
+}
+
+func TestFloat64RoundTrip(t *testing.T) {
+	// -2.5 is 0xC004000000000000
+	bus := map[datatype.ByteOrder]string{
+		datatype.ByteOrderBebBew: "c004000000000000",
+		datatype.ByteOrderBebLew: "000000000000c004",
+		datatype.ByteOrderLebBew: "04c0000000000000",
+		datatype.ByteOrderLebLew: "00000000000004c0",
+	}
+	values := []float64{-2.5, 0, 1, 1234567.891011, -math.MaxFloat64, math.SmallestNonzeroFloat64, math.Inf(1)}
+	for _, enc := range objectEncodings {
+		t.Run(string(enc.objectEncoding), func(t *testing.T) {
+			client := newRegisterClient()
+			mc := &ModbusClient{Client: client}
+			mc.SetEncoding(enc.endianness, enc.wordOrder)
+
+			if _, _, err := mc.WriteFloat64(10, -2.5); err != nil {
+				t.Fatalf("WriteFloat64() error = %v", err)
+			}
+			raw, _ := client.ReadHoldingRegisters(10, 4)
+			if got := hex.EncodeToString(raw); got != bus[enc.objectEncoding] {
+				t.Errorf("WriteFloat64() sent %s, want %s", got, bus[enc.objectEncoding])
+			}
+
+			for _, value := range values {
+				_, out, err := mc.WriteFloat64(10, value)
+				if err != nil || out != value {
+					t.Fatalf("WriteFloat64(%v) = %v, %v", value, out, err)
+				}
+				for _, regType := range []RegType{HoldingRegister, InputRegister} {
+					if _, out, err = mc.ReadFloat64(10, regType); err != nil || out != value {
+						t.Errorf("ReadFloat64() of %v = %v, %v", value, out, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFloat32RoundTrip(t *testing.T) {
+	values := []float64{-2.5, 0, 1, float64(float32(123.456)), -math.MaxFloat32, math.Inf(-1)}
+	for _, enc := range objectEncodings {
+		t.Run(string(enc.objectEncoding), func(t *testing.T) {
+			mc := &ModbusClient{Client: newRegisterClient()}
+			mc.SetEncoding(enc.endianness, enc.wordOrder)
+			for _, value := range values {
+				_, out, err := mc.WriteFloat32(20, value)
+				if err != nil || out != value {
+					t.Fatalf("WriteFloat32(%v) = %v, %v", value, out, err)
+				}
+				for _, regType := range []RegType{HoldingRegister, InputRegister} {
+					if _, out, err = mc.ReadFloat32(20, regType); err != nil || out != value {
+						t.Errorf("ReadFloat32() of %v = %v, %v", value, out, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInt64RoundTrip(t *testing.T) {
+	tests := []struct {
+		dataType string
+		values   []float64
+	}{
+		{string(datatype.TypeInt64), []float64{-1, 0, 1, -1234567890123, 1 << 52, math.MinInt64}},
+		{string(datatype.TypeUint64), []float64{0, 1, 1234567890123, 1 << 53, 1 << 63}},
+	}
+	for _, enc := range objectEncodings {
+		for _, tt := range tests {
+			t.Run(string(enc.objectEncoding)+"/"+tt.dataType, func(t *testing.T) {
+				mc := &ModbusClient{Client: newRegisterClient()}
+				mc.SetEncoding(enc.endianness, enc.wordOrder)
+				for _, value := range tt.values {
+					register := uint64(value)
+					if tt.dataType == string(datatype.TypeInt64) {
+						register = uint64(int64(value))
+					}
+					if _, _, err := mc.WriteQuadRegister(30, register); err != nil {
+						t.Fatalf("WriteQuadRegister(%v) error = %v", value, err)
+					}
+					for _, read := range []func(uint16, uint16, string) ([]byte, float64, error){mc.ReadHoldingRegisters, mc.ReadInputRegisters} {
+						if _, out, err := read(30, 4, tt.dataType); err != nil || out != value {
+							t.Errorf("read of %v = %v, %v", value, out, err)
+						}
+					}
+					if _, out, err := mc.WriteValue(30, tt.dataType, value); err != nil || out != value {
+						t.Errorf("WriteValue(%v) = %v, %v", value, out, err)
+					}
+					if _, out, _ := mc.ReadHoldingRegisters(30, 4, tt.dataType); out != value {
+						t.Errorf("read of WriteValue(%v) = %v", value, out)
+					}
+				}
+			})
+		}
+	}
+}